@@ -1,16 +1,18 @@
 module github.com/illmade-knight/go-secure-messaging
 
-go 1.24
+go 1.24.0
 
 require (
 	github.com/illmade-knight/go-action-intention-protos v1.0.35
 	github.com/stretchr/testify v1.11.1
 	github.com/tinywideclouds/go-action-intention-protos v0.0.0-20251029162430-fd543f6b267d
+	golang.org/x/crypto v0.45.0
+	google.golang.org/protobuf v1.36.9
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	google.golang.org/protobuf v1.36.9 // indirect
+	golang.org/x/sys v0.38.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,49 @@
+// Package chainkdf implements the symmetric-key ratchet shared by the
+// Double Ratchet and sender keys: an HMAC-SHA256 chain that yields a fresh
+// message key at every step, and the expansion of a single-use message key
+// into an AES-256-GCM key and nonce.
+package chainkdf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+const (
+	// KeySize is the size of chain keys, message keys and AES-256 keys.
+	KeySize   = 32
+	nonceSize = 12
+)
+
+// Next advances a chain and returns the next chain key and the message key
+// for the current step.
+func Next(chainKey []byte) (nextChainKey, messageKey []byte) {
+	mac := hmac.New(sha256.New, chainKey)
+	mac.Write([]byte{0x02})
+	nextChainKey = mac.Sum(nil)
+	mac.Reset()
+	mac.Write([]byte{0x01})
+	return nextChainKey, mac.Sum(nil)
+}
+
+// MessageAEAD expands a message key into an AES-256-GCM key and nonce,
+// using info to separate protocols. Each message key is used exactly once,
+// so a derived nonce is safe.
+func MessageAEAD(messageKey []byte, info string) (cipher.AEAD, []byte, error) {
+	out, err := hkdf.Key(sha256.New, messageKey, nil, info, KeySize+nonceSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(out[:KeySize])
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, out[KeySize:], nil
+}
@@ -0,0 +1,49 @@
+package chainkdf_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/chainkdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	chainKey := bytes.Repeat([]byte{0x11}, chainkdf.KeySize)
+
+	next, messageKey := chainkdf.Next(chainKey)
+	assert.Len(t, next, chainkdf.KeySize)
+	assert.Len(t, messageKey, chainkdf.KeySize)
+	assert.NotEqual(t, next, messageKey)
+	assert.NotEqual(t, chainKey, next)
+
+	again, againKey := chainkdf.Next(chainKey)
+	assert.Equal(t, next, again, "the chain is deterministic")
+	assert.Equal(t, messageKey, againKey)
+
+	_, following := chainkdf.Next(next)
+	assert.NotEqual(t, messageKey, following, "every step yields a new message key")
+}
+
+func TestMessageAEAD(t *testing.T) {
+	_, messageKey := chainkdf.Next(bytes.Repeat([]byte{0x22}, chainkdf.KeySize))
+
+	aead, nonce, err := chainkdf.MessageAEAD(messageKey, "test/v1 message")
+	require.NoError(t, err)
+	assert.Len(t, nonce, aead.NonceSize())
+	ciphertext := aead.Seal(nil, nonce, []byte("hello"), []byte("ad"))
+
+	same, sameNonce, err := chainkdf.MessageAEAD(messageKey, "test/v1 message")
+	require.NoError(t, err)
+	plaintext, err := same.Open(nil, sameNonce, ciphertext, []byte("ad"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), plaintext)
+
+	// A different info label derives an unrelated key and nonce.
+	other, otherNonce, err := chainkdf.MessageAEAD(messageKey, "other/v1 message")
+	require.NoError(t, err)
+	assert.NotEqual(t, nonce, otherNonce)
+	_, err = other.Open(nil, otherNonce, ciphertext, []byte("ad"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,25 @@
+package groupenv
+
+import (
+	"encoding/binary"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+)
+
+// AssociatedData binds the envelope's suite, header and routed fields to
+// its ciphertext. label separates the protocols that use it.
+func AssociatedData(label string, env *transport.SecureEnvelope) []byte {
+	b := []byte(label)
+	b = binary.BigEndian.AppendUint16(b, uint16(env.Suite))
+	for _, f := range []string{
+		string(env.EncryptedSymmetricKey),
+		env.MessageID,
+		env.SenderID.String(),
+		env.GroupID.String(),
+		env.ConversationID.String(),
+	} {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(f)))
+		b = append(b, f...)
+	}
+	return b
+}
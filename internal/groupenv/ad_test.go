@@ -0,0 +1,42 @@
+package groupenv_test
+
+import (
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/groupenv"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssociatedData(t *testing.T) {
+	env := &transport.SecureEnvelope{
+		Suite:                 transport.SuiteSenderKeyAES256GCMEd25519,
+		MessageID:             "m1",
+		SenderID:              mustParse(t, "urn:sm:user:alice"),
+		GroupID:               mustParse(t, "urn:sm:group:g1"),
+		EncryptedSymmetricKey: []byte{1, 2, 3},
+	}
+	ad := groupenv.AssociatedData("label", env)
+	assert.NotEqual(t, ad, groupenv.AssociatedData("other", env), "the label separates protocols")
+
+	for name, modify := range map[string]func(e *transport.SecureEnvelope){
+		"Suite":        func(e *transport.SecureEnvelope) { e.Suite = transport.SuiteMLSX25519AES256GCMEd25519 },
+		"Header":       func(e *transport.SecureEnvelope) { e.EncryptedSymmetricKey = []byte{1, 2, 4} },
+		"Message ID":   func(e *transport.SecureEnvelope) { e.MessageID = "m2" },
+		"Sender":       func(e *transport.SecureEnvelope) { e.SenderID = mustParse(t, "urn:sm:user:bob") },
+		"Conversation": func(e *transport.SecureEnvelope) { e.ConversationID = mustParse(t, "urn:sm:conversation:c1") },
+	} {
+		changed := *env
+		modify(&changed)
+		assert.NotEqual(t, ad, groupenv.AssociatedData("label", &changed), name)
+	}
+}
+
+func mustParse(t *testing.T, s string) urn.URN {
+	t.Helper()
+	u, err := urn.Parse(s)
+	require.NoError(t, err)
+	return u
+}
@@ -0,0 +1,94 @@
+package groupenv
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrKeyUsed is returned for a message whose key was already used or
+	// has been discarded from the skipped-key window.
+	ErrKeyUsed = errors.New("message was already received or discarded")
+	// ErrTooManySkipped is returned when a message would require skipping
+	// more message keys than allowed.
+	ErrTooManySkipped = errors.New("too many skipped messages")
+)
+
+// StepFunc advances a hash ratchet from the chain key for message n and
+// returns the next chain key and the message key for n.
+type StepFunc func(chainKey []byte, n uint32) (next, messageKey []byte)
+
+// Chain is a hash ratchet that keeps the message keys it skips over, up to
+// a limit, so that messages arriving out of order can still be decrypted.
+type Chain struct {
+	n        uint32
+	chainKey []byte
+	step     StepFunc
+	skipped  map[uint32][]byte
+	order    []uint32
+}
+
+// NewChain creates a chain whose next message is n, with chainKey as the
+// key for it.
+func NewChain(n uint32, chainKey []byte, step StepFunc) *Chain {
+	return &Chain{n: n, chainKey: chainKey, step: step, skipped: make(map[uint32][]byte)}
+}
+
+// Next returns the number and key of the next message and advances the
+// chain. It is used on the sending side, where nothing is skipped.
+func (c *Chain) Next() (uint32, []byte) {
+	n := c.n
+	var messageKey []byte
+	c.chainKey, messageKey = c.step(c.chainKey, n)
+	c.n++
+	return n, messageKey
+}
+
+// MessageKey returns the key for message n, advancing the chain and storing
+// skipped keys as needed. At most maxSkip keys are skipped in one step or
+// kept in total, the oldest being discarded first. The caller must only
+// commit the result if the message decrypts, so it operates on and returns
+// a copy.
+func (c *Chain) MessageKey(n uint32, maxSkip int) (*Chain, []byte, error) {
+	if n < c.n {
+		key, ok := c.skipped[n]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: message %d", ErrKeyUsed, n)
+		}
+		next := c.clone()
+		delete(next.skipped, n)
+		for i, m := range next.order {
+			if m == n {
+				next.order = append(next.order[:i:i], next.order[i+1:]...)
+				break
+			}
+		}
+		return next, key, nil
+	}
+	if int64(n)-int64(c.n) > int64(maxSkip) {
+		return nil, nil, fmt.Errorf("%w: %d messages", ErrTooManySkipped, n-c.n)
+	}
+
+	next := c.clone()
+	for next.n < n {
+		m, key := next.Next()
+		next.skipped[m] = key
+		next.order = append(next.order, m)
+	}
+	for len(next.order) > maxSkip {
+		delete(next.skipped, next.order[0])
+		next.order = next.order[1:]
+	}
+	_, key := next.Next()
+	return next, key, nil
+}
+
+func (c *Chain) clone() *Chain {
+	n := *c
+	n.skipped = make(map[uint32][]byte, len(c.skipped))
+	for m, key := range c.skipped {
+		n.skipped[m] = key
+	}
+	n.order = append([]uint32(nil), c.order...)
+	return &n
+}
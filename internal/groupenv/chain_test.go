@@ -0,0 +1,70 @@
+package groupenv_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/groupenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStep makes message keys that encode their number, so tests can tell
+// which key they were given.
+func testStep(chainKey []byte, n uint32) ([]byte, []byte) {
+	return chainKey, binary.BigEndian.AppendUint32(nil, n)
+}
+
+func keyFor(n uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, n)
+}
+
+func TestChainNext(t *testing.T) {
+	c := groupenv.NewChain(5, []byte("ck"), testStep)
+	n, key := c.Next()
+	assert.Equal(t, uint32(5), n)
+	assert.Equal(t, keyFor(5), key)
+	n, _ = c.Next()
+	assert.Equal(t, uint32(6), n)
+}
+
+func TestChainMessageKey(t *testing.T) {
+	c := groupenv.NewChain(0, []byte("ck"), testStep)
+
+	next, key, err := c.MessageKey(3, 10)
+	require.NoError(t, err)
+	assert.Equal(t, keyFor(3), key)
+
+	_, _, err = c.MessageKey(0, 10)
+	require.NoError(t, err, "the original chain is not modified")
+
+	c = next
+	for _, n := range []uint32{1, 0, 2} {
+		next, key, err := c.MessageKey(n, 10)
+		require.NoError(t, err, "skipped message %d", n)
+		assert.Equal(t, keyFor(n), key)
+		c = next
+	}
+	_, _, err = c.MessageKey(1, 10)
+	assert.ErrorIs(t, err, groupenv.ErrKeyUsed, "skipped keys are used once")
+	_, _, err = c.MessageKey(3, 10)
+	assert.ErrorIs(t, err, groupenv.ErrKeyUsed)
+
+	t.Run("Skip Limit", func(t *testing.T) {
+		c := groupenv.NewChain(0, []byte("ck"), testStep)
+		_, _, err := c.MessageKey(3, 2)
+		assert.ErrorIs(t, err, groupenv.ErrTooManySkipped)
+
+		// Each step is within the limit, but only the newest two skipped
+		// keys are kept.
+		c, _, err = c.MessageKey(2, 2)
+		require.NoError(t, err)
+		c, _, err = c.MessageKey(5, 2)
+		require.NoError(t, err)
+		_, _, err = c.MessageKey(0, 2)
+		assert.ErrorIs(t, err, groupenv.ErrKeyUsed)
+		_, key, err := c.MessageKey(4, 2)
+		require.NoError(t, err)
+		assert.Equal(t, keyFor(4), key)
+	})
+}
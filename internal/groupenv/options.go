@@ -0,0 +1,66 @@
+// Package groupenv holds the envelope plumbing shared by the sender key and
+// MLS group packages: per-message options, the associated data that binds
+// an envelope's routed fields to its ciphertext, and the receiving hash
+// ratchet that keeps skipped message keys.
+package groupenv
+
+import (
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// Options are the settings shared by group constructors and Encrypt calls.
+type Options struct {
+	MessageID      string
+	ConversationID urn.URN
+	SentAt         time.Time
+	TTL            time.Duration
+	MaxSkip        int
+}
+
+// Option configures Options.
+type Option func(*Options)
+
+// WithMessageID sets the envelope's MessageID.
+func WithMessageID(id string) Option {
+	return func(o *Options) { o.MessageID = id }
+}
+
+// WithConversationID sets the envelope's ConversationID.
+func WithConversationID(id urn.URN) Option {
+	return func(o *Options) { o.ConversationID = id }
+}
+
+// WithSentAt sets the envelope's SentAt.
+func WithSentAt(t time.Time) Option {
+	return func(o *Options) { o.SentAt = t }
+}
+
+// WithTTL sets the envelope's ExpiresAt to ttl after its SentAt.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) { o.TTL = ttl }
+}
+
+// WithMaxSkip sets the skip limit of a group's receiving chains.
+func WithMaxSkip(n int) Option {
+	return func(o *Options) { o.MaxSkip = n }
+}
+
+// NewOptions applies opts over the defaults.
+func NewOptions(opts []Option, defaultMaxSkip int) *Options {
+	o := &Options{MaxSkip: defaultMaxSkip}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Stamp sets env's SentAt and ExpiresAt if WithSentAt or WithTTL was given.
+// It must be called before the envelope is signed.
+func (o *Options) Stamp(env *transport.SecureEnvelope) {
+	if !o.SentAt.IsZero() || o.TTL > 0 {
+		env.Stamp(o.SentAt, o.TTL)
+	}
+}
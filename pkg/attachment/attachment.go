@@ -0,0 +1,151 @@
+// Package attachment encrypts files for storage outside the envelope and
+// describes them with a Pointer that travels inside it.
+//
+// A file is encrypted under a fresh random key as a stream of fixed-size
+// AES-256-GCM chunks, so files of any size can be encrypted and decrypted
+// with constant memory. The encrypted blob is uploaded on its own; the
+// Pointer, which holds the blob's URN, key and SHA-256 digest, is sent as
+// content in a sealed envelope. Importing this package registers Pointer
+// with content.DefaultRegistry under TypeAttachment.
+//
+// The blob is a five-byte header (format version, then the chunk size as a
+// big-endian uint32) followed by the chunks. Every chunk except the last
+// holds exactly ChunkSize bytes of plaintext; the last holds the rest, and
+// is empty only for an empty file. Each chunk's nonce encodes its index and
+// whether it is the last, so chunks cannot be reordered, dropped, or
+// truncated from the end without decryption failing.
+package attachment
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/content"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+const (
+	// KeySize is the size of an attachment key in bytes.
+	KeySize = 32
+	// DefaultChunkSize is the plaintext size of each chunk unless
+	// WithChunkSize is given.
+	DefaultChunkSize = 64 * 1024
+	// MinChunkSize and MaxChunkSize bound the chunk size.
+	MinChunkSize = 1024
+	MaxChunkSize = 16 * 1024 * 1024
+	// MaxThumbnailSize is the largest thumbnail a Pointer may carry inline.
+	MaxThumbnailSize = 64 * 1024
+
+	// TypeAttachment is the content type of a Pointer.
+	TypeAttachment content.ContentType = "attachment"
+)
+
+var (
+	// ErrInvalidPointer is returned when a Pointer is incomplete or
+	// malformed.
+	ErrInvalidPointer = errors.New("invalid attachment pointer")
+	// ErrMalformed is returned when a blob is not in the expected format.
+	ErrMalformed = errors.New("malformed attachment blob")
+	// ErrDecryptionFailed is returned when a chunk does not decrypt, because
+	// the blob was corrupted, reordered or truncated, or the key is wrong.
+	ErrDecryptionFailed = errors.New("attachment decryption failed")
+	// ErrDigestMismatch is returned when a blob's SHA-256 digest does not
+	// match the Pointer.
+	ErrDigestMismatch = errors.New("attachment digest mismatch")
+	// ErrSizeMismatch is returned when a blob decrypts to a different number
+	// of bytes than the Pointer records.
+	ErrSizeMismatch = errors.New("attachment size mismatch")
+)
+
+func init() {
+	content.DefaultRegistry.MustRegister(TypeAttachment, func() content.Content { return &Pointer{} })
+}
+
+// Pointer references an encrypted blob stored outside the envelope and
+// carries everything needed to fetch, verify and decrypt it. It contains
+// the blob's key, so it must only be sent inside an encrypted envelope.
+type Pointer struct {
+	// BlobID is where the encrypted blob was stored, usually a
+	// urn:sm:blob:<id> URN.
+	BlobID urn.URN `json:"blobId"`
+	// MediaType is the MIME type of the plaintext, e.g. "image/jpeg".
+	MediaType string `json:"mediaType"`
+	// FileName is the file's original name, if it had one.
+	FileName string `json:"fileName,omitempty"`
+	// Size is the length of the plaintext in bytes.
+	Size int64 `json:"size"`
+	// ChunkSize is the plaintext size of each chunk in the blob.
+	ChunkSize int `json:"chunkSize"`
+	// Key is the random key the blob was encrypted under.
+	Key []byte `json:"key"`
+	// Digest is the SHA-256 digest of the encrypted blob.
+	Digest    []byte     `json:"digest"`
+	Thumbnail *Thumbnail `json:"thumbnail,omitempty"`
+}
+
+// Thumbnail is a small preview image carried inline in a Pointer, so that it
+// can be shown before the blob is downloaded.
+type Thumbnail struct {
+	MediaType string `json:"mediaType"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Data      []byte `json:"data"`
+}
+
+// ContentType implements content.Content.
+func (Pointer) ContentType() content.ContentType { return TypeAttachment }
+
+// Validate checks that the pointer is complete and its key, digest and
+// chunk size are well formed.
+func (p Pointer) Validate() error {
+	switch {
+	case p.BlobID.IsZero():
+		return fmt.Errorf("%w: blob ID is required", ErrInvalidPointer)
+	case p.MediaType == "":
+		return fmt.Errorf("%w: media type is required", ErrInvalidPointer)
+	case p.Size < 0:
+		return fmt.Errorf("%w: negative size %d", ErrInvalidPointer, p.Size)
+	case p.ChunkSize < MinChunkSize || p.ChunkSize > MaxChunkSize:
+		return fmt.Errorf("%w: chunk size %d is out of range", ErrInvalidPointer, p.ChunkSize)
+	case len(p.Key) != KeySize:
+		return fmt.Errorf("%w: key must be %d bytes, got %d", ErrInvalidPointer, KeySize, len(p.Key))
+	case len(p.Digest) != sha256.Size:
+		return fmt.Errorf("%w: digest must be %d bytes, got %d", ErrInvalidPointer, sha256.Size, len(p.Digest))
+	}
+	if t := p.Thumbnail; t != nil {
+		switch {
+		case t.MediaType == "" || len(t.Data) == 0:
+			return fmt.Errorf("%w: thumbnail needs a media type and data", ErrInvalidPointer)
+		case len(t.Data) > MaxThumbnailSize:
+			return fmt.Errorf("%w: thumbnail is %d bytes, limit is %d", ErrInvalidPointer, len(t.Data), MaxThumbnailSize)
+		case t.Width < 0 || t.Height < 0:
+			return fmt.Errorf("%w: negative thumbnail dimensions", ErrInvalidPointer)
+		}
+	}
+	return nil
+}
+
+// EncryptedSize returns the length of the encrypted blob, for example to
+// set the Content-Length of an upload. It returns 0 if ChunkSize is not
+// set.
+func (p Pointer) EncryptedSize() int64 {
+	if p.ChunkSize <= 0 {
+		return 0
+	}
+	chunks := (p.Size + int64(p.ChunkSize) - 1) / int64(p.ChunkSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+	return headerSize + p.Size + chunks*tagSize
+}
+
+// NewKey returns a random attachment key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,110 @@
+package attachment_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/attachment"
+	"github.com/illmade-knight/go-secure-messaging/pkg/content"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, s string) urn.URN {
+	t.Helper()
+	u, err := urn.Parse(s)
+	require.NoError(t, err)
+	return u
+}
+
+func validPointer(t *testing.T) *attachment.Pointer {
+	t.Helper()
+	return &attachment.Pointer{
+		BlobID:    mustParse(t, "urn:sm:blob:0f8fad5b-d9cb-469f-a165-70867728950e"),
+		MediaType: "image/jpeg",
+		FileName:  "cat.jpg",
+		Size:      150_000,
+		ChunkSize: attachment.DefaultChunkSize,
+		Key:       make([]byte, attachment.KeySize),
+		Digest:    make([]byte, sha256.Size),
+		Thumbnail: &attachment.Thumbnail{MediaType: "image/jpeg", Width: 32, Height: 24, Data: []byte("jpeg")},
+	}
+}
+
+func TestPointerValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(p *attachment.Pointer)
+		valid  bool
+	}{
+		{name: "Valid", modify: func(p *attachment.Pointer) {}, valid: true},
+		{name: "No Thumbnail", modify: func(p *attachment.Pointer) { p.Thumbnail = nil }, valid: true},
+		{name: "Empty File", modify: func(p *attachment.Pointer) { p.Size = 0 }, valid: true},
+		{name: "No Blob ID", modify: func(p *attachment.Pointer) { p.BlobID = urn.URN{} }},
+		{name: "No Media Type", modify: func(p *attachment.Pointer) { p.MediaType = "" }},
+		{name: "Negative Size", modify: func(p *attachment.Pointer) { p.Size = -1 }},
+		{name: "Chunk Size Too Small", modify: func(p *attachment.Pointer) { p.ChunkSize = attachment.MinChunkSize - 1 }},
+		{name: "Chunk Size Too Large", modify: func(p *attachment.Pointer) { p.ChunkSize = attachment.MaxChunkSize + 1 }},
+		{name: "Short Key", modify: func(p *attachment.Pointer) { p.Key = p.Key[:16] }},
+		{name: "Short Digest", modify: func(p *attachment.Pointer) { p.Digest = p.Digest[:16] }},
+		{name: "Empty Thumbnail", modify: func(p *attachment.Pointer) { p.Thumbnail.Data = nil }},
+		{name: "Thumbnail Without Media Type", modify: func(p *attachment.Pointer) { p.Thumbnail.MediaType = "" }},
+		{name: "Thumbnail Too Large", modify: func(p *attachment.Pointer) { p.Thumbnail.Data = make([]byte, attachment.MaxThumbnailSize+1) }},
+		{name: "Negative Thumbnail Width", modify: func(p *attachment.Pointer) { p.Thumbnail.Width = -1 }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := validPointer(t)
+			tc.modify(p)
+			if tc.valid {
+				assert.NoError(t, p.Validate())
+			} else {
+				assert.ErrorIs(t, p.Validate(), attachment.ErrInvalidPointer)
+			}
+		})
+	}
+}
+
+func TestPointerEncryptedSize(t *testing.T) {
+	p := attachment.Pointer{ChunkSize: 1024}
+	for size, expected := range map[int64]int64{
+		0:    5 + 16,
+		1:    5 + 1 + 16,
+		1024: 5 + 1024 + 16,
+		1025: 5 + 1025 + 2*16,
+	} {
+		p.Size = size
+		assert.Equal(t, expected, p.EncryptedSize(), "size %d", size)
+	}
+	assert.Zero(t, attachment.Pointer{Size: 10}.EncryptedSize())
+}
+
+func TestPointerContent(t *testing.T) {
+	assert.True(t, content.DefaultRegistry.IsRegistered(attachment.TypeAttachment))
+
+	p := validPointer(t)
+	data, err := content.Marshal(p)
+	require.NoError(t, err)
+	got, err := content.Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, p, got)
+
+	_, err = content.Marshal(attachment.Pointer{})
+	assert.ErrorIs(t, err, content.ErrInvalidContent)
+	assert.ErrorIs(t, err, attachment.ErrInvalidPointer)
+
+	t.Run("Sealed In An Envelope", func(t *testing.T) {
+		alice := mustParse(t, "urn:sm:user:alice")
+		bobKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		env, err := content.Seal(p, alice, bobKey.PublicKey())
+		require.NoError(t, err)
+		opened, err := content.Open(env, bobKey)
+		require.NoError(t, err)
+		assert.Equal(t, p, opened)
+	})
+}
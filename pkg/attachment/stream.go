@@ -0,0 +1,347 @@
+package attachment
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+)
+
+const (
+	formatVersion = 0x01
+	headerSize    = 5
+	tagSize       = 16
+	chunkKeyInfo  = "go-secure-messaging/attachment/v1 chunk"
+)
+
+// Option configures an Encryptor.
+type Option func(*options)
+
+type options struct {
+	chunkSize int
+}
+
+// WithChunkSize sets the plaintext size of each chunk. It must be between
+// MinChunkSize and MaxChunkSize. The default is DefaultChunkSize.
+func WithChunkSize(n int) Option {
+	return func(o *options) { o.chunkSize = n }
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{chunkSize: DefaultChunkSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Encryptor is an io.WriteCloser that encrypts everything written to it
+// into a blob written to an underlying writer. Close must be called to write
+// the last chunk; it does not close the underlying writer.
+type Encryptor struct {
+	dst       io.Writer
+	hash      hash.Hash
+	aead      cipher.AEAD
+	ad        []byte
+	chunkSize int
+	index     uint32
+	buf       []byte
+	out       []byte
+	size      int64
+	closed    bool
+	err       error
+}
+
+// NewEncryptor writes a blob header to dst and returns an Encryptor for the
+// rest of the blob, encrypting under key.
+func NewEncryptor(dst io.Writer, key []byte, opts ...Option) (*Encryptor, error) {
+	o := newOptions(opts)
+	if o.chunkSize < MinChunkSize || o.chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("chunk size %d is out of range", o.chunkSize)
+	}
+	aead, err := chunkAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	e := &Encryptor{
+		hash:      sha256.New(),
+		aead:      aead,
+		ad:        header(o.chunkSize),
+		chunkSize: o.chunkSize,
+		buf:       make([]byte, 0, o.chunkSize),
+		out:       make([]byte, 0, o.chunkSize+tagSize),
+	}
+	e.dst = io.MultiWriter(dst, e.hash)
+	if _, err := e.dst.Write(e.ad); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Write encrypts p. A chunk is only written once it is full and more data
+// follows, since the last chunk is sealed differently.
+func (e *Encryptor) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed attachment encryptor")
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(e.buf) == e.chunkSize {
+			if e.err = e.flush(false); e.err != nil {
+				return written, e.err
+			}
+		}
+		n := copy(e.buf[len(e.buf):e.chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		e.size += int64(n)
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close writes the last chunk.
+func (e *Encryptor) Close() error {
+	if e.closed {
+		return e.err
+	}
+	e.closed = true
+	if e.err == nil {
+		e.err = e.flush(true)
+	}
+	return e.err
+}
+
+// Size returns the number of plaintext bytes written.
+func (e *Encryptor) Size() int64 {
+	return e.size
+}
+
+// Digest returns the SHA-256 digest of the blob. It is only complete once
+// Close has returned.
+func (e *Encryptor) Digest() []byte {
+	return e.hash.Sum(nil)
+}
+
+func (e *Encryptor) flush(final bool) error {
+	if !final && e.index == math.MaxUint32 {
+		return errors.New("attachment has too many chunks")
+	}
+	e.out = e.aead.Seal(e.out[:0], chunkNonce(e.index, final), e.buf, e.ad)
+	if _, err := e.dst.Write(e.out); err != nil {
+		return err
+	}
+	e.index++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// Encrypt encrypts everything read from src under a new key, writes the
+// blob to dst and returns a Pointer to it. The caller fills in the Pointer's
+// BlobID once the blob is stored, and its MediaType and other descriptive
+// fields.
+func Encrypt(dst io.Writer, src io.Reader, opts ...Option) (*Pointer, error) {
+	key, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	e, err := NewEncryptor(dst, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(e, src); err != nil {
+		return nil, err
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return &Pointer{Size: e.Size(), ChunkSize: e.chunkSize, Key: key, Digest: e.Digest()}, nil
+}
+
+// Decryptor is an io.Reader that decrypts a blob described by a Pointer.
+// Every chunk is authenticated before its plaintext is returned. The blob's
+// size and digest are checked before the last chunk is returned, so a
+// reader that reaches io.EOF has read exactly the file the Pointer
+// describes.
+type Decryptor struct {
+	src       *bufio.Reader
+	hash      hash.Hash
+	pointer   *Pointer
+	aead      cipher.AEAD
+	ad        []byte
+	chunkSize int
+	index     uint32
+	buf       []byte
+	plain     []byte
+	size      int64
+	done      bool
+	err       error
+}
+
+// NewDecryptor validates p, reads the blob header from src and returns a
+// Decryptor for the rest of the blob.
+func NewDecryptor(src io.Reader, p *Pointer) (*Decryptor, error) {
+	if p == nil {
+		return nil, fmt.Errorf("%w: pointer is nil", ErrInvalidPointer)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	aead, err := chunkAEAD(p.Key)
+	if err != nil {
+		return nil, err
+	}
+	d := &Decryptor{
+		hash:      sha256.New(),
+		pointer:   p,
+		aead:      aead,
+		ad:        header(p.ChunkSize),
+		chunkSize: p.ChunkSize,
+		buf:       make([]byte, p.ChunkSize+tagSize),
+	}
+	d.src = bufio.NewReader(io.TeeReader(src, d.hash))
+
+	h := make([]byte, headerSize)
+	if _, err := io.ReadFull(d.src, h); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %w", ErrMalformed, err)
+	}
+	if h[0] != formatVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrMalformed, h[0])
+	}
+	if got := binary.BigEndian.Uint32(h[1:]); got != uint32(p.ChunkSize) {
+		return nil, fmt.Errorf("%w: blob chunk size %d does not match pointer", ErrMalformed, got)
+	}
+	return d, nil
+}
+
+// Read implements io.Reader.
+func (d *Decryptor) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next reads and decrypts one chunk into d.plain.
+func (d *Decryptor) next() error {
+	n, err := io.ReadFull(d.src, d.buf)
+	final := false
+	switch {
+	case err == nil:
+		if _, err := d.src.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF) && n >= tagSize:
+		final = true
+	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		return fmt.Errorf("%w: chunk %d is missing or short", ErrMalformed, d.index)
+	default:
+		return err
+	}
+
+	plain, err := d.aead.Open(d.buf[:0], chunkNonce(d.index, final), d.buf[:n], d.ad)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d", ErrDecryptionFailed, d.index)
+	}
+	if !final && len(plain) != d.chunkSize {
+		return fmt.Errorf("%w: chunk %d is short", ErrMalformed, d.index)
+	}
+	d.index++
+	d.size += int64(len(plain))
+	if d.size > d.pointer.Size {
+		return fmt.Errorf("%w: blob is longer than %d bytes", ErrSizeMismatch, d.pointer.Size)
+	}
+	if final {
+		if d.size != d.pointer.Size {
+			return fmt.Errorf("%w: blob is %d bytes, expected %d", ErrSizeMismatch, d.size, d.pointer.Size)
+		}
+		if subtle.ConstantTimeCompare(d.hash.Sum(nil), d.pointer.Digest) != 1 {
+			return ErrDigestMismatch
+		}
+		d.done = true
+	}
+	d.plain = plain
+	return nil
+}
+
+// Decrypt decrypts the blob read from src, described by p, into dst.
+func Decrypt(dst io.Writer, src io.Reader, p *Pointer) error {
+	d, err := NewDecryptor(src, p)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, d)
+	return err
+}
+
+// Verify decrypts the blob read from src and discards the plaintext,
+// returning nil only if it is exactly the file p describes.
+func Verify(src io.Reader, p *Pointer) error {
+	return Decrypt(io.Discard, src, p)
+}
+
+// VerifyDigest checks the SHA-256 digest of the blob read from src without
+// decrypting it. It lets a party without the key, such as a storage
+// service, check that a blob was stored intact.
+func VerifyDigest(src io.Reader, digest []byte) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(h.Sum(nil), digest) != 1 {
+		return ErrDigestMismatch
+	}
+	return nil
+}
+
+func header(chunkSize int) []byte {
+	return binary.BigEndian.AppendUint32([]byte{formatVersion}, uint32(chunkSize))
+}
+
+func chunkAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("attachment key must be %d bytes, got %d", KeySize, len(key))
+	}
+	chunkKey, err := hkdf.Key(sha256.New, key, nil, chunkKeyInfo, KeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(chunkKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce is seven zero bytes, the chunk index as a big-endian uint32 and
+// a final byte that is 1 for the last chunk. Every blob has its own key, so
+// nonces only need to be unique within a blob.
+func chunkNonce(index uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint32(nonce[7:], index)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
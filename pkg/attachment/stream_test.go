@@ -0,0 +1,288 @@
+package attachment_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/attachment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChunkSize = attachment.MinChunkSize
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
+
+// encrypt encrypts plaintext with the test chunk size and returns the blob
+// and a pointer to it.
+func encrypt(t *testing.T, plaintext []byte) ([]byte, *attachment.Pointer) {
+	t.Helper()
+	var blob bytes.Buffer
+	p, err := attachment.Encrypt(&blob, bytes.NewReader(plaintext), attachment.WithChunkSize(testChunkSize))
+	require.NoError(t, err)
+	p.BlobID = mustParse(t, "urn:sm:blob:b1")
+	p.MediaType = "application/octet-stream"
+	return blob.Bytes(), p
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, size := range []int{0, 1, testChunkSize - 1, testChunkSize, testChunkSize + 1, 3 * testChunkSize, 5*testChunkSize + 17} {
+		t.Run("", func(t *testing.T) {
+			plaintext := randomBytes(t, size)
+			blob, p := encrypt(t, plaintext)
+
+			assert.Equal(t, int64(size), p.Size)
+			assert.Equal(t, p.EncryptedSize(), int64(len(blob)))
+			digest := sha256.Sum256(blob)
+			assert.Equal(t, digest[:], p.Digest)
+			require.NoError(t, p.Validate())
+
+			var out bytes.Buffer
+			require.NoError(t, attachment.Decrypt(&out, bytes.NewReader(blob), p))
+			assert.Equal(t, plaintext, out.Bytes(), "size %d", size)
+
+			// Readers that return one byte at a time exercise chunk boundaries.
+			d, err := attachment.NewDecryptor(iotest.OneByteReader(bytes.NewReader(blob)), p)
+			require.NoError(t, err)
+			got, err := io.ReadAll(iotest.OneByteReader(d))
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, got)
+
+			assert.NoError(t, attachment.Verify(bytes.NewReader(blob), p))
+			assert.NoError(t, attachment.VerifyDigest(bytes.NewReader(blob), p.Digest))
+		})
+	}
+}
+
+func TestEncryptorStreaming(t *testing.T) {
+	plaintext := randomBytes(t, 4*testChunkSize+100)
+	key, err := attachment.NewKey()
+	require.NoError(t, err)
+
+	var blob bytes.Buffer
+	e, err := attachment.NewEncryptor(&blob, key, attachment.WithChunkSize(testChunkSize))
+	require.NoError(t, err)
+	for rest := plaintext; len(rest) > 0; {
+		n := min(len(rest), 333)
+		written, err := e.Write(rest[:n])
+		require.NoError(t, err)
+		require.Equal(t, n, written)
+		rest = rest[n:]
+	}
+	require.NoError(t, e.Close())
+	require.NoError(t, e.Close(), "Close is idempotent")
+	_, err = e.Write([]byte("late"))
+	assert.Error(t, err)
+
+	p := &attachment.Pointer{
+		BlobID:    mustParse(t, "urn:sm:blob:b1"),
+		MediaType: "video/mp4",
+		Size:      e.Size(),
+		ChunkSize: testChunkSize,
+		Key:       key,
+		Digest:    e.Digest(),
+	}
+	var out bytes.Buffer
+	require.NoError(t, attachment.Decrypt(&out, &blob, p))
+	assert.Equal(t, plaintext, out.Bytes())
+
+	t.Run("Chunk Size Out Of Range", func(t *testing.T) {
+		for _, n := range []int{attachment.MinChunkSize - 1, attachment.MaxChunkSize + 1} {
+			_, err := attachment.NewEncryptor(io.Discard, key, attachment.WithChunkSize(n))
+			assert.Error(t, err)
+		}
+		_, err := attachment.NewEncryptor(io.Discard, key[:16])
+		assert.Error(t, err)
+	})
+
+	t.Run("Default Chunk Size", func(t *testing.T) {
+		p, err := attachment.Encrypt(io.Discard, bytes.NewReader(nil))
+		require.NoError(t, err)
+		assert.Equal(t, attachment.DefaultChunkSize, p.ChunkSize)
+	})
+}
+
+func TestDecryptFailures(t *testing.T) {
+	plaintext := randomBytes(t, 3*testChunkSize+10)
+	blob, p := encrypt(t, plaintext)
+	encChunk := testChunkSize + 16
+	header := 5
+
+	// redigest makes the pointer's digest match a modified blob, so that the
+	// chunk checks, not the digest, must catch the modification.
+	redigest := func(b []byte) *attachment.Pointer {
+		q := *p
+		d := sha256.Sum256(b)
+		q.Digest = d[:]
+		return &q
+	}
+	chunk := func(i int) []byte {
+		return blob[header+i*encChunk : min(header+(i+1)*encChunk, len(blob))]
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	testCases := []struct {
+		name          string
+		blob          []byte
+		pointer       func(b []byte) *attachment.Pointer
+		expectedErrIs error
+	}{
+		{
+			name: "Flipped Bit",
+			blob: func() []byte {
+				b := bytes.Clone(blob)
+				b[header+encChunk+3] ^= 1
+				return b
+			}(),
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrDecryptionFailed,
+		},
+		{
+			name:          "Truncated At Chunk Boundary",
+			blob:          blob[:header+2*encChunk],
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrDecryptionFailed,
+		},
+		{
+			name:          "Truncated Mid Chunk",
+			blob:          blob[:header+2*encChunk+100],
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrDecryptionFailed,
+		},
+		{
+			name:          "Dropped Chunk",
+			blob:          concat(blob[:header], chunk(0), chunk(2), chunk(3)),
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrDecryptionFailed,
+		},
+		{
+			name:          "Swapped Chunks",
+			blob:          concat(blob[:header], chunk(1), chunk(0), chunk(2), chunk(3)),
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrDecryptionFailed,
+		},
+		{
+			name:          "Appended Data",
+			blob:          concat(blob, chunk(0)),
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrDecryptionFailed,
+		},
+		{
+			name:          "Header Only",
+			blob:          blob[:header],
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrMalformed,
+		},
+		{
+			name:          "Short Header",
+			blob:          blob[:3],
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrMalformed,
+		},
+		{
+			name:          "Unknown Version",
+			blob:          concat([]byte{2}, blob[1:]),
+			pointer:       redigest,
+			expectedErrIs: attachment.ErrMalformed,
+		},
+		{
+			name: "Wrong Key",
+			blob: blob,
+			pointer: func(b []byte) *attachment.Pointer {
+				q := *p
+				q.Key = randomBytes(t, attachment.KeySize)
+				return &q
+			},
+			expectedErrIs: attachment.ErrDecryptionFailed,
+		},
+		{
+			name: "Wrong Chunk Size",
+			blob: blob,
+			pointer: func(b []byte) *attachment.Pointer {
+				q := *p
+				q.ChunkSize = 2 * testChunkSize
+				return &q
+			},
+			expectedErrIs: attachment.ErrMalformed,
+		},
+		{
+			name: "Wrong Digest",
+			blob: blob,
+			pointer: func(b []byte) *attachment.Pointer {
+				q := *p
+				q.Digest = make([]byte, sha256.Size)
+				return &q
+			},
+			expectedErrIs: attachment.ErrDigestMismatch,
+		},
+		{
+			name: "Claimed Size Too Small",
+			blob: blob,
+			pointer: func(b []byte) *attachment.Pointer {
+				q := *p
+				q.Size = testChunkSize
+				return &q
+			},
+			expectedErrIs: attachment.ErrSizeMismatch,
+		},
+		{
+			name: "Claimed Size Too Large",
+			blob: blob,
+			pointer: func(b []byte) *attachment.Pointer {
+				q := *p
+				q.Size++
+				return &q
+			},
+			expectedErrIs: attachment.ErrSizeMismatch,
+		},
+		{
+			name: "Invalid Pointer",
+			blob: blob,
+			pointer: func(b []byte) *attachment.Pointer {
+				q := *p
+				q.Key = nil
+				return &q
+			},
+			expectedErrIs: attachment.ErrInvalidPointer,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := attachment.Verify(bytes.NewReader(tc.blob), tc.pointer(tc.blob))
+			assert.ErrorIs(t, err, tc.expectedErrIs)
+		})
+	}
+
+	t.Run("Last Chunk Withheld Until Verified", func(t *testing.T) {
+		q := *p
+		q.Digest = make([]byte, sha256.Size)
+		d, err := attachment.NewDecryptor(bytes.NewReader(blob), &q)
+		require.NoError(t, err)
+		got, err := io.ReadAll(d)
+		assert.ErrorIs(t, err, attachment.ErrDigestMismatch)
+		assert.Len(t, got, 3*testChunkSize, "only the authenticated, non-final chunks were returned")
+	})
+
+	t.Run("Nil Pointer", func(t *testing.T) {
+		_, err := attachment.NewDecryptor(bytes.NewReader(blob), nil)
+		assert.ErrorIs(t, err, attachment.ErrInvalidPointer)
+	})
+
+	t.Run("VerifyDigest", func(t *testing.T) {
+		tampered := bytes.Clone(blob)
+		tampered[len(tampered)-1] ^= 1
+		assert.ErrorIs(t, attachment.VerifyDigest(bytes.NewReader(tampered), p.Digest), attachment.ErrDigestMismatch)
+	})
+}
@@ -0,0 +1,86 @@
+// Package content defines what goes inside an envelope's EncryptedData.
+//
+// Every plaintext is a Payload: a JSON object naming its content type and
+// holding the content itself, so a receiver knows how to interpret it
+// before looking at the body:
+//
+//	{"type":"text","body":{"text":"hello"}}
+//
+// A Registry maps content types to the Go types that hold them. Marshal and
+// Unmarshal convert between Content values and payload bytes, and Seal and
+// Open combine them with the seal package. Content for the ratchet,
+// senderkey and mls packages is passed to their Encrypt and returned from
+// their Decrypt as the bytes Marshal produces.
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownContentType is returned when a payload names a content type
+	// that is not registered. Use ParsePayload to handle such payloads, for
+	// example to show that a newer client is needed.
+	ErrUnknownContentType = errors.New("unknown content type")
+	// ErrMalformed is returned when payload bytes cannot be decoded.
+	ErrMalformed = errors.New("malformed payload")
+	// ErrInvalidContent is returned when content fails its own validation.
+	ErrInvalidContent = errors.New("invalid content")
+)
+
+// ContentType identifies the kind of content in a payload. Types are short
+// lowercase names; applications should prefix their own, for example
+// "acme.poll".
+type ContentType string
+
+// Content is a value that can be carried in a payload. If it also has a
+// Validate() error method, Marshal and Unmarshal call it.
+type Content interface {
+	ContentType() ContentType
+}
+
+type validator interface {
+	Validate() error
+}
+
+// Payload is the decoded outer layer of a plaintext.
+type Payload struct {
+	Type ContentType     `json:"type"`
+	Body json.RawMessage `json:"body"`
+}
+
+// ParsePayload decodes the outer layer of a plaintext without interpreting
+// its body.
+func ParsePayload(data []byte) (*Payload, error) {
+	var p Payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	if p.Type == "" {
+		return nil, fmt.Errorf("%w: content type is missing", ErrMalformed)
+	}
+	return &p, nil
+}
+
+// Marshal encodes c as a payload using DefaultRegistry.
+func Marshal(c Content) ([]byte, error) {
+	return DefaultRegistry.Marshal(c)
+}
+
+// Unmarshal decodes a payload using DefaultRegistry.
+func Unmarshal(data []byte) (Content, error) {
+	return DefaultRegistry.Unmarshal(data)
+}
+
+func validate(c Content) error {
+	v, ok := c.(validator)
+	if !ok {
+		return nil
+	}
+	if err := v.Validate(); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidContent, c.ContentType(), err)
+	}
+	return nil
+}
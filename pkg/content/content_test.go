@@ -0,0 +1,100 @@
+package content_test
+
+import (
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/content"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, s string) urn.URN {
+	t.Helper()
+	u, err := urn.Parse(s)
+	require.NoError(t, err)
+	return u
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	alice := mustParse(t, "urn:sm:user:alice")
+
+	testCases := []struct {
+		name    string
+		content content.Content
+		want    content.Content
+	}{
+		{name: "Text", content: content.Text{Text: "hello"}, want: &content.Text{Text: "hello"}},
+		{name: "Text Pointer", content: &content.Text{Text: "hello"}, want: &content.Text{Text: "hello"}},
+		{
+			name:    "Reaction",
+			content: content.Reaction{Target: content.MessageRef{SenderID: alice, MessageID: "msg-1"}, Emoji: "👍"},
+			want:    &content.Reaction{Target: content.MessageRef{SenderID: alice, MessageID: "msg-1"}, Emoji: "👍"},
+		},
+		{
+			name:    "Receipt",
+			content: content.Receipt{Kind: content.ReceiptRead, SenderID: alice, MessageIDs: []string{"msg-1", "msg-2"}},
+			want:    &content.Receipt{Kind: content.ReceiptRead, SenderID: alice, MessageIDs: []string{"msg-1", "msg-2"}},
+		},
+		{name: "Typing", content: content.Typing{Active: true}, want: &content.Typing{Active: true}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := content.Marshal(tc.content)
+			require.NoError(t, err)
+
+			p, err := content.ParsePayload(data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.content.ContentType(), p.Type)
+
+			got, err := content.Unmarshal(data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("Wire Format", func(t *testing.T) {
+		data, err := content.Marshal(content.Text{Text: "hello"})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type":"text","body":{"text":"hello"}}`, string(data))
+	})
+
+	t.Run("Type Switch", func(t *testing.T) {
+		got, err := content.Unmarshal([]byte(`{"type":"typing","body":{"active":false}}`))
+		require.NoError(t, err)
+		switch c := got.(type) {
+		case *content.Typing:
+			assert.False(t, c.Active)
+		default:
+			t.Fatalf("unexpected content %T", got)
+		}
+	})
+}
+
+func TestUnmarshalFailures(t *testing.T) {
+	testCases := []struct {
+		name          string
+		data          string
+		expectedErrIs error
+	}{
+		{name: "Not JSON", data: "hello", expectedErrIs: content.ErrMalformed},
+		{name: "No Type", data: `{"body":{"text":"hello"}}`, expectedErrIs: content.ErrMalformed},
+		{name: "No Body", data: `{"type":"text"}`, expectedErrIs: content.ErrMalformed},
+		{name: "Wrong Body Shape", data: `{"type":"text","body":{"text":1}}`, expectedErrIs: content.ErrMalformed},
+		{name: "Unknown Type", data: `{"type":"poll","body":{}}`, expectedErrIs: content.ErrUnknownContentType},
+		{name: "Invalid Content", data: `{"type":"text","body":{"text":""}}`, expectedErrIs: content.ErrInvalidContent},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := content.Unmarshal([]byte(tc.data))
+			assert.ErrorIs(t, err, tc.expectedErrIs)
+		})
+	}
+
+	t.Run("Unknown Type Is Still Parseable", func(t *testing.T) {
+		p, err := content.ParsePayload([]byte(`{"type":"poll","body":{"question":"when?"}}`))
+		require.NoError(t, err)
+		assert.Equal(t, content.ContentType("poll"), p.Type)
+		assert.JSONEq(t, `{"question":"when?"}`, string(p.Body))
+	})
+}
@@ -0,0 +1,131 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Registry maps content types to constructors for the Go types that hold
+// them. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[ContentType]func() Content
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{types: make(map[ContentType]func() Content)}
+}
+
+// DefaultRegistry holds the built-in content types. Applications may
+// register their own types in it at start-up.
+var DefaultRegistry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.MustRegister(TypeText, func() Content { return &Text{} })
+	r.MustRegister(TypeReaction, func() Content { return &Reaction{} })
+	r.MustRegister(TypeReceipt, func() Content { return &Receipt{} })
+	r.MustRegister(TypeTyping, func() Content { return &Typing{} })
+	return r
+}
+
+// Register adds a content type. newContent must return a new pointer to a
+// value whose ContentType is t; Unmarshal decodes bodies into it with
+// encoding/json.
+func (r *Registry) Register(t ContentType, newContent func() Content) error {
+	if t == "" {
+		return errors.New("content type must not be empty")
+	}
+	if newContent == nil {
+		return fmt.Errorf("content type %s has no constructor", t)
+	}
+	if got := newContent().ContentType(); got != t {
+		return fmt.Errorf("constructor for content type %s returns %s", t, got)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.types[t]; exists {
+		return fmt.Errorf("content type %s is already registered", t)
+	}
+	r.types[t] = newContent
+	return nil
+}
+
+// MustRegister is like Register but panics on error.
+func (r *Registry) MustRegister(t ContentType, newContent func() Content) {
+	if err := r.Register(t, newContent); err != nil {
+		panic(err)
+	}
+}
+
+// IsRegistered reports whether t has been registered.
+func (r *Registry) IsRegistered(t ContentType) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.types[t]
+	return ok
+}
+
+// Types returns the registered content types in sorted order.
+func (r *Registry) Types() []ContentType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]ContentType, 0, len(r.types))
+	for t := range r.types {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Marshal validates c and encodes it as a payload. Its content type must be
+// registered, so that receivers using the same registry can decode it.
+func (r *Registry) Marshal(c Content) ([]byte, error) {
+	if c == nil {
+		return nil, errors.New("cannot marshal nil content")
+	}
+	t := c.ContentType()
+	if !r.IsRegistered(t) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownContentType, t)
+	}
+	if err := validate(c); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s content: %w", t, err)
+	}
+	return json.Marshal(Payload{Type: t, Body: body})
+}
+
+// Unmarshal decodes a payload into a new value of its registered type and
+// validates it. The result is the pointer returned by the type's
+// constructor, for example *Text.
+func (r *Registry) Unmarshal(data []byte) (Content, error) {
+	p, err := ParsePayload(data)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	newContent, ok := r.types[p.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownContentType, p.Type)
+	}
+
+	c := newContent()
+	if len(p.Body) == 0 {
+		return nil, fmt.Errorf("%w: %s payload has no body", ErrMalformed, p.Type)
+	}
+	if err := json.Unmarshal(p.Body, c); err != nil {
+		return nil, fmt.Errorf("%w: %s body: %w", ErrMalformed, p.Type, err)
+	}
+	if err := validate(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,57 @@
+package content_test
+
+import (
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const typePoll content.ContentType = "acme.poll"
+
+type poll struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+}
+
+func (poll) ContentType() content.ContentType { return typePoll }
+
+func TestRegistry(t *testing.T) {
+	assert.Equal(t, []content.ContentType{
+		content.TypeReaction, content.TypeReceipt, content.TypeText, content.TypeTyping,
+	}, content.DefaultRegistry.Types())
+
+	r := content.NewRegistry()
+	require.NoError(t, r.Register(typePoll, func() content.Content { return &poll{} }))
+	assert.True(t, r.IsRegistered(typePoll))
+	assert.False(t, r.IsRegistered(content.TypeText))
+
+	t.Run("Custom Type Round-trip", func(t *testing.T) {
+		in := poll{Question: "when?", Options: []string{"mon", "tue"}}
+		data, err := r.Marshal(in)
+		require.NoError(t, err)
+		out, err := r.Unmarshal(data)
+		require.NoError(t, err)
+		assert.Equal(t, &in, out)
+
+		// A registry without the type cannot decode it.
+		_, err = content.Unmarshal(data)
+		assert.ErrorIs(t, err, content.ErrUnknownContentType)
+	})
+
+	t.Run("Unregistered Types Are Not Marshaled", func(t *testing.T) {
+		_, err := r.Marshal(content.Text{Text: "hi"})
+		assert.ErrorIs(t, err, content.ErrUnknownContentType)
+		_, err = r.Marshal(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Register Failures", func(t *testing.T) {
+		assert.Error(t, r.Register(typePoll, func() content.Content { return &poll{} }), "duplicate")
+		assert.Error(t, r.Register("", func() content.Content { return &poll{} }), "empty type")
+		assert.Error(t, r.Register("other", nil), "nil constructor")
+		assert.Error(t, r.Register("other", func() content.Content { return &poll{} }), "mismatched type")
+		assert.Panics(t, func() { r.MustRegister(typePoll, func() content.Content { return &poll{} }) })
+	})
+}
@@ -0,0 +1,46 @@
+package content
+
+import (
+	"crypto/ecdh"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/seal"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// Seal marshals c and seals it for the holder of recipientPublicKey with
+// seal.Seal.
+func Seal(c Content, sender urn.URN, recipientPublicKey *ecdh.PublicKey, opts ...seal.Option) (*transport.SecureEnvelope, error) {
+	plaintext, err := Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return seal.Seal(plaintext, sender, recipientPublicKey, opts...)
+}
+
+// Open opens env with seal.Open and unmarshals its content.
+func Open(env *transport.SecureEnvelope, recipientPrivateKey *ecdh.PrivateKey, opts ...seal.Option) (Content, error) {
+	plaintext, err := seal.Open(env, recipientPrivateKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(plaintext)
+}
+
+// SealGroup marshals c and seals it for every member with seal.SealGroup.
+func SealGroup(c Content, sender, groupID urn.URN, members []seal.Member, opts ...seal.Option) (*transport.SecureEnvelope, error) {
+	plaintext, err := Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return seal.SealGroup(plaintext, sender, groupID, members, opts...)
+}
+
+// OpenGroup opens env with seal.OpenGroup and unmarshals its content.
+func OpenGroup(env *transport.SecureEnvelope, memberID urn.URN, memberPrivateKey *ecdh.PrivateKey, opts ...seal.Option) (Content, error) {
+	plaintext, err := seal.OpenGroup(env, memberID, memberPrivateKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(plaintext)
+}
@@ -0,0 +1,66 @@
+package content_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/content"
+	"github.com/illmade-knight/go-secure-messaging/pkg/seal"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	alice := mustParse(t, "urn:sm:user:alice")
+	bob := mustParse(t, "urn:sm:user:bob")
+	bobKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	env, err := content.Seal(content.Text{Text: "hello, bob"}, alice, bobKey.PublicKey(), seal.WithRecipientID(bob))
+	require.NoError(t, err)
+
+	wire, err := transport.FromProto(transport.ToProto(env))
+	require.NoError(t, err)
+	got, err := content.Open(wire, bobKey)
+	require.NoError(t, err)
+	assert.Equal(t, &content.Text{Text: "hello, bob"}, got)
+
+	t.Run("Invalid Content Is Not Sealed", func(t *testing.T) {
+		_, err := content.Seal(content.Text{}, alice, bobKey.PublicKey())
+		assert.ErrorIs(t, err, content.ErrInvalidContent)
+	})
+
+	t.Run("Raw Plaintext Is Rejected", func(t *testing.T) {
+		env, err := seal.Seal([]byte("not a payload"), alice, bobKey.PublicKey())
+		require.NoError(t, err)
+		_, err = content.Open(env, bobKey)
+		assert.ErrorIs(t, err, content.ErrMalformed)
+	})
+
+	t.Run("Decryption Errors Pass Through", func(t *testing.T) {
+		otherKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		_, err = content.Open(env, otherKey)
+		assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+	})
+}
+
+func TestSealOpenGroup(t *testing.T) {
+	alice := mustParse(t, "urn:sm:user:alice")
+	group := mustParse(t, "urn:sm:group:book-club")
+	conversation := mustParse(t, "urn:sm:conversation:book-club-chat")
+	bob := mustParse(t, "urn:sm:user:bob")
+	bobKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	reaction := content.Reaction{Target: content.MessageRef{SenderID: bob, MessageID: "msg-1"}, Emoji: "❤️"}
+	env, err := content.SealGroup(reaction, alice, group, []seal.Member{{ID: bob, PublicKey: bobKey.PublicKey()}},
+		seal.WithConversationID(conversation))
+	require.NoError(t, err)
+
+	got, err := content.OpenGroup(env, bob, bobKey)
+	require.NoError(t, err)
+	assert.Equal(t, &reaction, got)
+}
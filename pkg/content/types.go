@@ -0,0 +1,113 @@
+package content
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// Built-in content types.
+const (
+	TypeText     ContentType = "text"
+	TypeReaction ContentType = "reaction"
+	TypeReceipt  ContentType = "receipt"
+	TypeTyping   ContentType = "typing"
+)
+
+// Text is a plain text message.
+type Text struct {
+	Text string `json:"text"`
+}
+
+// ContentType implements Content.
+func (Text) ContentType() ContentType { return TypeText }
+
+// Validate checks that the text is not empty.
+func (t Text) Validate() error {
+	if t.Text == "" {
+		return errors.New("text is empty")
+	}
+	return nil
+}
+
+// MessageRef identifies an earlier message by its sender and message ID,
+// the same pair that identifies it for replay detection.
+type MessageRef struct {
+	SenderID  urn.URN `json:"senderId"`
+	MessageID string  `json:"messageId"`
+}
+
+func (m MessageRef) validate() error {
+	if m.SenderID.IsZero() || m.MessageID == "" {
+		return errors.New("message reference needs a sender and message ID")
+	}
+	return nil
+}
+
+// Reaction adds or, if Remove is set, removes an emoji reaction to a
+// message.
+type Reaction struct {
+	Target MessageRef `json:"target"`
+	Emoji  string     `json:"emoji"`
+	Remove bool       `json:"remove,omitempty"`
+}
+
+// ContentType implements Content.
+func (Reaction) ContentType() ContentType { return TypeReaction }
+
+// Validate checks that the reaction names its target and emoji.
+func (r Reaction) Validate() error {
+	if err := r.Target.validate(); err != nil {
+		return err
+	}
+	if r.Emoji == "" {
+		return errors.New("reaction has no emoji")
+	}
+	return nil
+}
+
+// ReceiptKind says what a Receipt acknowledges.
+type ReceiptKind string
+
+// Receipt kinds.
+const (
+	ReceiptDelivered ReceiptKind = "delivered"
+	ReceiptRead      ReceiptKind = "read"
+)
+
+// Receipt acknowledges that messages from one sender were delivered or
+// read. The time of the acknowledgement is the envelope's SentAt.
+type Receipt struct {
+	Kind       ReceiptKind `json:"kind"`
+	SenderID   urn.URN     `json:"senderId"`
+	MessageIDs []string    `json:"messageIds"`
+}
+
+// ContentType implements Content.
+func (Receipt) ContentType() ContentType { return TypeReceipt }
+
+// Validate checks the receipt's kind and that it names at least one message.
+func (r Receipt) Validate() error {
+	if r.Kind != ReceiptDelivered && r.Kind != ReceiptRead {
+		return fmt.Errorf("unknown receipt kind %q", r.Kind)
+	}
+	if r.SenderID.IsZero() || len(r.MessageIDs) == 0 {
+		return errors.New("receipt needs a sender and at least one message ID")
+	}
+	for _, id := range r.MessageIDs {
+		if id == "" {
+			return errors.New("receipt has an empty message ID")
+		}
+	}
+	return nil
+}
+
+// Typing indicates that the sender started or stopped typing. It is
+// ephemeral and should be sent with a short TTL and never stored.
+type Typing struct {
+	Active bool `json:"active"`
+}
+
+// ContentType implements Content.
+func (Typing) ContentType() ContentType { return TypeTyping }
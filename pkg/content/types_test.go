@@ -0,0 +1,43 @@
+package content_test
+
+import (
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/content"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinValidation(t *testing.T) {
+	alice := mustParse(t, "urn:sm:user:alice")
+	target := content.MessageRef{SenderID: alice, MessageID: "msg-1"}
+
+	testCases := []struct {
+		name    string
+		content content.Content
+		valid   bool
+	}{
+		{name: "Text", content: content.Text{Text: "hi"}, valid: true},
+		{name: "Empty Text", content: content.Text{}},
+		{name: "Reaction", content: content.Reaction{Target: target, Emoji: "🎉"}, valid: true},
+		{name: "Reaction Removal", content: content.Reaction{Target: target, Emoji: "🎉", Remove: true}, valid: true},
+		{name: "Reaction Without Emoji", content: content.Reaction{Target: target}},
+		{name: "Reaction Without Target Sender", content: content.Reaction{Target: content.MessageRef{MessageID: "msg-1"}, Emoji: "🎉"}},
+		{name: "Reaction Without Target Message", content: content.Reaction{Target: content.MessageRef{SenderID: alice}, Emoji: "🎉"}},
+		{name: "Delivered Receipt", content: content.Receipt{Kind: content.ReceiptDelivered, SenderID: alice, MessageIDs: []string{"msg-1"}}, valid: true},
+		{name: "Receipt Unknown Kind", content: content.Receipt{Kind: "seen", SenderID: alice, MessageIDs: []string{"msg-1"}}},
+		{name: "Receipt Without Messages", content: content.Receipt{Kind: content.ReceiptRead, SenderID: alice}},
+		{name: "Receipt Empty Message ID", content: content.Receipt{Kind: content.ReceiptRead, SenderID: alice, MessageIDs: []string{""}}},
+		{name: "Receipt Without Sender", content: content.Receipt{Kind: content.ReceiptRead, MessageIDs: []string{"msg-1"}}},
+		{name: "Typing Stopped", content: content.Typing{}, valid: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := content.Marshal(tc.content)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, content.ErrInvalidContent)
+			}
+		})
+	}
+}
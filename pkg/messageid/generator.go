@@ -0,0 +1,116 @@
+package messageid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+const maxTimestamp = 1<<48 - 1
+
+// GeneratorOption configures a Generator.
+type GeneratorOption func(*Generator)
+
+// WithClock sets the function the Generator reads the current time from.
+// The default is time.Now.
+func WithClock(now func() time.Time) GeneratorOption {
+	return func(g *Generator) { g.now = now }
+}
+
+// WithRandom sets the source of random bits. The default is crypto/rand.
+func WithRandom(r io.Reader) GeneratorOption {
+	return func(g *Generator) { g.rand = r }
+}
+
+// Generator produces strictly increasing IDs. Within a millisecond, and if
+// the clock goes backwards, each ID is the previous one plus one in its
+// random bits; if those overflow, the timestamp is advanced. It is safe for
+// concurrent use.
+type Generator struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	rand io.Reader
+	last ID
+}
+
+// NewGenerator creates a Generator.
+func NewGenerator(opts ...GeneratorOption) *Generator {
+	g := &Generator{now: time.Now, rand: rand.Reader}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+var defaultGenerator = NewGenerator()
+
+// New returns a new ID from the package's default Generator.
+func New() (ID, error) {
+	return defaultGenerator.New()
+}
+
+// NewString returns the string form of a new ID from the default Generator.
+// It is a convenience for filling SecureEnvelope.MessageID.
+func NewString() (string, error) {
+	id, err := New()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// New returns an ID greater than every ID the Generator returned before.
+func (g *Generator) New() (ID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().UnixMilli()
+	if ms < 0 || ms > maxTimestamp {
+		ms = 0
+	}
+	if !g.last.IsZero() && uint64(ms) <= timestamp(g.last) {
+		if id, ok := increment(g.last); ok {
+			g.last = id
+			return id, nil
+		}
+		ms = int64(timestamp(g.last)) + 1
+	}
+
+	var id ID
+	if _, err := io.ReadFull(g.rand, id[6:]); err != nil {
+		return ID{}, err
+	}
+	putTimestamp(&id, uint64(ms))
+	id[6] = version<<4 | id[6]&0x0f
+	id[8] = variant<<6 | id[8]&0x3f
+	g.last = id
+	return id, nil
+}
+
+// increment adds one to the 74 counter bits of id, reporting false if they
+// overflow.
+func increment(id ID) (ID, bool) {
+	// rand_b: the low 62 bits of bytes 8-15.
+	lo := binary.BigEndian.Uint64(id[8:]) & (1<<62 - 1)
+	// rand_a: the low 12 bits of bytes 6-7.
+	hi := binary.BigEndian.Uint16(id[6:]) & 0x0fff
+	lo++
+	if lo == 1<<62 {
+		lo = 0
+		hi++
+		if hi == 1<<12 {
+			return ID{}, false
+		}
+	}
+	binary.BigEndian.PutUint16(id[6:], version<<12|hi)
+	binary.BigEndian.PutUint64(id[8:], variant<<62|lo)
+	return id, true
+}
+
+func putTimestamp(id *ID, ms uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], ms)
+	copy(id[:6], b[2:])
+}
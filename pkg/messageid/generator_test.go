@@ -0,0 +1,121 @@
+package messageid_test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/messageid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestGenerator(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Embeds Time", func(t *testing.T) {
+		g := messageid.NewGenerator(messageid.WithClock(func() time.Time { return start.Add(1500 * time.Microsecond) }))
+		id, err := g.New()
+		require.NoError(t, err)
+		require.NoError(t, id.Validate())
+		assert.Equal(t, start.Add(time.Millisecond), id.Time())
+
+		parsed, err := messageid.Parse(id.String())
+		require.NoError(t, err)
+		assert.Equal(t, id, parsed)
+	})
+
+	t.Run("Monotonic", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		g := messageid.NewGenerator(messageid.WithClock(clock.Now))
+		var last messageid.ID
+		for i, step := range []time.Duration{0, 0, 0, time.Millisecond, -time.Second, 0, time.Minute} {
+			clock.now = clock.now.Add(step)
+			id, err := g.New()
+			require.NoError(t, err)
+			require.NoError(t, id.Validate())
+			assert.Equal(t, 1, id.Compare(last), "ID %d must sort after the previous one", i)
+			assert.Greater(t, id.String(), last.String())
+			last = id
+		}
+		assert.Equal(t, start.Add(time.Minute-time.Second+time.Millisecond), last.Time())
+	})
+
+	t.Run("Same Millisecond Increments", func(t *testing.T) {
+		g := messageid.NewGenerator(
+			messageid.WithClock(func() time.Time { return start }),
+			messageid.WithRandom(bytes.NewReader(make([]byte, 10))),
+		)
+		a, err := g.New()
+		require.NoError(t, err)
+		b, err := g.New()
+		require.NoError(t, err)
+		assert.Equal(t, "01972b5c-ee00-7000-8000-000000000000", a.String())
+		assert.Equal(t, "01972b5c-ee00-7000-8000-000000000001", b.String())
+	})
+
+	t.Run("Counter Overflow Advances Time", func(t *testing.T) {
+		g := messageid.NewGenerator(
+			messageid.WithClock(func() time.Time { return start }),
+			messageid.WithRandom(bytes.NewReader(bytes.Repeat([]byte{0xff}, 20))),
+		)
+		a, err := g.New()
+		require.NoError(t, err)
+		assert.Equal(t, "01972b5c-ee00-7fff-bfff-ffffffffffff", a.String())
+
+		b, err := g.New()
+		require.NoError(t, err)
+		assert.Equal(t, 1, b.Compare(a))
+		assert.Equal(t, start.Add(time.Millisecond), b.Time())
+	})
+
+	t.Run("Random Source Error", func(t *testing.T) {
+		g := messageid.NewGenerator(messageid.WithRandom(bytes.NewReader(nil)))
+		_, err := g.New()
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, messageid.ErrInvalid))
+	})
+}
+
+func TestNewConcurrent(t *testing.T) {
+	const workers, perWorker = 8, 500
+	ids := make(chan messageid.ID, workers*perWorker)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				id, err := messageid.New()
+				assert.NoError(t, err)
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[messageid.ID]struct{})
+	for id := range ids {
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, workers*perWorker)
+}
+
+func TestNewString(t *testing.T) {
+	a, err := messageid.NewString()
+	require.NoError(t, err)
+	b, err := messageid.NewString()
+	require.NoError(t, err)
+	assert.Len(t, a, messageid.StringLength)
+	assert.NoError(t, messageid.Validate(a))
+	assert.Less(t, a, b)
+}
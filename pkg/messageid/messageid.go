@@ -0,0 +1,153 @@
+// Package messageid generates and parses envelope message IDs.
+//
+// Message IDs are UUIDv7 (RFC 9562): a 48-bit Unix timestamp in
+// milliseconds followed by 74 random bits, written in the canonical
+// 8-4-4-4-12 hexadecimal form. They sort by creation time both as bytes and
+// as strings, and IDs from one Generator are strictly increasing even
+// within a millisecond. An ID can also be written as a
+// urn:sm:message:<id> URN.
+package messageid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+const (
+	// Size is the length of an ID in bytes.
+	Size = 16
+	// StringLength is the length of an ID's canonical string form.
+	StringLength = 36
+
+	version = 7
+	variant = 0b10
+)
+
+// ErrInvalid is returned when a string or URN is not a valid message ID.
+var ErrInvalid = errors.New("invalid message ID")
+
+// ID is a UUIDv7 message ID. The zero ID is not valid.
+type ID [Size]byte
+
+// Parse parses the canonical form of a UUIDv7, in either case.
+func Parse(s string) (ID, error) {
+	var id ID
+	if len(s) != StringLength {
+		return ID{}, fmt.Errorf("%w: must be %d characters, got %d", ErrInvalid, StringLength, len(s))
+	}
+	if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return ID{}, fmt.Errorf("%w: %q is not in 8-4-4-4-12 form", ErrInvalid, s)
+	}
+	hexDigits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(id[:], []byte(hexDigits)); err != nil {
+		return ID{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if err := id.Validate(); err != nil {
+		return ID{}, err
+	}
+	return id, nil
+}
+
+// MustParse is like Parse but panics on error. It is intended for tests and
+// constants.
+func MustParse(s string) ID {
+	id, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Validate reports whether s is a valid message ID. Its signature matches
+// urn.IDValidator, so it can be registered for the message entity type.
+func Validate(s string) error {
+	_, err := Parse(s)
+	return err
+}
+
+// FromURN extracts the ID from a urn:sm:message:<id> URN.
+func FromURN(u urn.URN) (ID, error) {
+	if u.EntityType() != urn.EntityTypeMessage || u.Namespace() != urn.SecureMessaging || !u.Parent().IsZero() {
+		return ID{}, fmt.Errorf("%w: %s is not a message URN", ErrInvalid, u)
+	}
+	return Parse(u.EntityID())
+}
+
+// Validate checks the ID's version and variant bits.
+func (id ID) Validate() error {
+	if id[6]>>4 != version {
+		return fmt.Errorf("%w: version %d, expected %d", ErrInvalid, id[6]>>4, version)
+	}
+	if id[8]>>6 != variant {
+		return fmt.Errorf("%w: not an RFC 9562 variant", ErrInvalid)
+	}
+	return nil
+}
+
+// IsZero reports whether id is the zero ID.
+func (id ID) IsZero() bool {
+	return id == ID{}
+}
+
+// Time returns the millisecond timestamp embedded in the ID.
+func (id ID) Time() time.Time {
+	return time.UnixMilli(int64(timestamp(id))).UTC()
+}
+
+// String returns the canonical lowercase 8-4-4-4-12 form.
+func (id ID) String() string {
+	var b [StringLength]byte
+	hex.Encode(b[0:8], id[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], id[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], id[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], id[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], id[10:])
+	return string(b[:])
+}
+
+// URN returns the ID as a urn:sm:message:<id> URN.
+func (id ID) URN() urn.URN {
+	u, err := urn.New(urn.SecureMessaging, urn.EntityTypeMessage, id.String())
+	if err != nil {
+		// The canonical form is always a valid entity ID.
+		panic(err)
+	}
+	return u
+}
+
+// Compare returns -1, 0 or +1 as id sorts before, equal to or after other,
+// which is the order the IDs were generated in.
+func (id ID) Compare(other ID) int {
+	return bytes.Compare(id[:], other[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+func timestamp(id ID) uint64 {
+	var b [8]byte
+	copy(b[2:], id[:6])
+	return binary.BigEndian.Uint64(b[:])
+}
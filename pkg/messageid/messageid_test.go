@@ -0,0 +1,110 @@
+package messageid_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/messageid"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const example = "017f22e2-79b0-7cc3-98c4-dc0c0c07398f"
+
+func TestParse(t *testing.T) {
+	id, err := messageid.Parse(example)
+	require.NoError(t, err)
+	assert.Equal(t, example, id.String())
+	assert.Equal(t, time.Date(2022, 2, 22, 19, 22, 22, 0, time.UTC), id.Time())
+	assert.False(t, id.IsZero())
+
+	upper, err := messageid.Parse("017F22E2-79B0-7CC3-98C4-DC0C0C07398F")
+	require.NoError(t, err)
+	assert.Equal(t, id, upper)
+
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{name: "Empty", input: ""},
+		{name: "Too Short", input: example[:35]},
+		{name: "No Dashes", input: "017f22e279b07cc398c4dc0c0c07398f0000"},
+		{name: "Misplaced Dash", input: "017f22e-279b0-7cc3-98c4-dc0c0c07398f"},
+		{name: "Not Hex", input: "017f22e2-79b0-7cc3-98c4-dc0c0c07398g"},
+		{name: "Version 4", input: "017f22e2-79b0-4cc3-98c4-dc0c0c07398f"},
+		{name: "Wrong Variant", input: "017f22e2-79b0-7cc3-c8c4-dc0c0c07398f"},
+		{name: "Zero", input: "00000000-0000-0000-0000-000000000000"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := messageid.Parse(tc.input)
+			assert.ErrorIs(t, err, messageid.ErrInvalid)
+			assert.ErrorIs(t, messageid.Validate(tc.input), messageid.ErrInvalid)
+		})
+	}
+}
+
+func TestMustParse(t *testing.T) {
+	assert.Equal(t, example, messageid.MustParse(example).String())
+	assert.Panics(t, func() { messageid.MustParse("msg-123") })
+}
+
+func TestURN(t *testing.T) {
+	id := messageid.MustParse(example)
+	u := id.URN()
+	assert.Equal(t, "urn:sm:message:"+example, u.String())
+
+	parsed, err := messageid.FromURN(u)
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	for _, s := range []string{
+		"urn:sm:user:" + example,
+		"urn:other:message:" + example,
+		"urn:sm:message:msg-123",
+		"urn:sm:conversation:c1/message:" + example,
+	} {
+		u, err := urn.Parse(s)
+		require.NoError(t, err, s)
+		_, err = messageid.FromURN(u)
+		assert.ErrorIs(t, err, messageid.ErrInvalid, s)
+	}
+}
+
+func TestRegistryValidator(t *testing.T) {
+	r := urn.NewRegistry()
+	r.MustRegister(urn.EntityTypeMessage, messageid.Validate)
+
+	_, err := r.New(urn.SecureMessaging, urn.EntityTypeMessage, example)
+	assert.NoError(t, err)
+	_, err = r.New(urn.SecureMessaging, urn.EntityTypeMessage, "msg-123")
+	assert.ErrorIs(t, err, urn.ErrInvalidEntityID)
+	assert.ErrorIs(t, err, messageid.ErrInvalid)
+}
+
+func TestTextMarshaling(t *testing.T) {
+	type record struct {
+		ID messageid.ID `json:"id"`
+	}
+	in := record{ID: messageid.MustParse(example)}
+	b, err := json.Marshal(in)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"`+example+`"}`, string(b))
+
+	var out record
+	require.NoError(t, json.Unmarshal(b, &out))
+	assert.Equal(t, in, out)
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"id":"msg-123"}`), &out), messageid.ErrInvalid)
+}
+
+func TestCompare(t *testing.T) {
+	a := messageid.MustParse("017f22e2-79b0-7cc3-98c4-dc0c0c07398f")
+	b := messageid.MustParse("017f22e2-79b1-7000-8000-000000000000")
+	assert.Equal(t, -1, a.Compare(b))
+	assert.Equal(t, 1, b.Compare(a))
+	assert.Equal(t, 0, a.Compare(a))
+	assert.Less(t, a.String(), b.String(), "string order must match byte order")
+}
@@ -0,0 +1,355 @@
+package mls
+
+import (
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// Commit moves a group from Epoch to the next epoch. It is created by one
+// member with Group.Commit, sent to every other member through the delivery
+// service and applied with Group.Process.
+type Commit struct {
+	GroupID urn.URN `json:"groupId"`
+	Epoch   uint64  `json:"epoch"`
+	// Sender is the committer's leaf index.
+	Sender uint32 `json:"sender"`
+	// Adds are the key packages of the members being added.
+	Adds []*KeyPackage `json:"adds,omitempty"`
+	// Removes are the leaf indices of the members being removed.
+	Removes []uint32   `json:"removes,omitempty"`
+	Path    UpdatePath `json:"path"`
+	// Signature is made with the committer's leaf signing key over all of
+	// the above.
+	Signature []byte `json:"signature"`
+	// ConfirmationTag is a MAC over the new transcript with a key from the
+	// new epoch, proving the committer derived the same secrets.
+	ConfirmationTag []byte `json:"confirmationTag"`
+}
+
+// UpdatePath replaces the committer's leaf and the filtered direct path
+// from it to the root. Each node's path secret is encrypted to every node
+// in the resolution of its child off the path, other than newly added
+// members, which receive theirs in the Welcome.
+type UpdatePath struct {
+	Leaf  LeafNode         `json:"leaf"`
+	Nodes []UpdatePathNode `json:"nodes"`
+}
+
+// UpdatePathNode is the new public key of one node on an UpdatePath and its
+// path secret encrypted to the nodes below it.
+type UpdatePathNode struct {
+	EncryptionKey       []byte           `json:"encryptionKey"`
+	EncryptedPathSecret []HPKECiphertext `json:"encryptedPathSecret"`
+}
+
+func (c *Commit) content() []byte {
+	b := appendField(nil, []byte(c.GroupID.String()))
+	b = binary.BigEndian.AppendUint64(b, c.Epoch)
+	b = binary.BigEndian.AppendUint32(b, c.Sender)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(c.Adds)))
+	for _, kp := range c.Adds {
+		b = appendField(b, kp.tbs())
+		b = appendField(b, kp.Signature)
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(c.Removes)))
+	for _, r := range c.Removes {
+		b = binary.BigEndian.AppendUint32(b, r)
+	}
+	b = appendField(b, c.Path.Leaf.tbs())
+	b = appendField(b, c.Path.Leaf.Signature)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(c.Path.Nodes)))
+	for _, n := range c.Path.Nodes {
+		b = appendField(b, n.EncryptionKey)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(n.EncryptedPathSecret)))
+		for _, ct := range n.EncryptedPathSecret {
+			b = appendField(b, ct.KEMOutput)
+			b = appendField(b, ct.Ciphertext)
+		}
+	}
+	return b
+}
+
+// Commit adds and removes members, refreshes this member's leaf and path
+// and moves the group to the next epoch. An empty commit only refreshes
+// keys, which heals the group after a compromise of this member's state.
+//
+// The group applies the commit at once. The Commit must be delivered to
+// every other member, and the Welcome, which is nil if nobody was added, to
+// every added member.
+func (g *Group) Commit(adds []*KeyPackage, removes []urn.URN) (*Commit, *Welcome, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.removed {
+		return nil, nil, ErrRemoved
+	}
+	cur := g.state
+	c := &Commit{GroupID: cur.ctx.groupID, Epoch: cur.ctx.epoch, Sender: g.self, Adds: adds}
+	for _, id := range removes {
+		i, ok := cur.tree.find(id)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNotMember, id)
+		}
+		c.Removes = append(c.Removes, i)
+	}
+
+	tree := cur.tree.clone()
+	added, err := applyProposals(tree, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	leafKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	leaf := *cur.tree.leaf(g.self)
+	leaf.EncryptionKey = leafKey.PublicKey().Bytes()
+	leaf.sign(g.signingKey)
+	tree.nodes[leafNode(g.self)] = Node{Leaf: &leaf}
+	tree.blankPath(g.self)
+
+	steps := tree.filteredDirectPath(g.self)
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, nil, err
+	}
+	pathSecrets, nodeKeys, commitSecret, err := derivePath(secret, len(steps))
+	if err != nil {
+		return nil, nil, err
+	}
+	privs := maps.Clone(cur.privs)
+	privs[leafNode(g.self)] = leafKey
+	for i, st := range steps {
+		tree.nodes[st.node] = Node{Parent: &ParentNode{EncryptionKey: nodeKeys[i].PublicKey().Bytes()}}
+		privs[st.node] = nodeKeys[i]
+	}
+
+	provisional := groupContext{groupID: cur.ctx.groupID, epoch: cur.ctx.epoch + 1, treeHash: tree.hash(), confirmedTranscriptHash: cur.ctx.confirmedTranscriptHash}
+	exclude := make(map[uint32]bool)
+	for _, a := range added {
+		exclude[a] = true
+	}
+	c.Path.Leaf = leaf
+	for i, st := range steps {
+		node := UpdatePathNode{EncryptionKey: nodeKeys[i].PublicKey().Bytes()}
+		for _, x := range tree.resolution(st.copath, exclude) {
+			ct, err := encryptWithLabel(tree.publicKey(x), "UpdatePathNode", provisional.marshal(), pathSecrets[i])
+			if err != nil {
+				return nil, nil, err
+			}
+			node.EncryptedPathSecret = append(node.EncryptedPathSecret, ct)
+		}
+		c.Path.Nodes = append(c.Path.Nodes, node)
+	}
+	c.Signature = signWithLabel(g.signingKey, "Commit", c.content())
+
+	next, joiner := nextEpoch(cur, c, tree, privs, commitSecret)
+	c.ConfirmationTag = next.secrets.confirmationTag(next.ctx.confirmedTranscriptHash)
+	next.interim = interimTranscriptHash(next.ctx.confirmedTranscriptHash, c.ConfirmationTag)
+
+	var welcome *Welcome
+	if len(added) > 0 {
+		welcome, err = newWelcome(next, g.self, g.signingKey, joiner, c.Adds, added, steps, pathSecrets, c.ConfirmationTag)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	g.previous, g.state = cur.app, next
+	return c, welcome, nil
+}
+
+// Process applies another member's commit. If the commit removes this
+// member, Process returns ErrRemoved and the group can no longer be used.
+func (g *Group) Process(c *Commit) error {
+	if c == nil {
+		return errors.New("cannot process a nil commit")
+	}
+	if slices.Contains(c.Adds, nil) {
+		return fmt.Errorf("%w: nil key package", ErrMalformed)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.removed {
+		return ErrRemoved
+	}
+	cur := g.state
+	if !c.GroupID.Equal(cur.ctx.groupID) {
+		return fmt.Errorf("%w: commit is for %s", ErrMalformed, c.GroupID)
+	}
+	if c.Epoch != cur.ctx.epoch {
+		return fmt.Errorf("%w: commit is for epoch %d, group is in %d", ErrWrongEpoch, c.Epoch, cur.ctx.epoch)
+	}
+	sender := cur.tree.leaf(c.Sender)
+	if sender == nil {
+		return fmt.Errorf("%w: no member at leaf %d", ErrNotMember, c.Sender)
+	}
+	if c.Sender == g.self {
+		return fmt.Errorf("%w: commit is our own and was applied when created", ErrInvalidCommit)
+	}
+	if err := verifyWithLabel(sender.SigningKey, "Commit", c.content(), c.Signature); err != nil {
+		return err
+	}
+
+	tree := cur.tree.clone()
+	added, err := applyProposals(tree, c)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(c.Removes, g.self) {
+		g.removed = true
+		return ErrRemoved
+	}
+
+	leaf := c.Path.Leaf
+	if err := leaf.Verify(); err != nil {
+		return fmt.Errorf("commit leaf: %w", err)
+	}
+	if !leaf.Identity.Equal(sender.Identity) {
+		return fmt.Errorf("%w: committer's leaf changed identity", ErrInvalidCommit)
+	}
+	tree.nodes[leafNode(c.Sender)] = Node{Leaf: &leaf}
+	tree.blankPath(c.Sender)
+	steps := tree.filteredDirectPath(c.Sender)
+	if len(steps) != len(c.Path.Nodes) {
+		return fmt.Errorf("%w: path has %d nodes, expected %d", ErrInvalidCommit, len(c.Path.Nodes), len(steps))
+	}
+	for i, st := range steps {
+		if len(c.Path.Nodes[i].EncryptionKey) != secretSize {
+			return fmt.Errorf("%w: path node %d has an invalid key", ErrMalformed, i)
+		}
+		tree.nodes[st.node] = Node{Parent: &ParentNode{EncryptionKey: c.Path.Nodes[i].EncryptionKey}}
+	}
+
+	provisional := groupContext{groupID: cur.ctx.groupID, epoch: cur.ctx.epoch + 1, treeHash: tree.hash(), confirmedTranscriptHash: cur.ctx.confirmedTranscriptHash}
+	exclude := make(map[uint32]bool)
+	for _, a := range added {
+		exclude[a] = true
+	}
+	i := slices.IndexFunc(steps, func(st pathStep) bool { return inSubtree(st.copath, leafNode(g.self)) })
+	if i < 0 {
+		return fmt.Errorf("%w: path does not cover this member", ErrInvalidCommit)
+	}
+	res := tree.resolution(steps[i].copath, exclude)
+	cts := c.Path.Nodes[i].EncryptedPathSecret
+	if len(cts) != len(res) {
+		return fmt.Errorf("%w: path node %d has %d ciphertexts, expected %d", ErrInvalidCommit, i, len(cts), len(res))
+	}
+	var secret []byte
+	for j, x := range res {
+		if priv, ok := cur.privs[x]; ok && string(priv.PublicKey().Bytes()) == string(tree.publicKey(x)) {
+			if secret, err = decryptWithLabel(priv, "UpdatePathNode", provisional.marshal(), cts[j]); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	if secret == nil {
+		return fmt.Errorf("%w: no key to decrypt the path secret", ErrInvalidCommit)
+	}
+
+	privs := maps.Clone(cur.privs)
+	commitSecret, err := applyPathSecret(tree, steps[i:], secret, privs)
+	if err != nil {
+		return err
+	}
+
+	next, _ := nextEpoch(cur, c, tree, privs, commitSecret)
+	if !hmac.Equal(next.secrets.confirmationTag(next.ctx.confirmedTranscriptHash), c.ConfirmationTag) {
+		return fmt.Errorf("%w: confirmation tag mismatch", ErrInvalidCommit)
+	}
+	next.interim = interimTranscriptHash(next.ctx.confirmedTranscriptHash, c.ConfirmationTag)
+	g.previous, g.state = cur.app, next
+	return nil
+}
+
+// applyProposals removes and then adds the members named by c and returns
+// the leaf indices of the added members.
+func applyProposals(tree *ratchetTree, c *Commit) ([]uint32, error) {
+	for _, r := range c.Removes {
+		if tree.leaf(r) == nil {
+			return nil, fmt.Errorf("%w: no member at leaf %d", ErrNotMember, r)
+		}
+		if r == c.Sender {
+			return nil, fmt.Errorf("%w: a member cannot remove themselves", ErrInvalidCommit)
+		}
+		tree.removeLeaf(r)
+	}
+	tree.truncate()
+
+	added := make([]uint32, 0, len(c.Adds))
+	for _, kp := range c.Adds {
+		if err := kp.Verify(); err != nil {
+			return nil, fmt.Errorf("key package: %w", err)
+		}
+		if _, ok := tree.find(kp.Leaf.Identity); ok {
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyMember, kp.Leaf.Identity)
+		}
+		leaf := kp.Leaf
+		added = append(added, tree.addLeaf(&leaf))
+	}
+	return added, nil
+}
+
+// derivePath expands a path secret up n nodes, returning each node's path
+// secret and key pair and the resulting commit secret.
+func derivePath(secret []byte, n int) ([][]byte, []*ecdh.PrivateKey, []byte, error) {
+	secrets := make([][]byte, n)
+	keys := make([]*ecdh.PrivateKey, n)
+	for i := range n {
+		priv, err := ecdh.X25519().NewPrivateKey(deriveSecret(secret, "node"))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		secrets[i], keys[i] = secret, priv
+		secret = deriveSecret(secret, "path")
+	}
+	return secrets, keys, secret, nil
+}
+
+// applyPathSecret derives the keys for steps from the path secret of the
+// first, checks them against the tree and records them in privs. It
+// returns the commit secret.
+func applyPathSecret(tree *ratchetTree, steps []pathStep, secret []byte, privs map[uint32]*ecdh.PrivateKey) ([]byte, error) {
+	_, keys, commitSecret, err := derivePath(secret, len(steps))
+	if err != nil {
+		return nil, err
+	}
+	for i, st := range steps {
+		if string(keys[i].PublicKey().Bytes()) != string(tree.publicKey(st.node)) {
+			return nil, fmt.Errorf("%w: path secret does not match node %d", ErrInvalidCommit, st.node)
+		}
+		privs[st.node] = keys[i]
+	}
+	return commitSecret, nil
+}
+
+// nextEpoch runs the key schedule for the epoch that c starts and returns
+// its state, without the interim transcript hash, and the joiner secret.
+func nextEpoch(cur *epochState, c *Commit, tree *ratchetTree, privs map[uint32]*ecdh.PrivateKey, commitSecret []byte) (*epochState, []byte) {
+	ctx := groupContext{
+		groupID:                 cur.ctx.groupID,
+		epoch:                   cur.ctx.epoch + 1,
+		treeHash:                tree.hash(),
+		confirmedTranscriptHash: confirmedTranscriptHash(cur.interim, c.content(), c.Signature),
+	}
+	joiner := joinerSecret(cur.secrets.init, commitSecret, ctx)
+	secrets := newEpochSecrets(joiner, ctx)
+	next := &epochState{
+		ctx:     ctx,
+		tree:    tree,
+		privs:   privs,
+		secrets: secrets,
+		app:     newAppKeys(ctx.epoch, secrets.encryption, tree),
+	}
+	next.prunePrivs()
+	return next, joiner
+}
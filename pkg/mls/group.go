@@ -0,0 +1,172 @@
+package mls
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/groupenv"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// DefaultMaxSkip is the default limit on how far ahead of the last received
+// message a sender's chain may be advanced in one step, and on the number of
+// skipped message keys kept per sender.
+const DefaultMaxSkip = 1000
+
+// Option configures a Group or a single Encrypt call.
+type Option = groupenv.Option
+
+// WithMessageID sets the envelope's MessageID. If it is not given, Encrypt
+// generates a time-ordered one with messageid.New.
+func WithMessageID(id string) Option {
+	return groupenv.WithMessageID(id)
+}
+
+// WithConversationID sets the envelope's ConversationID. Group envelopes
+// need one to pass Validate.
+func WithConversationID(id urn.URN) Option {
+	return groupenv.WithConversationID(id)
+}
+
+// WithSentAt sets the envelope's SentAt, which Encrypt signs. Without it,
+// SentAt is only set if WithTTL is given.
+func WithSentAt(t time.Time) Option {
+	return groupenv.WithSentAt(t)
+}
+
+// WithTTL sets the envelope's ExpiresAt to ttl after its SentAt, which
+// defaults to the current time.
+func WithTTL(ttl time.Duration) Option {
+	return groupenv.WithTTL(ttl)
+}
+
+// WithMaxSkip sets the group's skip limit when passed to CreateGroup or
+// Join. The default is DefaultMaxSkip.
+func WithMaxSkip(n int) Option {
+	return groupenv.WithMaxSkip(n)
+}
+
+// epochState is everything a member knows about one epoch. Commits build a
+// new epochState and only replace the current one once fully verified.
+type epochState struct {
+	ctx     groupContext
+	tree    *ratchetTree
+	privs   map[uint32]*ecdh.PrivateKey
+	secrets *epochSecrets
+	interim []byte
+	app     *appKeys
+}
+
+// prunePrivs forgets private keys for nodes that are blank or have been
+// replaced.
+func (s *epochState) prunePrivs() {
+	for x, priv := range s.privs {
+		if x >= uint32(len(s.tree.nodes)) || string(s.tree.publicKey(x)) != string(priv.PublicKey().Bytes()) {
+			delete(s.privs, x)
+		}
+	}
+}
+
+// Group is one member's state for an MLS group. It is safe for concurrent
+// use.
+type Group struct {
+	mu         sync.Mutex
+	maxSkip    int
+	self       uint32
+	signingKey ed25519.PrivateKey
+	removed    bool
+	state      *epochState
+	// previous keeps the last epoch's message keys so that messages sent
+	// just before a commit can still be read.
+	previous *appKeys
+}
+
+// CreateGroup starts a group with the key package's owner as its only
+// member, in epoch 0.
+func CreateGroup(groupID urn.URN, kp *KeyPackage, keys *KeyPackageKeys, opts ...Option) (*Group, error) {
+	o := groupenv.NewOptions(opts, DefaultMaxSkip)
+	if groupID.IsZero() {
+		return nil, errors.New("group ID is required")
+	}
+	if err := kp.Verify(); err != nil {
+		return nil, err
+	}
+	if !keys.matches(kp) {
+		return nil, errors.New("keys do not match the key package")
+	}
+
+	leaf := kp.Leaf
+	tree := newTree(&leaf)
+	ctx := groupContext{groupID: groupID, treeHash: tree.hash()}
+	joiner := make([]byte, secretSize)
+	if _, err := rand.Read(joiner); err != nil {
+		return nil, err
+	}
+	secrets := newEpochSecrets(joiner, ctx)
+	state := &epochState{
+		ctx:     ctx,
+		tree:    tree,
+		privs:   map[uint32]*ecdh.PrivateKey{0: keys.EncryptionKey},
+		secrets: secrets,
+		interim: interimTranscriptHash(nil, secrets.confirmationTag(nil)),
+		app:     newAppKeys(0, secrets.encryption, tree),
+	}
+	return &Group{maxSkip: o.MaxSkip, signingKey: keys.SigningKey, state: state}, nil
+}
+
+// ID returns the group's URN.
+func (g *Group) ID() urn.URN {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state.ctx.groupID
+}
+
+// Self returns this member's identity.
+func (g *Group) Self() urn.URN {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state.tree.leaf(g.self).Identity
+}
+
+// Epoch returns the current epoch number.
+func (g *Group) Epoch() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state.ctx.epoch
+}
+
+// Members returns the identities of all members, including this one, in
+// leaf order.
+func (g *Group) Members() []urn.URN {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state.tree.members()
+}
+
+// EpochAuthenticator returns a secret that is equal for all members of the
+// current epoch. Members can compare it out of band to detect a split view
+// of the group.
+func (g *Group) EpochAuthenticator() []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]byte(nil), g.state.secrets.authenticator...)
+}
+
+// Export derives a secret of the given length from the current epoch for
+// use outside the group, as in RFC 9420 section 8.5.
+func (g *Group) Export(label string, context []byte, length int) ([]byte, error) {
+	if length <= 0 || length > 255*secretSize {
+		return nil, fmt.Errorf("invalid export length %d", length)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.removed {
+		return nil, ErrRemoved
+	}
+	return expandWithLabel(deriveSecret(g.state.secrets.exporter, label), "exported", hash(context), length), nil
+}
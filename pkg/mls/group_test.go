@@ -0,0 +1,326 @@
+package mls_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/mls"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client is one member device with a published key package and, once it
+// has joined, its group state.
+type client struct {
+	id    urn.URN
+	kp    *mls.KeyPackage
+	keys  *mls.KeyPackageKeys
+	group *mls.Group
+}
+
+func newClient(t *testing.T, name string) *client {
+	t.Helper()
+	id := mustParse(t, "urn:sm:user:"+name)
+	kp, keys, err := mls.NewKeyPackage(id, mustSigningKey(t))
+	require.NoError(t, err)
+	return &client{id: id, kp: kp, keys: keys}
+}
+
+// roundTrip passes v through JSON, as the delivery service would.
+func roundTrip[T any](t *testing.T, v *T) *T {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	out := new(T)
+	require.NoError(t, json.Unmarshal(data, out))
+	return out
+}
+
+// testGroup is a set of clients and a simulated delivery service.
+type testGroup struct {
+	id      urn.URN
+	clients map[string]*client
+}
+
+// newTestGroup creates a group with the first name as creator and adds the
+// others in one commit.
+func newTestGroup(t *testing.T, names ...string) *testGroup {
+	t.Helper()
+	tg := &testGroup{id: mustParse(t, "urn:sm:group:book-club"), clients: make(map[string]*client)}
+	creator := newClient(t, names[0])
+	g, err := mls.CreateGroup(tg.id, creator.kp, creator.keys)
+	require.NoError(t, err)
+	creator.group = g
+	tg.clients[names[0]] = creator
+	if len(names) > 1 {
+		tg.commit(t, names[0], names[1:], nil)
+	}
+	return tg
+}
+
+// commit has committer add and remove members and delivers the commit and
+// welcome to everyone else.
+func (tg *testGroup) commit(t *testing.T, committer string, add, remove []string) (*mls.Commit, *mls.Welcome) {
+	t.Helper()
+	var kps []*mls.KeyPackage
+	var joining []*client
+	for _, name := range add {
+		c := newClient(t, name)
+		kps = append(kps, c.kp)
+		joining = append(joining, c)
+	}
+	var removes []urn.URN
+	for _, name := range remove {
+		removes = append(removes, tg.clients[name].id)
+	}
+
+	commit, welcome, err := tg.clients[committer].group.Commit(kps, removes)
+	require.NoError(t, err)
+	commit = roundTrip(t, commit)
+
+	for name, c := range tg.clients {
+		if name == committer {
+			continue
+		}
+		err := c.group.Process(commit)
+		if containsName(remove, name) {
+			require.ErrorIs(t, err, mls.ErrRemoved)
+			delete(tg.clients, name)
+			continue
+		}
+		require.NoError(t, err, "%s processing %s's commit", name, committer)
+	}
+
+	if len(add) == 0 {
+		assert.Nil(t, welcome)
+		return commit, nil
+	}
+	require.NotNil(t, welcome)
+	welcome = roundTrip(t, welcome)
+	for i, c := range joining {
+		g, err := mls.Join(welcome, c.kp, c.keys)
+		require.NoError(t, err, "%s joining", add[i])
+		c.group = g
+		tg.clients[add[i]] = c
+	}
+	return commit, welcome
+}
+
+// requireAgreement checks that every client is in the same epoch with the
+// same members and secrets.
+func (tg *testGroup) requireAgreement(t *testing.T) {
+	t.Helper()
+	var first *mls.Group
+	for name, c := range tg.clients {
+		if first == nil {
+			first = c.group
+			continue
+		}
+		require.Equal(t, first.Epoch(), c.group.Epoch(), name)
+		require.Equal(t, first.Members(), c.group.Members(), name)
+		require.Equal(t, first.EpochAuthenticator(), c.group.EpochAuthenticator(), name)
+	}
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGroupLifecycle(t *testing.T) {
+	tg := newTestGroup(t, "alice")
+	alice := tg.clients["alice"].group
+	assert.Equal(t, uint64(0), alice.Epoch())
+	assert.Equal(t, tg.id, alice.ID())
+	assert.Equal(t, []urn.URN{tg.clients["alice"].id}, alice.Members())
+
+	tg.commit(t, "alice", []string{"bob", "carol"}, nil)
+	tg.requireAgreement(t)
+	assert.Equal(t, uint64(1), alice.Epoch())
+	assert.Len(t, alice.Members(), 3)
+	assert.Equal(t, tg.clients["bob"].id, tg.clients["bob"].group.Self())
+
+	tg.commit(t, "bob", []string{"dave"}, nil)
+	tg.requireAgreement(t)
+
+	before := alice.EpochAuthenticator()
+	tg.commit(t, "carol", nil, nil)
+	tg.requireAgreement(t)
+	assert.NotEqual(t, before, alice.EpochAuthenticator(), "an update commit starts a new epoch")
+
+	bob := tg.clients["bob"].group
+	tg.commit(t, "alice", nil, []string{"bob"})
+	tg.requireAgreement(t)
+	assert.Equal(t, uint64(4), alice.Epoch())
+	assert.NotContains(t, alice.Members(), mustParse(t, "urn:sm:user:bob"))
+	assert.NotEqual(t, alice.EpochAuthenticator(), bob.EpochAuthenticator())
+
+	_, _, err := bob.Commit(nil, nil)
+	assert.ErrorIs(t, err, mls.ErrRemoved)
+	_, err = bob.Export("x", nil, 32)
+	assert.ErrorIs(t, err, mls.ErrRemoved)
+
+	t.Run("Exported secrets agree", func(t *testing.T) {
+		a, err := alice.Export("file-keys", []byte("ctx"), 48)
+		require.NoError(t, err)
+		d, err := tg.clients["dave"].group.Export("file-keys", []byte("ctx"), 48)
+		require.NoError(t, err)
+		assert.Len(t, a, 48)
+		assert.Equal(t, a, d)
+
+		other, err := alice.Export("other", []byte("ctx"), 48)
+		require.NoError(t, err)
+		assert.NotEqual(t, a, other)
+	})
+}
+
+func TestLargeGroup(t *testing.T) {
+	names := []string{"m00"}
+	for i := 1; i < 20; i++ {
+		names = append(names, fmt.Sprintf("m%02d", i))
+	}
+	tg := newTestGroup(t, names...)
+	tg.requireAgreement(t)
+
+	// Every member refreshes its path once, filling in the tree.
+	for _, name := range names {
+		tg.commit(t, name, nil, nil)
+	}
+	tg.requireAgreement(t)
+
+	// With a full tree of 32 leaves, a commit costs one node per level and
+	// far fewer encryptions than there are members.
+	commit, _ := tg.commit(t, "m07", nil, nil)
+	assert.Len(t, commit.Path.Nodes, 5)
+	encryptions := 0
+	for _, n := range commit.Path.Nodes {
+		encryptions += len(n.EncryptedPathSecret)
+	}
+	assert.Less(t, encryptions, len(names)/2)
+
+	// Removing members shrinks the tree again.
+	tg.commit(t, "m00", nil, names[4:])
+	tg.requireAgreement(t)
+	commit, _ = tg.commit(t, "m01", nil, nil)
+	assert.Len(t, commit.Path.Nodes, 2)
+	assert.Len(t, tg.clients["m02"].group.Members(), 4)
+}
+
+func TestCommitFailures(t *testing.T) {
+	newCommit := func(t *testing.T) (*testGroup, *mls.Commit) {
+		tg := newTestGroup(t, "alice", "bob", "carol")
+		commit, _, err := tg.clients["alice"].group.Commit(nil, nil)
+		require.NoError(t, err)
+		return tg, roundTrip(t, commit)
+	}
+
+	testCases := []struct {
+		name          string
+		modify        func(c *mls.Commit)
+		expectedErrIs error
+	}{
+		{name: "Tampered signature", modify: func(c *mls.Commit) { c.Signature[0] ^= 1 }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Tampered path key", modify: func(c *mls.Commit) { c.Path.Nodes[0].EncryptionKey[0] ^= 1 }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Tampered confirmation tag", modify: func(c *mls.Commit) { c.ConfirmationTag[0] ^= 1 }, expectedErrIs: mls.ErrInvalidCommit},
+		{name: "Wrong epoch", modify: func(c *mls.Commit) { c.Epoch++ }, expectedErrIs: mls.ErrWrongEpoch},
+		{name: "Unknown sender", modify: func(c *mls.Commit) { c.Sender = 7 }, expectedErrIs: mls.ErrNotMember},
+		{name: "Wrong group", modify: func(c *mls.Commit) { c.GroupID = mustParse(t, "urn:sm:group:other") }, expectedErrIs: mls.ErrMalformed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tg, commit := newCommit(t)
+			tc.modify(commit)
+			bob := tg.clients["bob"].group
+			assert.ErrorIs(t, bob.Process(commit), tc.expectedErrIs)
+			assert.Equal(t, uint64(1), bob.Epoch(), "a rejected commit leaves the group unchanged")
+		})
+	}
+
+	t.Run("Genuine commit applies after a rejected one", func(t *testing.T) {
+		tg, commit := newCommit(t)
+		bob := tg.clients["bob"].group
+		tampered := roundTrip(t, commit)
+		tampered.ConfirmationTag[0] ^= 1
+		require.Error(t, bob.Process(tampered))
+		require.NoError(t, bob.Process(commit))
+		assert.Equal(t, tg.clients["alice"].group.EpochAuthenticator(), bob.EpochAuthenticator())
+		assert.ErrorIs(t, bob.Process(commit), mls.ErrWrongEpoch, "a commit cannot be applied twice")
+	})
+
+	t.Run("Invalid proposals", func(t *testing.T) {
+		tg := newTestGroup(t, "alice", "bob")
+		alice := tg.clients["alice"]
+
+		_, _, err := alice.group.Commit([]*mls.KeyPackage{tg.clients["bob"].kp}, nil)
+		assert.ErrorIs(t, err, mls.ErrAlreadyMember)
+		_, _, err = alice.group.Commit(nil, []urn.URN{mustParse(t, "urn:sm:user:nobody")})
+		assert.ErrorIs(t, err, mls.ErrNotMember)
+		_, _, err = alice.group.Commit(nil, []urn.URN{alice.id})
+		assert.ErrorIs(t, err, mls.ErrInvalidCommit)
+
+		tampered := newClient(t, "eve").kp
+		tampered.InitKey[0] ^= 1
+		_, _, err = alice.group.Commit([]*mls.KeyPackage{tampered}, nil)
+		assert.ErrorIs(t, err, transport.ErrInvalidSignature)
+		assert.Equal(t, uint64(1), alice.group.Epoch())
+	})
+
+	t.Run("Concurrent commits", func(t *testing.T) {
+		tg := newTestGroup(t, "alice", "bob", "carol")
+		first, _, err := tg.clients["alice"].group.Commit(nil, nil)
+		require.NoError(t, err)
+		_, _, err = tg.clients["bob"].group.Commit(nil, nil)
+		require.NoError(t, err)
+
+		// The delivery service orders alice's commit first; bob's own commit
+		// is lost and bob must rejoin or recover out of band.
+		require.NoError(t, tg.clients["carol"].group.Process(first))
+		assert.ErrorIs(t, tg.clients["bob"].group.Process(first), mls.ErrWrongEpoch)
+	})
+}
+
+func TestJoinFailures(t *testing.T) {
+	tg := newTestGroup(t, "alice")
+	bob := newClient(t, "bob")
+	_, welcome, err := tg.clients["alice"].group.Commit([]*mls.KeyPackage{bob.kp}, nil)
+	require.NoError(t, err)
+
+	t.Run("Not addressed to key package", func(t *testing.T) {
+		carol := newClient(t, "carol")
+		_, err := mls.Join(welcome, carol.kp, carol.keys)
+		assert.ErrorIs(t, err, mls.ErrNotMember)
+	})
+
+	t.Run("Keys do not match", func(t *testing.T) {
+		carol := newClient(t, "carol")
+		_, err := mls.Join(welcome, bob.kp, carol.keys)
+		assert.Error(t, err)
+	})
+
+	t.Run("Tampered group info", func(t *testing.T) {
+		tampered := roundTrip(t, welcome)
+		tampered.EncryptedGroupInfo[0] ^= 1
+		_, err := mls.Join(tampered, bob.kp, bob.keys)
+		assert.ErrorIs(t, err, mls.ErrDecryptionFailed)
+	})
+
+	t.Run("Tampered secrets", func(t *testing.T) {
+		tampered := roundTrip(t, welcome)
+		tampered.Secrets[0].Secrets.Ciphertext[0] ^= 1
+		_, err := mls.Join(tampered, bob.kp, bob.keys)
+		assert.ErrorIs(t, err, mls.ErrDecryptionFailed)
+	})
+
+	t.Run("Valid welcome joins", func(t *testing.T) {
+		g, err := mls.Join(welcome, bob.kp, bob.keys)
+		require.NoError(t, err)
+		assert.Equal(t, tg.clients["alice"].group.EpochAuthenticator(), g.EpochAuthenticator())
+	})
+}
@@ -0,0 +1,94 @@
+package mls
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// KeyPackage is what a client publishes so that others can add it to a
+// group: the leaf it will occupy and an init key that Welcome messages are
+// encrypted to. A key package should be used for one group add only.
+type KeyPackage struct {
+	Leaf      LeafNode `json:"leaf"`
+	InitKey   []byte   `json:"initKey"`
+	Signature []byte   `json:"signature"`
+}
+
+// KeyPackageKeys are the private keys matching a KeyPackage. The init key
+// should be deleted once the client has joined a group with it.
+type KeyPackageKeys struct {
+	InitKey       *ecdh.PrivateKey
+	EncryptionKey *ecdh.PrivateKey
+	SigningKey    ed25519.PrivateKey
+}
+
+// NewKeyPackage generates fresh init and leaf encryption keys for identity
+// and signs the key package with signingKey, the client's long-term
+// signature key.
+func NewKeyPackage(identity urn.URN, signingKey ed25519.PrivateKey) (*KeyPackage, *KeyPackageKeys, error) {
+	if identity.IsZero() {
+		return nil, nil, errors.New("identity is required")
+	}
+	if len(signingKey) != ed25519.PrivateKeySize {
+		return nil, nil, errors.New("an Ed25519 signing key is required")
+	}
+	initKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	encryptionKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kp := &KeyPackage{
+		Leaf: LeafNode{
+			Identity:      identity,
+			EncryptionKey: encryptionKey.PublicKey().Bytes(),
+			SigningKey:    signingKey.Public().(ed25519.PublicKey),
+		},
+		InitKey: initKey.PublicKey().Bytes(),
+	}
+	kp.Leaf.sign(signingKey)
+	kp.Signature = signWithLabel(signingKey, "KeyPackageTBS", kp.tbs())
+	return kp, &KeyPackageKeys{InitKey: initKey, EncryptionKey: encryptionKey, SigningKey: signingKey}, nil
+}
+
+// Verify checks the key package's leaf, init key and signature.
+func (kp *KeyPackage) Verify() error {
+	if kp == nil {
+		return fmt.Errorf("%w: nil key package", ErrMalformed)
+	}
+	if err := kp.Leaf.Verify(); err != nil {
+		return err
+	}
+	if len(kp.InitKey) != secretSize {
+		return fmt.Errorf("%w: init key must be %d bytes", ErrMalformed, secretSize)
+	}
+	return verifyWithLabel(kp.Leaf.SigningKey, "KeyPackageTBS", kp.tbs(), kp.Signature)
+}
+
+// Ref returns the hash that identifies the key package in a Welcome.
+func (kp *KeyPackage) Ref() []byte {
+	return hash([]byte(labelPrefix+"KeyPackageRef"), kp.tbs(), kp.Signature)
+}
+
+func (kp *KeyPackage) tbs() []byte {
+	b := appendField(nil, kp.Leaf.tbs())
+	b = appendField(b, kp.Leaf.Signature)
+	return appendField(b, kp.InitKey)
+}
+
+// matches reports whether keys are the private half of kp.
+func (keys *KeyPackageKeys) matches(kp *KeyPackage) bool {
+	return keys != nil && keys.InitKey != nil && keys.EncryptionKey != nil &&
+		string(keys.InitKey.PublicKey().Bytes()) == string(kp.InitKey) &&
+		string(keys.EncryptionKey.PublicKey().Bytes()) == string(kp.Leaf.EncryptionKey) &&
+		len(keys.SigningKey) == ed25519.PrivateKeySize &&
+		string(keys.SigningKey.Public().(ed25519.PublicKey)) == string(kp.Leaf.SigningKey)
+}
@@ -0,0 +1,86 @@
+package mls_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/mls"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, s string) urn.URN {
+	t.Helper()
+	u, err := urn.Parse(s)
+	require.NoError(t, err)
+	return u
+}
+
+func mustSigningKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func TestKeyPackage(t *testing.T) {
+	alice := mustParse(t, "urn:sm:user:alice")
+	signingKey := mustSigningKey(t)
+
+	kp, keys, err := mls.NewKeyPackage(alice, signingKey)
+	require.NoError(t, err)
+	require.NoError(t, kp.Verify())
+	assert.Equal(t, alice, kp.Leaf.Identity)
+	assert.Equal(t, signingKey.Public(), kp.Leaf.SigningKey)
+	assert.Equal(t, kp.InitKey, keys.InitKey.PublicKey().Bytes())
+	assert.Equal(t, kp.Leaf.EncryptionKey, keys.EncryptionKey.PublicKey().Bytes())
+
+	t.Run("JSON round-trip", func(t *testing.T) {
+		data, err := json.Marshal(kp)
+		require.NoError(t, err)
+		var decoded mls.KeyPackage
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.NoError(t, decoded.Verify())
+		assert.Equal(t, kp.Ref(), decoded.Ref())
+	})
+
+	t.Run("Fresh keys each time", func(t *testing.T) {
+		other, _, err := mls.NewKeyPackage(alice, signingKey)
+		require.NoError(t, err)
+		assert.NotEqual(t, kp.Ref(), other.Ref())
+		assert.NotEqual(t, kp.InitKey, other.InitKey)
+	})
+
+	testCases := []struct {
+		name          string
+		modify        func(kp *mls.KeyPackage)
+		expectedErrIs error
+	}{
+		{name: "Tampered init key", modify: func(kp *mls.KeyPackage) { kp.InitKey[0] ^= 1 }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Tampered leaf key", modify: func(kp *mls.KeyPackage) { kp.Leaf.EncryptionKey[0] ^= 1 }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Changed identity", modify: func(kp *mls.KeyPackage) { kp.Leaf.Identity = mustParse(t, "urn:sm:user:mallory") }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Short init key", modify: func(kp *mls.KeyPackage) { kp.InitKey = kp.InitKey[:16] }, expectedErrIs: mls.ErrMalformed},
+		{name: "Missing identity", modify: func(kp *mls.KeyPackage) { kp.Leaf.Identity = urn.URN{} }, expectedErrIs: mls.ErrMalformed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kp, _, err := mls.NewKeyPackage(alice, signingKey)
+			require.NoError(t, err)
+			tc.modify(kp)
+			assert.ErrorIs(t, kp.Verify(), tc.expectedErrIs)
+		})
+	}
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		_, _, err := mls.NewKeyPackage(urn.URN{}, signingKey)
+		assert.Error(t, err)
+		_, _, err = mls.NewKeyPackage(alice, nil)
+		assert.Error(t, err)
+		var nilPackage *mls.KeyPackage
+		assert.ErrorIs(t, nilPackage.Verify(), mls.ErrMalformed)
+	})
+}
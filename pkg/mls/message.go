@@ -0,0 +1,195 @@
+package mls
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/groupenv"
+	"github.com/illmade-knight/go-secure-messaging/pkg/messageid"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+const (
+	headerVersion = 0x01
+	headerSize    = 17
+	adContext     = "go-secure-messaging/mls/v1 ad"
+)
+
+// appKeys holds one epoch's per-sender hash ratchets, seeded from the
+// epoch's encryption secret for every occupied leaf.
+type appKeys struct {
+	epoch   uint64
+	senders map[uint32]*senderChain
+}
+
+// senderChain is the hash ratchet of one leaf. It also records the leaf's
+// identity and signing key in that epoch, so that messages from a previous
+// epoch are checked against the leaf as it was then.
+type senderChain struct {
+	identity   urn.URN
+	signingKey ed25519.PublicKey
+	chain      *groupenv.Chain
+}
+
+func newAppKeys(epoch uint64, encryptionSecret []byte, tree *ratchetTree) *appKeys {
+	a := &appKeys{epoch: epoch, senders: make(map[uint32]*senderChain)}
+	for i := uint32(0); i < tree.leafCount(); i++ {
+		l := tree.leaf(i)
+		if l == nil {
+			continue
+		}
+		secret := expandWithLabel(encryptionSecret, "application", binary.BigEndian.AppendUint32(nil, i), secretSize)
+		a.senders[i] = &senderChain{
+			identity:   l.Identity,
+			signingKey: l.SigningKey,
+			chain:      groupenv.NewChain(0, secret, ratchetStep),
+		}
+	}
+	return a
+}
+
+// advance returns the ratchet secret for generation. The caller must only
+// commit the result if the message decrypts, so it operates on and returns
+// a copy.
+func (c *senderChain) advance(generation uint32, maxSkip int) (*senderChain, []byte, error) {
+	chain, secret, err := c.chain.MessageKey(generation, maxSkip)
+	if errors.Is(err, groupenv.ErrKeyUsed) {
+		return nil, nil, fmt.Errorf("%w: %w", ErrDecryptionFailed, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	next := *c
+	next.chain = chain
+	return &next, secret, nil
+}
+
+// ratchetStep returns the next ratchet secret and the secret for generation,
+// from which the message key and nonce are expanded.
+func ratchetStep(secret []byte, generation uint32) ([]byte, []byte) {
+	return expandWithLabel(secret, "secret", generationContext(generation), secretSize), secret
+}
+
+func generationContext(generation uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, generation)
+}
+
+// messageKey expands the ratchet secret for generation into an AES-256-GCM
+// key and nonce.
+func messageKey(secret []byte, generation uint32) ([]byte, []byte) {
+	ctx := generationContext(generation)
+	return expandWithLabel(secret, "key", ctx, secretSize), expandWithLabel(secret, "nonce", ctx, nonceSize)
+}
+
+// Encrypt encrypts plaintext for the current epoch and returns it in an
+// envelope addressed to the group and signed with this member's leaf
+// signing key.
+func (g *Group) Encrypt(plaintext []byte, opts ...Option) (*transport.SecureEnvelope, error) {
+	o := groupenv.NewOptions(opts, DefaultMaxSkip)
+	messageID := o.MessageID
+	if messageID == "" {
+		var err error
+		if messageID, err = messageid.NewString(); err != nil {
+			return nil, err
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.removed {
+		return nil, ErrRemoved
+	}
+	chain := g.state.app.senders[g.self]
+	generation, secret := chain.chain.Next()
+
+	env := &transport.SecureEnvelope{
+		Suite:                 transport.SuiteMLSX25519AES256GCMEd25519,
+		MessageID:             messageID,
+		SenderID:              chain.identity,
+		GroupID:               g.state.ctx.groupID,
+		ConversationID:        o.ConversationID,
+		EncryptedSymmetricKey: marshalHeader(g.state.ctx.epoch, g.self, generation),
+	}
+	key, nonce := messageKey(secret, generation)
+	ciphertext, err := sealAEAD(key, nonce, plaintext, groupenv.AssociatedData(adContext, env))
+	if err != nil {
+		return nil, err
+	}
+	env.EncryptedData = ciphertext
+	o.Stamp(env)
+	if err := transport.Sign(env, g.signingKey); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Decrypt verifies the sender's signature on a group envelope and decrypts
+// it. Messages from the current and the previous epoch are accepted; the
+// sender's chain only advances if decryption succeeds.
+func (g *Group) Decrypt(env *transport.SecureEnvelope) ([]byte, error) {
+	if env == nil {
+		return nil, errors.New("cannot decrypt a nil envelope")
+	}
+	if env.Suite != transport.SuiteMLSX25519AES256GCMEd25519 {
+		return nil, fmt.Errorf("%w: envelope suite is %s", ErrMalformed, env.Suite)
+	}
+	epoch, leaf, generation, err := parseHeader(env.EncryptedSymmetricKey)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !env.GroupID.Equal(g.state.ctx.groupID) {
+		return nil, fmt.Errorf("%w: envelope is for %s", ErrMalformed, env.GroupID)
+	}
+	var keys *appKeys
+	switch {
+	case g.removed:
+		return nil, ErrRemoved
+	case epoch == g.state.app.epoch:
+		keys = g.state.app
+	case g.previous != nil && epoch == g.previous.epoch:
+		keys = g.previous
+	default:
+		return nil, fmt.Errorf("%w: message is from epoch %d, group is in %d", ErrWrongEpoch, epoch, g.state.ctx.epoch)
+	}
+	chain, ok := keys.senders[leaf]
+	if !ok || !chain.identity.Equal(env.SenderID) {
+		return nil, fmt.Errorf("%w: %s at leaf %d in epoch %d", ErrNotMember, env.SenderID, leaf, epoch)
+	}
+	if err := transport.Verify(env, chain.signingKey); err != nil {
+		return nil, err
+	}
+
+	next, secret, err := chain.advance(generation, g.maxSkip)
+	if err != nil {
+		return nil, err
+	}
+	key, nonce := messageKey(secret, generation)
+	plaintext, err := openAEAD(key, nonce, env.EncryptedData, groupenv.AssociatedData(adContext, env))
+	if err != nil {
+		return nil, err
+	}
+	keys.senders[leaf] = next
+	return plaintext, nil
+}
+
+// header is carried in EncryptedSymmetricKey: version (1), epoch (8),
+// sender leaf index (4) and generation (4), big-endian.
+func marshalHeader(epoch uint64, leaf, generation uint32) []byte {
+	b := []byte{headerVersion}
+	b = binary.BigEndian.AppendUint64(b, epoch)
+	b = binary.BigEndian.AppendUint32(b, leaf)
+	return binary.BigEndian.AppendUint32(b, generation)
+}
+
+func parseHeader(b []byte) (epoch uint64, leaf, generation uint32, err error) {
+	if len(b) != headerSize || b[0] != headerVersion {
+		return 0, 0, 0, fmt.Errorf("%w: invalid header", ErrMalformed)
+	}
+	return binary.BigEndian.Uint64(b[1:]), binary.BigEndian.Uint32(b[9:]), binary.BigEndian.Uint32(b[13:]), nil
+}
@@ -0,0 +1,192 @@
+package mls_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/mls"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupMessaging(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob", "carol")
+	conversation := mustParse(t, "urn:sm:conversation:book-club-chat")
+
+	for round := 0; round < 2; round++ {
+		for _, from := range []string{"alice", "bob", "carol"} {
+			text := fmt.Sprintf("%s says %d", from, round)
+			env, err := tg.clients[from].group.Encrypt([]byte(text), mls.WithConversationID(conversation), mls.WithMessageID(text))
+			require.NoError(t, err)
+			require.NoError(t, env.Validate(), "mls envelopes are complete group envelopes")
+			assert.Equal(t, tg.id, env.GroupID)
+			assert.Equal(t, tg.clients[from].id, env.SenderID)
+			assert.Equal(t, text, env.MessageID)
+			assert.Equal(t, transport.SuiteMLSX25519AES256GCMEd25519, env.Suite)
+
+			wire, err := transport.FromProto(transport.ToProto(env))
+			require.NoError(t, err)
+			for name, c := range tg.clients {
+				if name == from {
+					continue
+				}
+				plaintext, err := c.group.Decrypt(wire)
+				require.NoError(t, err, "%s reading %s", name, from)
+				assert.Equal(t, text, string(plaintext))
+			}
+		}
+	}
+}
+
+func TestMessageExpiry(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob")
+	conversation := mustParse(t, "urn:sm:conversation:book-club-chat")
+	sentAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	encrypt := func(t *testing.T) *transport.SecureEnvelope {
+		env, err := tg.clients["alice"].group.Encrypt([]byte("disappearing"),
+			mls.WithConversationID(conversation), mls.WithSentAt(sentAt), mls.WithTTL(time.Hour))
+		require.NoError(t, err)
+		return env
+	}
+
+	env := encrypt(t)
+	assert.Equal(t, sentAt, env.SentAt)
+	assert.Equal(t, sentAt.Add(time.Hour), env.ExpiresAt)
+
+	wire, err := transport.FromProto(transport.ToProto(env))
+	require.NoError(t, err)
+	plaintext, err := tg.clients["bob"].group.Decrypt(wire)
+	require.NoError(t, err)
+	assert.Equal(t, "disappearing", string(plaintext))
+
+	extended := encrypt(t)
+	extended.ExpiresAt = extended.ExpiresAt.Add(time.Hour)
+	_, err = tg.clients["bob"].group.Decrypt(extended)
+	assert.ErrorIs(t, err, transport.ErrInvalidSignature, "the expiry is signed")
+}
+
+func TestMessageOrdering(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob")
+	alice, bob := tg.clients["alice"].group, tg.clients["bob"].group
+
+	var envs []*transport.SecureEnvelope
+	for i := 0; i < 4; i++ {
+		env, err := alice.Encrypt([]byte(fmt.Sprintf("msg %d", i)))
+		require.NoError(t, err)
+		envs = append(envs, env)
+	}
+	for _, i := range []int{2, 0, 3, 1} {
+		plaintext, err := bob.Decrypt(envs[i])
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("msg %d", i), string(plaintext))
+	}
+	for _, env := range envs {
+		_, err := bob.Decrypt(env)
+		assert.ErrorIs(t, err, mls.ErrDecryptionFailed, "replays are rejected")
+	}
+
+	t.Run("Max skip", func(t *testing.T) {
+		tg := newTestGroup(t, "alice")
+		bob := newClient(t, "bob")
+		_, welcome, err := tg.clients["alice"].group.Commit([]*mls.KeyPackage{bob.kp}, nil)
+		require.NoError(t, err)
+		bobGroup, err := mls.Join(welcome, bob.kp, bob.keys, mls.WithMaxSkip(2))
+		require.NoError(t, err)
+
+		var last *transport.SecureEnvelope
+		for i := 0; i < 4; i++ {
+			last, err = tg.clients["alice"].group.Encrypt([]byte("x"))
+			require.NoError(t, err)
+		}
+		_, err = bobGroup.Decrypt(last)
+		assert.ErrorIs(t, err, mls.ErrTooManySkipped)
+	})
+}
+
+func TestMessageEpochs(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob", "carol")
+	alice := tg.clients["alice"].group
+	bob := tg.clients["bob"].group
+	carol := tg.clients["carol"].group
+
+	inFlight, err := alice.Encrypt([]byte("sent in epoch 1"))
+	require.NoError(t, err)
+
+	tg.commit(t, "bob", []string{"dave"}, []string{"carol"})
+	dave := tg.clients["dave"].group
+
+	plaintext, err := bob.Decrypt(inFlight)
+	require.NoError(t, err, "messages from the previous epoch still open")
+	assert.Equal(t, "sent in epoch 1", string(plaintext))
+
+	_, err = dave.Decrypt(inFlight)
+	assert.ErrorIs(t, err, mls.ErrWrongEpoch, "new members cannot read earlier epochs")
+
+	after, err := alice.Encrypt([]byte("sent in epoch 2"))
+	require.NoError(t, err)
+	for _, g := range []*mls.Group{bob, dave} {
+		plaintext, err := g.Decrypt(after)
+		require.NoError(t, err)
+		assert.Equal(t, "sent in epoch 2", string(plaintext))
+	}
+	_, err = carol.Decrypt(after)
+	assert.ErrorIs(t, err, mls.ErrRemoved)
+	_, err = carol.Encrypt([]byte("still here?"))
+	assert.ErrorIs(t, err, mls.ErrRemoved)
+
+	// Two commits later the first epoch's keys are gone.
+	tg.commit(t, "alice", nil, nil)
+	_, err = bob.Decrypt(inFlight)
+	assert.ErrorIs(t, err, mls.ErrWrongEpoch)
+}
+
+func TestMessageForgeries(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob", "carol")
+	bob := tg.clients["bob"].group
+	otherConversation := mustParse(t, "urn:sm:conversation:other")
+
+	encrypt := func(t *testing.T, from string) *transport.SecureEnvelope {
+		env, err := tg.clients[from].group.Encrypt([]byte("secret"))
+		require.NoError(t, err)
+		return env
+	}
+
+	t.Run("Member impersonating another", func(t *testing.T) {
+		env := encrypt(t, "carol")
+		env.SenderID = tg.clients["alice"].id
+		_, err := bob.Decrypt(env)
+		assert.ErrorIs(t, err, mls.ErrNotMember)
+	})
+
+	testCases := []struct {
+		name          string
+		modify        func(env *transport.SecureEnvelope)
+		expectedErrIs error
+	}{
+		{name: "Tampered ciphertext", modify: func(env *transport.SecureEnvelope) { env.EncryptedData[0] ^= 1 }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Moved conversation", modify: func(env *transport.SecureEnvelope) { env.ConversationID = otherConversation }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Wrong epoch", modify: func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey[8] ^= 4 }, expectedErrIs: mls.ErrWrongEpoch},
+		{name: "Wrong leaf", modify: func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey[12] ^= 2 }, expectedErrIs: mls.ErrNotMember},
+		{name: "Malformed header", modify: func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey = []byte{1} }, expectedErrIs: mls.ErrMalformed},
+		{name: "Wrong suite", modify: func(env *transport.SecureEnvelope) { env.Suite = transport.SuiteSenderKeyAES256GCMEd25519 }, expectedErrIs: mls.ErrMalformed},
+		{name: "Wrong group", modify: func(env *transport.SecureEnvelope) { env.GroupID = mustParse(t, "urn:sm:group:other") }, expectedErrIs: mls.ErrMalformed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := encrypt(t, "alice")
+			tc.modify(env)
+			_, err := bob.Decrypt(env)
+			assert.ErrorIs(t, err, tc.expectedErrIs)
+		})
+	}
+
+	t.Run("Chain unaffected by rejected messages", func(t *testing.T) {
+		env := encrypt(t, "alice")
+		plaintext, err := bob.Decrypt(env)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", string(plaintext))
+	})
+}
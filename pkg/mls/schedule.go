@@ -0,0 +1,73 @@
+package mls
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// groupContext is the state every member of an epoch agrees on. It is
+// mixed into the key schedule, so members that disagree about any of it
+// derive different secrets.
+type groupContext struct {
+	groupID                 urn.URN
+	epoch                   uint64
+	treeHash                []byte
+	confirmedTranscriptHash []byte
+}
+
+func (c groupContext) marshal() []byte {
+	b := appendField(nil, []byte(c.groupID.String()))
+	b = binary.BigEndian.AppendUint64(b, c.epoch)
+	b = appendField(b, c.treeHash)
+	return appendField(b, c.confirmedTranscriptHash)
+}
+
+// epochSecrets are the secrets the key schedule derives for one epoch.
+type epochSecrets struct {
+	encryption    []byte
+	exporter      []byte
+	confirmation  []byte
+	authenticator []byte
+	init          []byte
+}
+
+// joinerSecret combines the previous epoch's init secret with the commit
+// secret from the new path.
+func joinerSecret(initSecret, commitSecret []byte, ctx groupContext) []byte {
+	return expandWithLabel(extract(initSecret, commitSecret), "joiner", ctx.marshal(), secretSize)
+}
+
+// welcomeSecret encrypts the GroupInfo sent to new members.
+func welcomeSecret(joiner []byte) []byte {
+	return deriveSecret(extract(joiner, make([]byte, secretSize)), "welcome")
+}
+
+func newEpochSecrets(joiner []byte, ctx groupContext) *epochSecrets {
+	epoch := expandWithLabel(extract(joiner, make([]byte, secretSize)), "epoch", ctx.marshal(), secretSize)
+	return &epochSecrets{
+		encryption:    deriveSecret(epoch, "encryption"),
+		exporter:      deriveSecret(epoch, "exporter"),
+		confirmation:  deriveSecret(epoch, "confirm"),
+		authenticator: deriveSecret(epoch, "authentication"),
+		init:          deriveSecret(epoch, "init"),
+	}
+}
+
+// confirmationTag proves that the committer derived the same epoch secrets
+// from the same transcript.
+func (s *epochSecrets) confirmationTag(confirmedTranscriptHash []byte) []byte {
+	mac := hmac.New(sha256.New, s.confirmation)
+	mac.Write(confirmedTranscriptHash)
+	return mac.Sum(nil)
+}
+
+func confirmedTranscriptHash(interim, content, signature []byte) []byte {
+	return hash(appendField(nil, interim), appendField(nil, content), appendField(nil, signature))
+}
+
+func interimTranscriptHash(confirmed, tag []byte) []byte {
+	return hash(appendField(nil, confirmed), appendField(nil, tag))
+}
@@ -0,0 +1,302 @@
+package mls
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// Node is one node of the public ratchet tree. A blank node has neither
+// Leaf nor Parent set; leaves sit at even node indices and parents at odd
+// ones.
+type Node struct {
+	Leaf   *LeafNode   `json:"leaf,omitempty"`
+	Parent *ParentNode `json:"parent,omitempty"`
+}
+
+// LeafNode is a member's leaf: their identity, the X25519 key that path
+// secrets are encrypted to and the Ed25519 key that verifies their commits
+// and messages. Signature is made with SigningKey over the other fields.
+type LeafNode struct {
+	Identity      urn.URN           `json:"identity"`
+	EncryptionKey []byte            `json:"encryptionKey"`
+	SigningKey    ed25519.PublicKey `json:"signingKey"`
+	Signature     []byte            `json:"signature"`
+}
+
+// ParentNode holds the public key of an inner node of the tree.
+type ParentNode struct {
+	EncryptionKey []byte `json:"encryptionKey"`
+}
+
+func (l *LeafNode) tbs() []byte {
+	b := appendField(nil, []byte(l.Identity.String()))
+	b = appendField(b, l.EncryptionKey)
+	return appendField(b, l.SigningKey)
+}
+
+func (l *LeafNode) sign(key ed25519.PrivateKey) {
+	l.Signature = signWithLabel(key, "LeafNodeTBS", l.tbs())
+}
+
+// Verify checks the leaf's keys and its signature.
+func (l *LeafNode) Verify() error {
+	if l.Identity.IsZero() || len(l.EncryptionKey) != secretSize || len(l.SigningKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: leaf node must have an identity and valid keys", ErrMalformed)
+	}
+	return verifyWithLabel(l.SigningKey, "LeafNodeTBS", l.tbs(), l.Signature)
+}
+
+// --- Tree math (RFC 9420 appendix C) ---
+//
+// The tree is stored as an array of 2n-1 nodes for n leaves, n always a
+// power of two. Leaf i is node 2i; a node's level is the number of trailing
+// one bits in its index.
+
+func level(x uint32) uint32 {
+	return uint32(bits.TrailingZeros32(^x))
+}
+
+func nodeWidth(leaves uint32) uint32 {
+	return 2*leaves - 1
+}
+
+func root(leaves uint32) uint32 {
+	return leaves - 1
+}
+
+func left(x uint32) uint32 {
+	return x ^ (1 << (level(x) - 1))
+}
+
+func right(x uint32) uint32 {
+	return x ^ (3 << (level(x) - 1))
+}
+
+func parent(x uint32) uint32 {
+	k := level(x)
+	b := (x >> (k + 1)) & 1
+	return (x | (1 << k)) ^ (b << (k + 1))
+}
+
+func sibling(x uint32) uint32 {
+	p := parent(x)
+	if x < p {
+		return right(p)
+	}
+	return left(p)
+}
+
+// inSubtree reports whether node y lies in the subtree rooted at x.
+func inSubtree(x, y uint32) bool {
+	span := uint32(1)<<level(x) - 1
+	return y+span >= x && y <= x+span
+}
+
+func leafNode(leaf uint32) uint32 {
+	return 2 * leaf
+}
+
+// ratchetTree is the public tree plus helpers for TreeKEM.
+type ratchetTree struct {
+	nodes []Node
+}
+
+func newTree(leaf *LeafNode) *ratchetTree {
+	return &ratchetTree{nodes: []Node{{Leaf: leaf}}}
+}
+
+// treeFromNodes checks the shape and leaves of a received tree.
+func treeFromNodes(nodes []Node) (*ratchetTree, error) {
+	n := uint32(len(nodes)+1) / 2
+	if len(nodes) == 0 || n&(n-1) != 0 || uint32(len(nodes)) != nodeWidth(n) {
+		return nil, fmt.Errorf("%w: tree of %d nodes is not full", ErrMalformed, len(nodes))
+	}
+	t := &ratchetTree{nodes: nodes}
+	seen := make(map[urn.URN]bool)
+	for x, node := range nodes {
+		isLeaf := x%2 == 0
+		if (node.Leaf != nil && !isLeaf) || (node.Parent != nil && isLeaf) {
+			return nil, fmt.Errorf("%w: node %d has the wrong type", ErrMalformed, x)
+		}
+		if node.Parent != nil && len(node.Parent.EncryptionKey) != secretSize {
+			return nil, fmt.Errorf("%w: node %d has an invalid key", ErrMalformed, x)
+		}
+		if node.Leaf != nil {
+			if err := node.Leaf.Verify(); err != nil {
+				return nil, fmt.Errorf("leaf %d: %w", x/2, err)
+			}
+			if seen[node.Leaf.Identity] {
+				return nil, fmt.Errorf("%w: %s appears twice", ErrAlreadyMember, node.Leaf.Identity)
+			}
+			seen[node.Leaf.Identity] = true
+		}
+	}
+	return t, nil
+}
+
+func (t *ratchetTree) leafCount() uint32 {
+	return uint32(len(t.nodes)+1) / 2
+}
+
+func (t *ratchetTree) blank(x uint32) bool {
+	return t.nodes[x].Leaf == nil && t.nodes[x].Parent == nil
+}
+
+func (t *ratchetTree) leaf(i uint32) *LeafNode {
+	if i >= t.leafCount() {
+		return nil
+	}
+	return t.nodes[leafNode(i)].Leaf
+}
+
+func (t *ratchetTree) publicKey(x uint32) []byte {
+	switch {
+	case t.nodes[x].Leaf != nil:
+		return t.nodes[x].Leaf.EncryptionKey
+	case t.nodes[x].Parent != nil:
+		return t.nodes[x].Parent.EncryptionKey
+	}
+	return nil
+}
+
+func (t *ratchetTree) clone() *ratchetTree {
+	return &ratchetTree{nodes: append([]Node(nil), t.nodes...)}
+}
+
+// find returns the leaf index of identity.
+func (t *ratchetTree) find(identity urn.URN) (uint32, bool) {
+	for i := uint32(0); i < t.leafCount(); i++ {
+		if l := t.leaf(i); l != nil && l.Identity.Equal(identity) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// members returns the identities of every occupied leaf in leaf order.
+func (t *ratchetTree) members() []urn.URN {
+	var ids []urn.URN
+	for i := uint32(0); i < t.leafCount(); i++ {
+		if l := t.leaf(i); l != nil {
+			ids = append(ids, l.Identity)
+		}
+	}
+	return ids
+}
+
+// directPath returns the parents of leaf up to and including the root.
+func (t *ratchetTree) directPath(leaf uint32) []uint32 {
+	var path []uint32
+	r := root(t.leafCount())
+	for x := leafNode(leaf); x != r; {
+		x = parent(x)
+		path = append(path, x)
+	}
+	return path
+}
+
+// resolution returns the non-blank nodes that together cover the subtree
+// at x, leaving out the leaves in exclude.
+func (t *ratchetTree) resolution(x uint32, exclude map[uint32]bool) []uint32 {
+	if !t.blank(x) {
+		if x%2 == 0 && exclude[x/2] {
+			return nil
+		}
+		return []uint32{x}
+	}
+	if x%2 == 0 {
+		return nil
+	}
+	return append(t.resolution(left(x), exclude), t.resolution(right(x), exclude)...)
+}
+
+// pathStep is one entry of a filtered direct path: a parent of the leaf and
+// its child off the path, whose resolution receives the parent's secret.
+type pathStep struct {
+	node, copath uint32
+}
+
+// filteredDirectPath returns the direct path of leaf without the parents
+// whose copath child has an empty resolution.
+func (t *ratchetTree) filteredDirectPath(leaf uint32) []pathStep {
+	var steps []pathStep
+	x := leafNode(leaf)
+	for _, p := range t.directPath(leaf) {
+		if s := sibling(x); len(t.resolution(s, nil)) > 0 {
+			steps = append(steps, pathStep{node: p, copath: s})
+		}
+		x = p
+	}
+	return steps
+}
+
+// blankPath blanks the direct path of leaf.
+func (t *ratchetTree) blankPath(leaf uint32) {
+	for _, p := range t.directPath(leaf) {
+		t.nodes[p] = Node{}
+	}
+}
+
+// addLeaf places l in the leftmost blank leaf, doubling the tree if it is
+// full, and blanks its direct path.
+func (t *ratchetTree) addLeaf(l *LeafNode) uint32 {
+	i := uint32(0)
+	for ; i < t.leafCount(); i++ {
+		if t.leaf(i) == nil {
+			break
+		}
+	}
+	if i == t.leafCount() {
+		t.nodes = append(t.nodes, make([]Node, len(t.nodes)+1)...)
+	}
+	t.nodes[leafNode(i)] = Node{Leaf: l}
+	t.blankPath(i)
+	return i
+}
+
+// removeLeaf blanks leaf and its direct path.
+func (t *ratchetTree) removeLeaf(leaf uint32) {
+	t.nodes[leafNode(leaf)] = Node{}
+	t.blankPath(leaf)
+}
+
+// truncate halves the tree while its right half has no members.
+func (t *ratchetTree) truncate() {
+	for n := t.leafCount(); n > 1; n = t.leafCount() {
+		for i := n / 2; i < n; i++ {
+			if t.leaf(i) != nil {
+				return
+			}
+		}
+		t.nodes = t.nodes[:nodeWidth(n/2)]
+	}
+}
+
+// hash is the tree hash: a hash over every node's public contents that
+// members fold into the group context so that they agree on the tree.
+func (t *ratchetTree) hash() []byte {
+	return t.nodeHash(root(t.leafCount()))
+}
+
+func (t *ratchetTree) nodeHash(x uint32) []byte {
+	b := binary.BigEndian.AppendUint32(nil, x)
+	if x%2 == 0 {
+		b = append(b, 0x01)
+		if l := t.nodes[x].Leaf; l != nil {
+			b = append(b, 0x01)
+			b = appendField(b, l.tbs())
+			b = appendField(b, l.Signature)
+		}
+		return hash(b)
+	}
+	b = append(b, 0x02)
+	if p := t.nodes[x].Parent; p != nil {
+		b = append(b, 0x01)
+		b = appendField(b, p.EncryptionKey)
+	}
+	return hash(b, t.nodeHash(left(x)), t.nodeHash(right(x)))
+}
@@ -0,0 +1,177 @@
+package mls
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/groupenv"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// Welcome lets the members added by a commit join the new epoch. The group
+// state is encrypted once with a key from the key schedule; the secrets
+// needed to decrypt it are encrypted to each new member's init key.
+type Welcome struct {
+	Secrets            []EncryptedGroupSecrets `json:"secrets"`
+	EncryptedGroupInfo []byte                  `json:"encryptedGroupInfo"`
+}
+
+// EncryptedGroupSecrets holds one new member's secrets, found by the
+// reference of the key package they were added with.
+type EncryptedGroupSecrets struct {
+	KeyPackageRef []byte         `json:"keyPackageRef"`
+	Secrets       HPKECiphertext `json:"secrets"`
+}
+
+// groupSecrets are the secrets a new member needs: the joiner secret for
+// the key schedule and the path secret of the lowest node it shares with
+// the committer.
+type groupSecrets struct {
+	JoinerSecret []byte `json:"joinerSecret"`
+	PathSecret   []byte `json:"pathSecret"`
+}
+
+// groupInfo is the public state of the new epoch, signed by the committer.
+type groupInfo struct {
+	GroupID                 urn.URN `json:"groupId"`
+	Epoch                   uint64  `json:"epoch"`
+	Tree                    []Node  `json:"tree"`
+	ConfirmedTranscriptHash []byte  `json:"confirmedTranscriptHash"`
+	ConfirmationTag         []byte  `json:"confirmationTag"`
+	Signer                  uint32  `json:"signer"`
+	Signature               []byte  `json:"signature"`
+}
+
+func (info *groupInfo) tbs(treeHash []byte) []byte {
+	ctx := groupContext{groupID: info.GroupID, epoch: info.Epoch, treeHash: treeHash, confirmedTranscriptHash: info.ConfirmedTranscriptHash}
+	b := appendField(nil, ctx.marshal())
+	b = appendField(b, info.ConfirmationTag)
+	return binary.BigEndian.AppendUint32(b, info.Signer)
+}
+
+func newWelcome(next *epochState, signer uint32, signingKey ed25519.PrivateKey, joiner []byte, kps []*KeyPackage, added []uint32, steps []pathStep, pathSecrets [][]byte, tag []byte) (*Welcome, error) {
+	info := &groupInfo{
+		GroupID:                 next.ctx.groupID,
+		Epoch:                   next.ctx.epoch,
+		Tree:                    next.tree.nodes,
+		ConfirmedTranscriptHash: next.ctx.confirmedTranscriptHash,
+		ConfirmationTag:         tag,
+		Signer:                  signer,
+	}
+	info.Signature = signWithLabel(signingKey, "GroupInfoTBS", info.tbs(next.ctx.treeHash))
+	plaintext, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	key, nonce := welcomeKey(joiner)
+	encryptedInfo, err := sealAEAD(key, nonce, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Welcome{EncryptedGroupInfo: encryptedInfo}
+	for k, kp := range kps {
+		i := slices.IndexFunc(steps, func(st pathStep) bool { return inSubtree(st.copath, leafNode(added[k])) })
+		secrets, err := json.Marshal(groupSecrets{JoinerSecret: joiner, PathSecret: pathSecrets[i]})
+		if err != nil {
+			return nil, err
+		}
+		ct, err := encryptWithLabel(kp.InitKey, "Welcome", encryptedInfo, secrets)
+		if err != nil {
+			return nil, err
+		}
+		w.Secrets = append(w.Secrets, EncryptedGroupSecrets{KeyPackageRef: kp.Ref(), Secrets: ct})
+	}
+	return w, nil
+}
+
+func welcomeKey(joiner []byte) ([]byte, []byte) {
+	secret := welcomeSecret(joiner)
+	return expandWithLabel(secret, "key", nil, secretSize), expandWithLabel(secret, "nonce", nil, nonceSize)
+}
+
+// Join joins a group from a Welcome that added kp, using the key package's
+// private keys. It verifies the tree, the committer's signature and the
+// confirmation tag before returning the member's group state.
+func Join(w *Welcome, kp *KeyPackage, keys *KeyPackageKeys, opts ...Option) (*Group, error) {
+	o := groupenv.NewOptions(opts, DefaultMaxSkip)
+	if w == nil {
+		return nil, errors.New("cannot join from a nil welcome")
+	}
+	if err := kp.Verify(); err != nil {
+		return nil, err
+	}
+	if !keys.matches(kp) {
+		return nil, errors.New("keys do not match the key package")
+	}
+
+	ref := kp.Ref()
+	i := slices.IndexFunc(w.Secrets, func(s EncryptedGroupSecrets) bool { return hmac.Equal(s.KeyPackageRef, ref) })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: welcome is not for this key package", ErrNotMember)
+	}
+	plaintext, err := decryptWithLabel(keys.InitKey, "Welcome", w.EncryptedGroupInfo, w.Secrets[i].Secrets)
+	if err != nil {
+		return nil, err
+	}
+	var secrets groupSecrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil || len(secrets.JoinerSecret) != secretSize || len(secrets.PathSecret) != secretSize {
+		return nil, fmt.Errorf("%w: invalid group secrets", ErrMalformed)
+	}
+
+	key, nonce := welcomeKey(secrets.JoinerSecret)
+	plaintext, err = openAEAD(key, nonce, w.EncryptedGroupInfo, nil)
+	if err != nil {
+		return nil, err
+	}
+	var info groupInfo
+	if err := json.Unmarshal(plaintext, &info); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	tree, err := treeFromNodes(info.Tree)
+	if err != nil {
+		return nil, err
+	}
+	signer := tree.leaf(info.Signer)
+	if signer == nil {
+		return nil, fmt.Errorf("%w: no member at signer leaf %d", ErrMalformed, info.Signer)
+	}
+	ctx := groupContext{groupID: info.GroupID, epoch: info.Epoch, treeHash: tree.hash(), confirmedTranscriptHash: info.ConfirmedTranscriptHash}
+	if err := verifyWithLabel(signer.SigningKey, "GroupInfoTBS", info.tbs(ctx.treeHash), info.Signature); err != nil {
+		return nil, err
+	}
+
+	self, ok := tree.find(kp.Leaf.Identity)
+	if !ok || string(tree.leaf(self).EncryptionKey) != string(kp.Leaf.EncryptionKey) {
+		return nil, fmt.Errorf("%w: key package is not in the tree", ErrInvalidCommit)
+	}
+	steps := tree.filteredDirectPath(info.Signer)
+	j := slices.IndexFunc(steps, func(st pathStep) bool { return inSubtree(st.copath, leafNode(self)) })
+	if j < 0 {
+		return nil, fmt.Errorf("%w: committer's path does not cover this member", ErrInvalidCommit)
+	}
+	privs := map[uint32]*ecdh.PrivateKey{leafNode(self): keys.EncryptionKey}
+	if _, err := applyPathSecret(tree, steps[j:], secrets.PathSecret, privs); err != nil {
+		return nil, err
+	}
+
+	epochSecrets := newEpochSecrets(secrets.JoinerSecret, ctx)
+	if !hmac.Equal(epochSecrets.confirmationTag(ctx.confirmedTranscriptHash), info.ConfirmationTag) {
+		return nil, fmt.Errorf("%w: confirmation tag mismatch", ErrInvalidCommit)
+	}
+	state := &epochState{
+		ctx:     ctx,
+		tree:    tree,
+		privs:   privs,
+		secrets: epochSecrets,
+		interim: interimTranscriptHash(ctx.confirmedTranscriptHash, info.ConfirmationTag),
+		app:     newAppKeys(ctx.epoch, epochSecrets.encryption, tree),
+	}
+	return &Group{maxSkip: o.MaxSkip, self: self, signingKey: keys.SigningKey, state: state}, nil
+}
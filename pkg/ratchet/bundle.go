@@ -0,0 +1,33 @@
+package ratchet
+
+import (
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+)
+
+// PeerKeysFromBundle verifies a prekey bundle and converts it into the keys
+// Initiate needs.
+func PeerKeysFromBundle(b *transport.PreKeyBundle) (PeerKeys, error) {
+	if err := b.Verify(); err != nil {
+		return PeerKeys{}, err
+	}
+	identity, err := b.IdentityKey.ECDH()
+	if err != nil {
+		return PeerKeys{}, err
+	}
+	signedPreKey, err := b.SignedPreKey.ECDH()
+	if err != nil {
+		return PeerKeys{}, err
+	}
+	keys := PeerKeys{
+		IdentityKey:    identity,
+		SignedPreKeyID: b.SignedPreKey.ID,
+		SignedPreKey:   signedPreKey,
+	}
+	if b.OneTimePreKey != nil {
+		if keys.OneTimePreKey, err = b.OneTimePreKey.ECDH(); err != nil {
+			return PeerKeys{}, err
+		}
+		keys.OneTimePreKeyID = b.OneTimePreKey.ID
+	}
+	return keys, nil
+}
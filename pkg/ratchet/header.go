@@ -0,0 +1,117 @@
+package ratchet
+
+import (
+	"crypto/ecdh"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+)
+
+// Ratchet envelopes carry their message header in EncryptedSymmetricKey:
+//
+//	version (1) | flags (1) | [prekey message (72)] | DH key (32) | PN (4) | N (4)
+//
+// where the optional prekey message is the initiator's identity key (32),
+// ephemeral key (32), signed prekey ID (4) and one-time prekey ID (4).
+// Integers are big-endian.
+const (
+	headerVersion  = 0x01
+	flagPreKey     = 0x01
+	preKeySize     = 2*keySize + 8
+	ratchetKeySize = keySize + 8
+)
+
+// ErrNotPreKeyMessage is returned by ParsePreKeyMessage when an envelope
+// belongs to an established session rather than starting a new one.
+var ErrNotPreKeyMessage = errors.New("not a prekey message")
+
+type messageHeader struct {
+	dh     *ecdh.PublicKey
+	pn     uint32
+	n      uint32
+	preKey *PreKeyMessage
+}
+
+func (h messageHeader) marshal() []byte {
+	b := make([]byte, 0, 2+preKeySize+ratchetKeySize)
+	b = append(b, headerVersion, 0)
+	if h.preKey != nil {
+		b[1] |= flagPreKey
+		b = append(b, h.preKey.IdentityKey.Bytes()...)
+		b = append(b, h.preKey.EphemeralKey.Bytes()...)
+		b = binary.BigEndian.AppendUint32(b, h.preKey.SignedPreKeyID)
+		b = binary.BigEndian.AppendUint32(b, h.preKey.OneTimePreKeyID)
+	}
+	b = append(b, h.dh.Bytes()...)
+	b = binary.BigEndian.AppendUint32(b, h.pn)
+	return binary.BigEndian.AppendUint32(b, h.n)
+}
+
+func parseHeader(b []byte) (messageHeader, error) {
+	var h messageHeader
+	if len(b) < 2 || b[0] != headerVersion || b[1]&^flagPreKey != 0 {
+		return h, fmt.Errorf("%w: unsupported header", ErrMalformed)
+	}
+	size := 2 + ratchetKeySize
+	if b[1]&flagPreKey != 0 {
+		size += preKeySize
+	}
+	if len(b) != size {
+		return h, fmt.Errorf("%w: header is %d bytes, expected %d", ErrMalformed, len(b), size)
+	}
+	b = b[2:]
+
+	if size > 2+ratchetKeySize {
+		identity, err := ecdh.X25519().NewPublicKey(b[:keySize])
+		if err != nil {
+			return h, fmt.Errorf("%w: identity key: %w", ErrMalformed, err)
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(b[keySize : 2*keySize])
+		if err != nil {
+			return h, fmt.Errorf("%w: ephemeral key: %w", ErrMalformed, err)
+		}
+		h.preKey = &PreKeyMessage{
+			IdentityKey:     identity,
+			EphemeralKey:    ephemeral,
+			SignedPreKeyID:  binary.BigEndian.Uint32(b[2*keySize:]),
+			OneTimePreKeyID: binary.BigEndian.Uint32(b[2*keySize+4:]),
+		}
+		b = b[preKeySize:]
+	}
+
+	dh, err := ecdh.X25519().NewPublicKey(b[:keySize])
+	if err != nil {
+		return h, fmt.Errorf("%w: ratchet key: %w", ErrMalformed, err)
+	}
+	h.dh = dh
+	h.pn = binary.BigEndian.Uint32(b[keySize:])
+	h.n = binary.BigEndian.Uint32(b[keySize+4:])
+	return h, nil
+}
+
+// ParsePreKeyMessage returns the X3DH data of an envelope that starts a new
+// session, so the responder can look up the prekeys it names before calling
+// Respond. It returns ErrNotPreKeyMessage for other ratchet envelopes.
+func ParsePreKeyMessage(env *transport.SecureEnvelope) (*PreKeyMessage, error) {
+	if env == nil {
+		return nil, errors.New("cannot parse a nil envelope")
+	}
+	if env.Suite != transport.SuiteX3DHDoubleRatchetAES256GCMEd25519 {
+		return nil, fmt.Errorf("%w: envelope suite is %s", ErrMalformed, env.Suite)
+	}
+	h, err := parseHeader(env.EncryptedSymmetricKey)
+	if err != nil {
+		return nil, err
+	}
+	if h.preKey == nil {
+		return nil, ErrNotPreKeyMessage
+	}
+	return h.preKey, nil
+}
+
+func appendField(b, f []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(f)))
+	return append(b, f...)
+}
@@ -0,0 +1,507 @@
+// Package ratchet implements one-to-one sessions with forward secrecy and
+// post-compromise security on top of SecureEnvelope.
+//
+// A session starts with an X3DH key agreement: the initiator combines its
+// identity key and a fresh ephemeral key with the responder's published
+// identity, signed prekey and optional one-time prekey. The resulting secret
+// seeds a Double Ratchet, which derives a new key for every message and
+// mixes in a fresh Diffie-Hellman exchange each time the direction of the
+// conversation changes.
+//
+// Ratchet envelopes are marked with
+// transport.SuiteX3DHDoubleRatchetAES256GCMEd25519. The ratchet header
+// travels in EncryptedSymmetricKey and the message in EncryptedData. The
+// envelope's routed fields are bound to the ciphertext, and envelopes are
+// returned unsigned so that the caller can sign them with transport.Sign.
+package ratchet
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/chainkdf"
+	"github.com/illmade-knight/go-secure-messaging/pkg/messageid"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+const (
+	rootInfo    = "go-secure-messaging/ratchet/v1 root"
+	messageInfo = "go-secure-messaging/ratchet/v1 message"
+	adContext   = "go-secure-messaging/ratchet/v1 ad"
+
+	// DefaultMaxSkip is the default limit on how far ahead of the last
+	// received message a single chain may skip.
+	DefaultMaxSkip = 1000
+	// DefaultMaxSkippedKeys is the default limit on the number of skipped
+	// message keys a session stores. The oldest are discarded first.
+	DefaultMaxSkippedKeys = 2000
+)
+
+var (
+	// ErrDecryptionFailed is returned when a message does not authenticate,
+	// including when any of its routed fields were altered, or when its key
+	// has already been used or discarded.
+	ErrDecryptionFailed = errors.New("decryption failed")
+	// ErrMalformed is returned when an envelope is not a well-formed ratchet
+	// message.
+	ErrMalformed = errors.New("malformed ratchet message")
+	// ErrTooManySkipped is returned when a message would require skipping
+	// more message keys than the session allows.
+	ErrTooManySkipped = errors.New("too many skipped messages")
+	// ErrSessionMismatch is returned when an envelope's sender or recipient
+	// do not match the session.
+	ErrSessionMismatch = errors.New("envelope does not belong to this session")
+	// ErrCannotSend is returned when a responder session tries to encrypt
+	// before it has received a message.
+	ErrCannotSend = errors.New("session has no sending chain yet")
+)
+
+// Option configures a session or a single Encrypt call.
+type Option func(*options)
+
+type options struct {
+	messageID      string
+	conversationID urn.URN
+	maxSkip        int
+	maxSkippedKeys int
+}
+
+// WithMessageID sets the envelope's MessageID. If it is not given, Encrypt
+// generates a time-ordered one with messageid.New.
+func WithMessageID(id string) Option {
+	return func(o *options) { o.messageID = id }
+}
+
+// WithConversationID sets the envelope's ConversationID.
+func WithConversationID(id urn.URN) Option {
+	return func(o *options) { o.conversationID = id }
+}
+
+// WithMaxSkip sets the session's per-chain skip limit when passed to
+// Initiate or Respond. The default is DefaultMaxSkip.
+func WithMaxSkip(n int) Option {
+	return func(o *options) { o.maxSkip = n }
+}
+
+// WithMaxSkippedKeys sets how many skipped message keys the session keeps
+// when passed to Initiate or Respond. The default is DefaultMaxSkippedKeys.
+func WithMaxSkippedKeys(n int) Option {
+	return func(o *options) { o.maxSkippedKeys = n }
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{maxSkip: DefaultMaxSkip, maxSkippedKeys: DefaultMaxSkippedKeys}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// skippedKey identifies a message key stored for an out-of-order message.
+type skippedKey struct {
+	dh [keySize]byte
+	n  uint32
+}
+
+// Session is one side of a Double Ratchet session between local and remote.
+// It is not safe for concurrent use; persist it with a SessionStore after
+// every Encrypt and successful Decrypt.
+type Session struct {
+	local, remote  urn.URN
+	remoteIdentity *ecdh.PublicKey
+	ad             []byte
+	maxSkip        int
+	maxSkippedKeys int
+
+	rootKey    []byte
+	dhSelf     *ecdh.PrivateKey
+	dhRemote   *ecdh.PublicKey
+	sendChain  []byte
+	recvChain  []byte
+	ns, nr, pn uint32
+
+	skipped      map[skippedKey][]byte
+	skippedOrder []skippedKey
+
+	// preKey is sent with every message until the first reply arrives.
+	preKey *PreKeyMessage
+}
+
+// LocalID returns the URN of this side of the session.
+func (s *Session) LocalID() urn.URN {
+	return s.local
+}
+
+// RemoteID returns the URN of the other side of the session.
+func (s *Session) RemoteID() urn.URN {
+	return s.remote
+}
+
+// RemoteIdentity returns the remote party's X25519 identity key.
+func (s *Session) RemoteIdentity() *ecdh.PublicKey {
+	return s.remoteIdentity
+}
+
+// SkippedKeys returns the number of stored message keys for messages that
+// have not arrived yet.
+func (s *Session) SkippedKeys() int {
+	return len(s.skippedOrder)
+}
+
+// initSender completes the initiator's setup: the responder's signed prekey
+// acts as its first ratchet key.
+func (s *Session) initSender(sk []byte, remoteRatchetKey *ecdh.PublicKey) error {
+	dhSelf, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	dh, err := dhSelf.ECDH(remoteRatchetKey)
+	if err != nil {
+		return fmt.Errorf("%w: key agreement failed: %w", ErrMalformed, err)
+	}
+	s.dhSelf, s.dhRemote = dhSelf, remoteRatchetKey
+	s.rootKey, s.sendChain, err = kdfRoot(sk, dh)
+	return err
+}
+
+// Encrypt advances the sending chain and returns plaintext sealed in an
+// unsigned envelope from LocalID to RemoteID.
+func (s *Session) Encrypt(plaintext []byte, opts ...Option) (*transport.SecureEnvelope, error) {
+	if s.sendChain == nil {
+		return nil, ErrCannotSend
+	}
+	o := newOptions(opts)
+	messageID := o.messageID
+	if messageID == "" {
+		var err error
+		if messageID, err = messageid.NewString(); err != nil {
+			return nil, err
+		}
+	}
+
+	h := messageHeader{dh: s.dhSelf.PublicKey(), pn: s.pn, n: s.ns, preKey: s.preKey}
+	env := &transport.SecureEnvelope{
+		Suite:                 transport.SuiteX3DHDoubleRatchetAES256GCMEd25519,
+		MessageID:             messageID,
+		SenderID:              s.local,
+		RecipientID:           s.remote,
+		ConversationID:        o.conversationID,
+		EncryptedSymmetricKey: h.marshal(),
+	}
+
+	nextChain, messageKey := chainkdf.Next(s.sendChain)
+	ciphertext, err := sealMessage(messageKey, plaintext, s.messageAD(env))
+	if err != nil {
+		return nil, err
+	}
+	s.sendChain = nextChain
+	s.ns++
+	env.EncryptedData = ciphertext
+	return env, nil
+}
+
+// Decrypt opens an envelope from RemoteID to LocalID. The session is only
+// updated if decryption succeeds, so a forged or corrupted envelope cannot
+// desynchronize it.
+func (s *Session) Decrypt(env *transport.SecureEnvelope) ([]byte, error) {
+	if env == nil {
+		return nil, errors.New("cannot decrypt a nil envelope")
+	}
+	if env.Suite != transport.SuiteX3DHDoubleRatchetAES256GCMEd25519 {
+		return nil, fmt.Errorf("%w: envelope suite is %s", ErrMalformed, env.Suite)
+	}
+	if !env.SenderID.Equal(s.remote) || !env.RecipientID.Equal(s.local) {
+		return nil, fmt.Errorf("%w: envelope from %s to %s", ErrSessionMismatch, env.SenderID, env.RecipientID)
+	}
+	h, err := parseHeader(env.EncryptedSymmetricKey)
+	if err != nil {
+		return nil, err
+	}
+	ad := s.messageAD(env)
+
+	if key, ok := s.skipped[skippedID(h.dh, h.n)]; ok {
+		plaintext, err := openMessage(key, env.EncryptedData, ad)
+		if err != nil {
+			return nil, err
+		}
+		s.removeSkipped(skippedID(h.dh, h.n))
+		return plaintext, nil
+	}
+
+	next := s.clone()
+	if next.dhRemote == nil || !h.dh.Equal(next.dhRemote) {
+		if err := next.skipMessageKeys(h.pn); err != nil {
+			return nil, err
+		}
+		if err := next.dhRatchet(h.dh); err != nil {
+			return nil, err
+		}
+	}
+	if err := next.skipMessageKeys(h.n); err != nil {
+		return nil, err
+	}
+	var messageKey []byte
+	next.recvChain, messageKey = chainkdf.Next(next.recvChain)
+	next.nr++
+
+	plaintext, err := openMessage(messageKey, env.EncryptedData, ad)
+	if err != nil {
+		return nil, err
+	}
+	// Any reply proves the responder has set up the session.
+	next.preKey = nil
+	*s = *next
+	return plaintext, nil
+}
+
+// skipMessageKeys stores the keys of receiving-chain messages up to, but not
+// including, until.
+func (s *Session) skipMessageKeys(until uint32) error {
+	if s.recvChain == nil {
+		return nil
+	}
+	if until > s.nr && int64(until)-int64(s.nr) > int64(s.maxSkip) {
+		return fmt.Errorf("%w: %d messages in one chain", ErrTooManySkipped, until-s.nr)
+	}
+	for s.nr < until {
+		var messageKey []byte
+		s.recvChain, messageKey = chainkdf.Next(s.recvChain)
+		s.addSkipped(skippedID(s.dhRemote, s.nr), messageKey)
+		s.nr++
+	}
+	return nil
+}
+
+func (s *Session) dhRatchet(remote *ecdh.PublicKey) error {
+	s.pn, s.ns, s.nr = s.ns, 0, 0
+	s.dhRemote = remote
+
+	dh, err := s.dhSelf.ECDH(remote)
+	if err != nil {
+		return fmt.Errorf("%w: key agreement failed: %w", ErrMalformed, err)
+	}
+	if s.rootKey, s.recvChain, err = kdfRoot(s.rootKey, dh); err != nil {
+		return err
+	}
+
+	if s.dhSelf, err = ecdh.X25519().GenerateKey(rand.Reader); err != nil {
+		return err
+	}
+	if dh, err = s.dhSelf.ECDH(remote); err != nil {
+		return fmt.Errorf("%w: key agreement failed: %w", ErrMalformed, err)
+	}
+	s.rootKey, s.sendChain, err = kdfRoot(s.rootKey, dh)
+	return err
+}
+
+func (s *Session) addSkipped(id skippedKey, messageKey []byte) {
+	if s.skipped == nil {
+		s.skipped = make(map[skippedKey][]byte)
+	}
+	s.skipped[id] = messageKey
+	s.skippedOrder = append(s.skippedOrder, id)
+	for len(s.skippedOrder) > s.maxSkippedKeys {
+		delete(s.skipped, s.skippedOrder[0])
+		s.skippedOrder = s.skippedOrder[1:]
+	}
+}
+
+func (s *Session) removeSkipped(id skippedKey) {
+	delete(s.skipped, id)
+	for i, k := range s.skippedOrder {
+		if k == id {
+			s.skippedOrder = append(s.skippedOrder[:i:i], s.skippedOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// clone returns a copy of s that can be modified without affecting s.
+func (s *Session) clone() *Session {
+	c := *s
+	c.skipped = make(map[skippedKey][]byte, len(s.skipped))
+	for k, v := range s.skipped {
+		c.skipped[k] = v
+	}
+	c.skippedOrder = append([]skippedKey(nil), s.skippedOrder...)
+	return &c
+}
+
+// messageAD binds the session's identities, the ratchet header and the
+// envelope's routed fields to the ciphertext.
+func (s *Session) messageAD(env *transport.SecureEnvelope) []byte {
+	b := []byte(adContext)
+	b = appendField(b, s.ad)
+	b = appendField(b, env.EncryptedSymmetricKey)
+	b = binary.BigEndian.AppendUint16(b, uint16(env.Suite))
+	b = appendField(b, []byte(env.MessageID))
+	b = appendField(b, []byte(env.SenderID.String()))
+	b = appendField(b, []byte(env.RecipientID.String()))
+	b = appendField(b, []byte(env.GroupID.String()))
+	return appendField(b, []byte(env.ConversationID.String()))
+}
+
+func skippedID(dh *ecdh.PublicKey, n uint32) skippedKey {
+	id := skippedKey{n: n}
+	copy(id.dh[:], dh.Bytes())
+	return id
+}
+
+// kdfRoot advances the root chain with a DH output and returns the new root
+// key and a new chain key.
+func kdfRoot(rootKey, dh []byte) ([]byte, []byte, error) {
+	out, err := hkdf.Key(sha256.New, dh, rootKey, rootInfo, 2*keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out[:keySize], out[keySize:], nil
+}
+
+func sealMessage(messageKey, plaintext, ad []byte) ([]byte, error) {
+	aead, nonce, err := chainkdf.MessageAEAD(messageKey, messageInfo)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nonce, plaintext, ad), nil
+}
+
+func openMessage(messageKey, ciphertext, ad []byte) ([]byte, error) {
+	aead, nonce, err := chainkdf.MessageAEAD(messageKey, messageInfo)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// --- Serialization ---
+
+// sessionState is the serialized form of a Session.
+type sessionState struct {
+	Local          urn.URN        `json:"local"`
+	Remote         urn.URN        `json:"remote"`
+	RemoteIdentity []byte         `json:"remoteIdentity"`
+	AD             []byte         `json:"ad"`
+	MaxSkip        int            `json:"maxSkip"`
+	MaxSkippedKeys int            `json:"maxSkippedKeys"`
+	RootKey        []byte         `json:"rootKey"`
+	DHSelf         []byte         `json:"dhSelf"`
+	DHRemote       []byte         `json:"dhRemote,omitempty"`
+	SendChain      []byte         `json:"sendChain,omitempty"`
+	RecvChain      []byte         `json:"recvChain,omitempty"`
+	Ns             uint32         `json:"ns"`
+	Nr             uint32         `json:"nr"`
+	PN             uint32         `json:"pn"`
+	Skipped        []skippedState `json:"skipped,omitempty"`
+	PreKey         []byte         `json:"preKey,omitempty"`
+}
+
+type skippedState struct {
+	DH  []byte `json:"dh"`
+	N   uint32 `json:"n"`
+	Key []byte `json:"key"`
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The result contains
+// private key material and must be stored accordingly.
+func (s *Session) MarshalBinary() ([]byte, error) {
+	st := sessionState{
+		Local:          s.local,
+		Remote:         s.remote,
+		RemoteIdentity: s.remoteIdentity.Bytes(),
+		AD:             s.ad,
+		MaxSkip:        s.maxSkip,
+		MaxSkippedKeys: s.maxSkippedKeys,
+		RootKey:        s.rootKey,
+		DHSelf:         s.dhSelf.Bytes(),
+		SendChain:      s.sendChain,
+		RecvChain:      s.recvChain,
+		Ns:             s.ns,
+		Nr:             s.nr,
+		PN:             s.pn,
+	}
+	if s.dhRemote != nil {
+		st.DHRemote = s.dhRemote.Bytes()
+	}
+	for _, id := range s.skippedOrder {
+		st.Skipped = append(st.Skipped, skippedState{DH: id.dh[:], N: id.n, Key: s.skipped[id]})
+	}
+	if s.preKey != nil {
+		// The prekey message is stored in its header encoding, with a
+		// placeholder ratchet key that is dropped again on load.
+		st.PreKey = messageHeader{dh: s.dhSelf.PublicKey(), preKey: s.preKey}.marshal()
+	}
+	return json.Marshal(st)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *Session) UnmarshalBinary(data []byte) error {
+	var st sessionState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("failed to decode session: %w", err)
+	}
+	x := ecdh.X25519()
+	remoteIdentity, err := x.NewPublicKey(st.RemoteIdentity)
+	if err != nil {
+		return fmt.Errorf("failed to decode session remote identity: %w", err)
+	}
+	dhSelf, err := x.NewPrivateKey(st.DHSelf)
+	if err != nil {
+		return fmt.Errorf("failed to decode session ratchet key: %w", err)
+	}
+	var dhRemote *ecdh.PublicKey
+	if st.DHRemote != nil {
+		if dhRemote, err = x.NewPublicKey(st.DHRemote); err != nil {
+			return fmt.Errorf("failed to decode session remote ratchet key: %w", err)
+		}
+	}
+	var preKey *PreKeyMessage
+	if st.PreKey != nil {
+		h, err := parseHeader(st.PreKey)
+		if err != nil {
+			return fmt.Errorf("failed to decode session prekey message: %w", err)
+		}
+		if h.preKey == nil {
+			return fmt.Errorf("%w: session prekey message has no prekey data", ErrMalformed)
+		}
+		preKey = h.preKey
+	}
+
+	*s = Session{
+		local:          st.Local,
+		remote:         st.Remote,
+		remoteIdentity: remoteIdentity,
+		ad:             st.AD,
+		maxSkip:        st.MaxSkip,
+		maxSkippedKeys: st.MaxSkippedKeys,
+		rootKey:        st.RootKey,
+		dhSelf:         dhSelf,
+		dhRemote:       dhRemote,
+		sendChain:      st.SendChain,
+		recvChain:      st.RecvChain,
+		ns:             st.Ns,
+		nr:             st.Nr,
+		pn:             st.PN,
+		preKey:         preKey,
+	}
+	for _, sk := range st.Skipped {
+		if len(sk.DH) != keySize {
+			return errors.New("failed to decode session: invalid skipped key")
+		}
+		var id skippedKey
+		copy(id.dh[:], sk.DH)
+		id.n = sk.N
+		s.addSkipped(id, sk.Key)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package ratchet_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/ratchet"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConversation(t *testing.T) {
+	alice, bob := newParty(t, "urn:sm:user:alice"), newParty(t, "urn:sm:user:bob")
+	aliceSession, bobSession := establish(t, alice, bob)
+	conversationURN, _ := urn.Parse("urn:sm:conversation:alice-bob")
+
+	// Alternate bursts of messages so the DH ratchet turns several times.
+	senders := []struct {
+		from, to *ratchet.Session
+	}{
+		{bobSession, aliceSession},
+		{aliceSession, bobSession},
+		{aliceSession, bobSession},
+		{bobSession, aliceSession},
+		{aliceSession, bobSession},
+	}
+	for round, s := range senders {
+		for i := 0; i < 3; i++ {
+			text := []byte(fmt.Sprintf("round %d message %d", round, i))
+			env, err := s.from.Encrypt(text, ratchet.WithConversationID(conversationURN), ratchet.WithMessageID(fmt.Sprintf("m-%d-%d", round, i)))
+			require.NoError(t, err)
+			assert.NotContains(t, string(env.EncryptedData), string(text))
+
+			// Envelopes survive the wire format.
+			wire, err := transport.FromProto(transport.ToProto(env))
+			require.NoError(t, err)
+
+			plaintext, err := s.to.Decrypt(wire)
+			require.NoError(t, err)
+			assert.Equal(t, text, plaintext)
+		}
+	}
+}
+
+func TestOutOfOrderDelivery(t *testing.T) {
+	alice, bob := newParty(t, "urn:sm:user:alice"), newParty(t, "urn:sm:user:bob")
+	aliceSession, bobSession := establish(t, alice, bob)
+
+	var envs []*transport.SecureEnvelope
+	for i := 0; i < 5; i++ {
+		env, err := aliceSession.Encrypt([]byte(fmt.Sprintf("msg %d", i)))
+		require.NoError(t, err)
+		envs = append(envs, env)
+	}
+
+	for _, i := range []int{4, 0, 2} {
+		plaintext, err := bobSession.Decrypt(envs[i])
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("msg %d", i), string(plaintext))
+	}
+	assert.Equal(t, 2, bobSession.SkippedKeys(), "keys for messages 1 and 3 remain")
+
+	// A reply turns the ratchet; late messages from the old chain still open.
+	reply, err := bobSession.Encrypt([]byte("got some"))
+	require.NoError(t, err)
+	_, err = aliceSession.Decrypt(reply)
+	require.NoError(t, err)
+	fresh, err := aliceSession.Encrypt([]byte("new chain"))
+	require.NoError(t, err)
+	_, err = bobSession.Decrypt(fresh)
+	require.NoError(t, err)
+
+	for _, i := range []int{3, 1} {
+		plaintext, err := bobSession.Decrypt(envs[i])
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("msg %d", i), string(plaintext))
+	}
+	assert.Zero(t, bobSession.SkippedKeys())
+
+	t.Run("Replay is rejected", func(t *testing.T) {
+		_, err := bobSession.Decrypt(envs[1])
+		assert.ErrorIs(t, err, ratchet.ErrDecryptionFailed)
+		_, err = bobSession.Decrypt(fresh)
+		assert.ErrorIs(t, err, ratchet.ErrDecryptionFailed)
+	})
+}
+
+func TestSkippedKeyLimits(t *testing.T) {
+	alice, bob := newParty(t, "urn:sm:user:alice"), newParty(t, "urn:sm:user:bob")
+
+	t.Run("Max skip per chain", func(t *testing.T) {
+		aliceSession, bobSession := establish(t, alice, bob, ratchet.WithMaxSkip(3))
+		var last *transport.SecureEnvelope
+		for i := 0; i < 5; i++ {
+			var err error
+			last, err = aliceSession.Encrypt([]byte("x"))
+			require.NoError(t, err)
+		}
+		_, err := bobSession.Decrypt(last)
+		assert.ErrorIs(t, err, ratchet.ErrTooManySkipped)
+		assert.Zero(t, bobSession.SkippedKeys(), "a rejected message must not change the session")
+	})
+
+	t.Run("Max stored keys", func(t *testing.T) {
+		aliceSession, bobSession := establish(t, alice, bob, ratchet.WithMaxSkippedKeys(2))
+		var envs []*transport.SecureEnvelope
+		for i := 0; i < 4; i++ {
+			env, err := aliceSession.Encrypt([]byte(fmt.Sprintf("msg %d", i)))
+			require.NoError(t, err)
+			envs = append(envs, env)
+		}
+		_, err := bobSession.Decrypt(envs[3])
+		require.NoError(t, err)
+		assert.Equal(t, 2, bobSession.SkippedKeys())
+
+		// The oldest skipped key was evicted.
+		_, err = bobSession.Decrypt(envs[0])
+		assert.ErrorIs(t, err, ratchet.ErrDecryptionFailed)
+		_, err = bobSession.Decrypt(envs[1])
+		assert.NoError(t, err)
+	})
+}
+
+func TestTamperedEnvelopes(t *testing.T) {
+	alice, bob := newParty(t, "urn:sm:user:alice"), newParty(t, "urn:sm:user:bob")
+	aliceSession, bobSession := establish(t, alice, bob)
+	otherConversationURN, _ := urn.Parse("urn:sm:conversation:other")
+
+	testCases := []struct {
+		name          string
+		modify        func(env *transport.SecureEnvelope)
+		expectedErrIs error
+	}{
+		{name: "Ciphertext", modify: func(env *transport.SecureEnvelope) { env.EncryptedData[0] ^= 1 }, expectedErrIs: ratchet.ErrDecryptionFailed},
+		{name: "MessageID", modify: func(env *transport.SecureEnvelope) { env.MessageID = "replayed" }, expectedErrIs: ratchet.ErrDecryptionFailed},
+		{name: "ConversationID", modify: func(env *transport.SecureEnvelope) { env.ConversationID = otherConversationURN }, expectedErrIs: ratchet.ErrDecryptionFailed},
+		{name: "Header counter", modify: func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey[len(env.EncryptedSymmetricKey)-1] ^= 1 }, expectedErrIs: ratchet.ErrDecryptionFailed},
+		{name: "Truncated header", modify: func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey = env.EncryptedSymmetricKey[:10] }, expectedErrIs: ratchet.ErrMalformed},
+		{name: "Wrong suite", modify: func(env *transport.SecureEnvelope) { env.Suite = transport.SuiteUnspecified }, expectedErrIs: ratchet.ErrMalformed},
+		{name: "Wrong sender", modify: func(env *transport.SecureEnvelope) { env.SenderID = bob.id }, expectedErrIs: ratchet.ErrSessionMismatch},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env, err := aliceSession.Encrypt([]byte("secret"))
+			require.NoError(t, err)
+			tc.modify(env)
+			_, err = bobSession.Decrypt(env)
+			assert.ErrorIs(t, err, tc.expectedErrIs)
+		})
+	}
+
+	t.Run("Session still works after failures", func(t *testing.T) {
+		env, err := aliceSession.Encrypt([]byte("still here"))
+		require.NoError(t, err)
+		plaintext, err := bobSession.Decrypt(env)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("still here"), plaintext)
+	})
+}
+
+func TestSessionSerialization(t *testing.T) {
+	alice, bob := newParty(t, "urn:sm:user:alice"), newParty(t, "urn:sm:user:bob")
+	aliceSession, err := ratchet.Initiate(alice.id, alice.identity, bob.id, bob.peerKeys(true))
+	require.NoError(t, err)
+
+	// Serialize alice before bob has replied, with pending prekey data.
+	data, err := aliceSession.MarshalBinary()
+	require.NoError(t, err)
+	restored := new(ratchet.Session)
+	require.NoError(t, restored.UnmarshalBinary(data))
+
+	env, err := restored.Encrypt([]byte("first"))
+	require.NoError(t, err)
+	msg, err := ratchet.ParsePreKeyMessage(env)
+	require.NoError(t, err)
+	bobSession, _, err := ratchet.Respond(bob.id, bob.responderKeys(msg), env)
+	require.NoError(t, err)
+
+	// Leave a skipped key in bob's session, then serialize it.
+	skipped, err := restored.Encrypt([]byte("skipped"))
+	require.NoError(t, err)
+	latest, err := restored.Encrypt([]byte("latest"))
+	require.NoError(t, err)
+	_, err = bobSession.Decrypt(latest)
+	require.NoError(t, err)
+
+	data, err = bobSession.MarshalBinary()
+	require.NoError(t, err)
+	restoredBob := new(ratchet.Session)
+	require.NoError(t, restoredBob.UnmarshalBinary(data))
+	assert.Equal(t, 1, restoredBob.SkippedKeys())
+	assert.Equal(t, alice.id, restoredBob.RemoteID())
+	assert.Equal(t, bob.id, restoredBob.LocalID())
+
+	plaintext, err := restoredBob.Decrypt(skipped)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("skipped"), plaintext)
+
+	assert.Error(t, new(ratchet.Session).UnmarshalBinary([]byte("not json")))
+
+	t.Run("Prekey header without prekey data", func(t *testing.T) {
+		data, err := aliceSession.MarshalBinary()
+		require.NoError(t, err)
+		var st map[string]any
+		require.NoError(t, json.Unmarshal(data, &st))
+		header, err := base64.StdEncoding.DecodeString(st["preKey"].(string))
+		require.NoError(t, err)
+		header[1] = 0 // clear the prekey flag
+		st["preKey"] = header[:2+32]
+		data, err = json.Marshal(st)
+		require.NoError(t, err)
+
+		err = new(ratchet.Session).UnmarshalBinary(data)
+		assert.ErrorIs(t, err, ratchet.ErrMalformed)
+		assert.NotContains(t, err.Error(), "%!w")
+	})
+}
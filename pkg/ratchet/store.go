@@ -0,0 +1,78 @@
+package ratchet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// ErrSessionNotFound is returned by a SessionStore when no session exists
+// between the given parties.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore persists sessions, keyed by their local and remote URNs.
+// Implementations typically store Session.MarshalBinary output, which holds
+// private key material and should be encrypted at rest.
+type SessionStore interface {
+	// LoadSession returns the session between local and remote, or an error
+	// wrapping ErrSessionNotFound.
+	LoadSession(ctx context.Context, local, remote urn.URN) (*Session, error)
+	// StoreSession saves s, replacing any session between the same parties.
+	StoreSession(ctx context.Context, s *Session) error
+	// DeleteSession removes the session between local and remote, if any.
+	DeleteSession(ctx context.Context, local, remote urn.URN) error
+}
+
+type sessionKey struct {
+	local, remote urn.URN
+}
+
+// MemorySessionStore is an in-memory SessionStore. It keeps sessions in
+// their serialized form, so callers never share state with the store.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[sessionKey][]byte
+}
+
+// NewMemorySessionStore creates an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[sessionKey][]byte)}
+}
+
+// LoadSession implements SessionStore.
+func (m *MemorySessionStore) LoadSession(_ context.Context, local, remote urn.URN) (*Session, error) {
+	m.mu.Lock()
+	data, ok := m.sessions[sessionKey{local, remote}]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrSessionNotFound, local, remote)
+	}
+	s := new(Session)
+	if err := s.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// StoreSession implements SessionStore.
+func (m *MemorySessionStore) StoreSession(_ context.Context, s *Session) error {
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[sessionKey{s.local, s.remote}] = data
+	return nil
+}
+
+// DeleteSession implements SessionStore.
+func (m *MemorySessionStore) DeleteSession(_ context.Context, local, remote urn.URN) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, sessionKey{local, remote})
+	return nil
+}
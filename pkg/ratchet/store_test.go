@@ -0,0 +1,57 @@
+package ratchet_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/ratchet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	ctx := context.Background()
+	alice, bob := newParty(t, "urn:sm:user:alice"), newParty(t, "urn:sm:user:bob")
+	aliceSession, bobSession := establish(t, alice, bob)
+
+	var store ratchet.SessionStore = ratchet.NewMemorySessionStore()
+
+	_, err := store.LoadSession(ctx, alice.id, bob.id)
+	assert.ErrorIs(t, err, ratchet.ErrSessionNotFound)
+
+	require.NoError(t, store.StoreSession(ctx, aliceSession))
+	require.NoError(t, store.StoreSession(ctx, bobSession))
+
+	// Each message is sent and received through sessions loaded from the
+	// store and saved back afterwards, as a service would.
+	for _, text := range []string{"one", "two", "three"} {
+		sender, err := store.LoadSession(ctx, alice.id, bob.id)
+		require.NoError(t, err)
+		env, err := sender.Encrypt([]byte(text))
+		require.NoError(t, err)
+		require.NoError(t, store.StoreSession(ctx, sender))
+
+		receiver, err := store.LoadSession(ctx, env.RecipientID, env.SenderID)
+		require.NoError(t, err)
+		plaintext, err := receiver.Decrypt(env)
+		require.NoError(t, err)
+		require.NoError(t, store.StoreSession(ctx, receiver))
+		assert.Equal(t, text, string(plaintext))
+	}
+
+	// Advancing a loaded session does not affect the stored copy: a fresh
+	// load reuses the same message number.
+	loaded, err := store.LoadSession(ctx, alice.id, bob.id)
+	require.NoError(t, err)
+	unsaved, err := loaded.Encrypt([]byte("not saved"))
+	require.NoError(t, err)
+	reloaded, err := store.LoadSession(ctx, alice.id, bob.id)
+	require.NoError(t, err)
+	again, err := reloaded.Encrypt([]byte("not saved"))
+	require.NoError(t, err)
+	assert.Equal(t, unsaved.EncryptedSymmetricKey, again.EncryptedSymmetricKey)
+
+	require.NoError(t, store.DeleteSession(ctx, alice.id, bob.id))
+	_, err = store.LoadSession(ctx, alice.id, bob.id)
+	assert.ErrorIs(t, err, ratchet.ErrSessionNotFound)
+}
@@ -0,0 +1,200 @@
+package ratchet
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+const (
+	x3dhInfo = "go-secure-messaging/x3dh/v1"
+	keySize  = 32
+)
+
+// PeerKeys are the public keys of the responder that the initiator needs
+// for X3DH, typically taken from a published prekey bundle after its
+// signature has been verified. All keys are X25519. Prekey IDs must be
+// non-zero; a zero OneTimePreKeyID means no one-time prekey is used.
+type PeerKeys struct {
+	IdentityKey     *ecdh.PublicKey
+	SignedPreKeyID  uint32
+	SignedPreKey    *ecdh.PublicKey
+	OneTimePreKeyID uint32
+	OneTimePreKey   *ecdh.PublicKey
+}
+
+// ResponderKeys are the responder's private keys matching the prekey IDs
+// named in a PreKeyMessage. OneTimePreKey must be set if and only if the
+// message names a one-time prekey, and should be deleted once used.
+type ResponderKeys struct {
+	IdentityKey   *ecdh.PrivateKey
+	SignedPreKey  *ecdh.PrivateKey
+	OneTimePreKey *ecdh.PrivateKey
+}
+
+// PreKeyMessage is the X3DH data the initiator attaches to its messages
+// until the responder replies. Use ParsePreKeyMessage to read it from an
+// envelope so that the matching ResponderKeys can be looked up.
+type PreKeyMessage struct {
+	IdentityKey     *ecdh.PublicKey
+	EphemeralKey    *ecdh.PublicKey
+	SignedPreKeyID  uint32
+	OneTimePreKeyID uint32
+}
+
+// Initiate runs the initiator side of X3DH against peer's keys and returns a
+// session from local to remote. Every envelope the session encrypts carries
+// a PreKeyMessage until the first reply is decrypted.
+func Initiate(local urn.URN, identity *ecdh.PrivateKey, remote urn.URN, peer PeerKeys, opts ...Option) (*Session, error) {
+	o := newOptions(opts)
+	if identity == nil {
+		return nil, errors.New("identity key is required")
+	}
+	if err := checkX25519(identity.PublicKey()); err != nil {
+		return nil, fmt.Errorf("identity key: %w", err)
+	}
+	if peer.IdentityKey == nil || peer.SignedPreKey == nil || peer.SignedPreKeyID == 0 {
+		return nil, errors.New("peer identity key and signed prekey are required")
+	}
+	if (peer.OneTimePreKey == nil) != (peer.OneTimePreKeyID == 0) {
+		return nil, errors.New("peer one-time prekey and its ID must be given together")
+	}
+	for _, k := range []*ecdh.PublicKey{peer.IdentityKey, peer.SignedPreKey, peer.OneTimePreKey} {
+		if k != nil {
+			if err := checkX25519(k); err != nil {
+				return nil, fmt.Errorf("peer key: %w", err)
+			}
+		}
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secrets := []dhPair{
+		{identity, peer.SignedPreKey},
+		{ephemeral, peer.IdentityKey},
+		{ephemeral, peer.SignedPreKey},
+	}
+	if peer.OneTimePreKey != nil {
+		secrets = append(secrets, dhPair{ephemeral, peer.OneTimePreKey})
+	}
+	sk, err := deriveSharedKey(secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		local:          local,
+		remote:         remote,
+		remoteIdentity: peer.IdentityKey,
+		ad:             associatedData(local, identity.PublicKey(), remote, peer.IdentityKey),
+		maxSkip:        o.maxSkip,
+		maxSkippedKeys: o.maxSkippedKeys,
+		preKey: &PreKeyMessage{
+			IdentityKey:     identity.PublicKey(),
+			EphemeralKey:    ephemeral.PublicKey(),
+			SignedPreKeyID:  peer.SignedPreKeyID,
+			OneTimePreKeyID: peer.OneTimePreKeyID,
+		},
+	}
+	if err := s.initSender(sk, peer.SignedPreKey); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Respond runs the responder side of X3DH for the first envelope of a new
+// session, decrypts it and returns the session together with the
+// plaintext. The caller should check Session.RemoteIdentity against the
+// identity key it trusts for env.SenderID.
+func Respond(local urn.URN, keys ResponderKeys, env *transport.SecureEnvelope, opts ...Option) (*Session, []byte, error) {
+	o := newOptions(opts)
+	msg, err := ParsePreKeyMessage(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	if keys.IdentityKey == nil || keys.SignedPreKey == nil {
+		return nil, nil, errors.New("identity key and signed prekey are required")
+	}
+	if err := checkX25519(keys.IdentityKey.PublicKey()); err != nil {
+		return nil, nil, fmt.Errorf("identity key: %w", err)
+	}
+	if (keys.OneTimePreKey == nil) != (msg.OneTimePreKeyID == 0) {
+		return nil, nil, fmt.Errorf("%w: one-time prekey does not match the message", ErrMalformed)
+	}
+
+	secrets := []dhPair{
+		{keys.SignedPreKey, msg.IdentityKey},
+		{keys.IdentityKey, msg.EphemeralKey},
+		{keys.SignedPreKey, msg.EphemeralKey},
+	}
+	if keys.OneTimePreKey != nil {
+		secrets = append(secrets, dhPair{keys.OneTimePreKey, msg.EphemeralKey})
+	}
+	sk, err := deriveSharedKey(secrets)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := &Session{
+		local:          local,
+		remote:         env.SenderID,
+		remoteIdentity: msg.IdentityKey,
+		ad:             associatedData(env.SenderID, msg.IdentityKey, local, keys.IdentityKey.PublicKey()),
+		maxSkip:        o.maxSkip,
+		maxSkippedKeys: o.maxSkippedKeys,
+		rootKey:        sk,
+		dhSelf:         keys.SignedPreKey,
+	}
+	plaintext, err := s.Decrypt(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, plaintext, nil
+}
+
+type dhPair struct {
+	private *ecdh.PrivateKey
+	public  *ecdh.PublicKey
+}
+
+// deriveSharedKey computes each DH in order and derives the X3DH shared key
+// from their concatenation, prefixed with 32 0xFF bytes as the X3DH
+// specification requires for X25519.
+func deriveSharedKey(pairs []dhPair) ([]byte, error) {
+	ikm := make([]byte, keySize, keySize*(len(pairs)+1))
+	for i := range ikm {
+		ikm[i] = 0xFF
+	}
+	for _, p := range pairs {
+		dh, err := p.private.ECDH(p.public)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key agreement failed: %w", ErrMalformed, err)
+		}
+		ikm = append(ikm, dh...)
+	}
+	return hkdf.Key(sha256.New, ikm, make([]byte, sha256.Size), x3dhInfo, keySize)
+}
+
+// associatedData binds a session to both parties' URNs and identity keys.
+func associatedData(initiator urn.URN, initiatorKey *ecdh.PublicKey, responder urn.URN, responderKey *ecdh.PublicKey) []byte {
+	var b []byte
+	b = appendField(b, []byte(initiator.String()))
+	b = appendField(b, initiatorKey.Bytes())
+	b = appendField(b, []byte(responder.String()))
+	return appendField(b, responderKey.Bytes())
+}
+
+func checkX25519(pub *ecdh.PublicKey) error {
+	if pub.Curve() != ecdh.X25519() {
+		return fmt.Errorf("key is on %s, X25519 is required", pub.Curve())
+	}
+	return nil
+}
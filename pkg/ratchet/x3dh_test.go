@@ -0,0 +1,274 @@
+package ratchet_test
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/ratchet"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// party holds the long-term and prekey material of one test participant.
+type party struct {
+	id            urn.URN
+	identity      *ecdh.PrivateKey
+	signedPreKey  *ecdh.PrivateKey
+	oneTimePreKey *ecdh.PrivateKey
+}
+
+func newParty(t *testing.T, id string) *party {
+	t.Helper()
+	u, err := urn.Parse(id)
+	require.NoError(t, err)
+	return &party{
+		id:            u,
+		identity:      mustX25519(t),
+		signedPreKey:  mustX25519(t),
+		oneTimePreKey: mustX25519(t),
+	}
+}
+
+func (p *party) peerKeys(withOneTime bool) ratchet.PeerKeys {
+	keys := ratchet.PeerKeys{
+		IdentityKey:    p.identity.PublicKey(),
+		SignedPreKeyID: 7,
+		SignedPreKey:   p.signedPreKey.PublicKey(),
+	}
+	if withOneTime {
+		keys.OneTimePreKeyID = 42
+		keys.OneTimePreKey = p.oneTimePreKey.PublicKey()
+	}
+	return keys
+}
+
+func (p *party) responderKeys(msg *ratchet.PreKeyMessage) ratchet.ResponderKeys {
+	keys := ratchet.ResponderKeys{IdentityKey: p.identity, SignedPreKey: p.signedPreKey}
+	if msg.OneTimePreKeyID != 0 {
+		keys.OneTimePreKey = p.oneTimePreKey
+	}
+	return keys
+}
+
+func mustX25519(t *testing.T) *ecdh.PrivateKey {
+	t.Helper()
+	k, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return k
+}
+
+// establish runs X3DH between alice and bob and returns both sessions after
+// bob has received alice's first message.
+func establish(t *testing.T, alice, bob *party, opts ...ratchet.Option) (*ratchet.Session, *ratchet.Session) {
+	t.Helper()
+	aliceSession, err := ratchet.Initiate(alice.id, alice.identity, bob.id, bob.peerKeys(true), opts...)
+	require.NoError(t, err)
+
+	env, err := aliceSession.Encrypt([]byte("hello bob"))
+	require.NoError(t, err)
+	msg, err := ratchet.ParsePreKeyMessage(env)
+	require.NoError(t, err)
+
+	bobSession, plaintext, err := ratchet.Respond(bob.id, bob.responderKeys(msg), env, opts...)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello bob"), plaintext)
+	return aliceSession, bobSession
+}
+
+func TestX3DH(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withOneTime bool
+	}{
+		{name: "With one-time prekey", withOneTime: true},
+		{name: "Without one-time prekey", withOneTime: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			alice := newParty(t, "urn:sm:user:alice/device:phone")
+			bob := newParty(t, "urn:sm:user:bob/device:laptop")
+
+			aliceSession, err := ratchet.Initiate(alice.id, alice.identity, bob.id, bob.peerKeys(tc.withOneTime))
+			require.NoError(t, err)
+			env, err := aliceSession.Encrypt([]byte("hi"))
+			require.NoError(t, err)
+
+			assert.Equal(t, transport.SuiteX3DHDoubleRatchetAES256GCMEd25519, env.Suite)
+			assert.Equal(t, alice.id, env.SenderID)
+			assert.Equal(t, bob.id, env.RecipientID)
+
+			msg, err := ratchet.ParsePreKeyMessage(env)
+			require.NoError(t, err)
+			assert.True(t, msg.IdentityKey.Equal(alice.identity.PublicKey()))
+			assert.Equal(t, uint32(7), msg.SignedPreKeyID)
+			if tc.withOneTime {
+				assert.Equal(t, uint32(42), msg.OneTimePreKeyID)
+			} else {
+				assert.Zero(t, msg.OneTimePreKeyID)
+			}
+
+			bobSession, plaintext, err := ratchet.Respond(bob.id, bob.responderKeys(msg), env)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("hi"), plaintext)
+			assert.Equal(t, alice.id, bobSession.RemoteID())
+			assert.True(t, bobSession.RemoteIdentity().Equal(alice.identity.PublicKey()))
+
+			// Once bob replies, alice stops sending prekey messages.
+			reply, err := bobSession.Encrypt([]byte("hi alice"))
+			require.NoError(t, err)
+			_, err = ratchet.ParsePreKeyMessage(reply)
+			assert.ErrorIs(t, err, ratchet.ErrNotPreKeyMessage)
+
+			plaintext, err = aliceSession.Decrypt(reply)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("hi alice"), plaintext)
+
+			next, err := aliceSession.Encrypt([]byte("no more prekeys"))
+			require.NoError(t, err)
+			_, err = ratchet.ParsePreKeyMessage(next)
+			assert.ErrorIs(t, err, ratchet.ErrNotPreKeyMessage)
+		})
+	}
+}
+
+func TestX3DHFailures(t *testing.T) {
+	alice := newParty(t, "urn:sm:user:alice")
+	bob := newParty(t, "urn:sm:user:bob")
+
+	aliceSession, err := ratchet.Initiate(alice.id, alice.identity, bob.id, bob.peerKeys(true))
+	require.NoError(t, err)
+	env, err := aliceSession.Encrypt([]byte("hi"))
+	require.NoError(t, err)
+	msg, err := ratchet.ParsePreKeyMessage(env)
+	require.NoError(t, err)
+
+	t.Run("Wrong signed prekey", func(t *testing.T) {
+		keys := bob.responderKeys(msg)
+		keys.SignedPreKey = mustX25519(t)
+		_, _, err := ratchet.Respond(bob.id, keys, env)
+		assert.ErrorIs(t, err, ratchet.ErrDecryptionFailed)
+	})
+
+	t.Run("Missing one-time prekey", func(t *testing.T) {
+		keys := bob.responderKeys(msg)
+		keys.OneTimePreKey = nil
+		_, _, err := ratchet.Respond(bob.id, keys, env)
+		assert.ErrorIs(t, err, ratchet.ErrMalformed)
+	})
+
+	t.Run("Wrong recipient", func(t *testing.T) {
+		carol := newParty(t, "urn:sm:user:carol")
+		_, _, err := ratchet.Respond(carol.id, bob.responderKeys(msg), env)
+		assert.ErrorIs(t, err, ratchet.ErrSessionMismatch)
+	})
+
+	t.Run("Not a ratchet envelope", func(t *testing.T) {
+		other := *env
+		other.Suite = transport.SuiteX25519AES256GCMEd25519
+		_, err := ratchet.ParsePreKeyMessage(&other)
+		assert.ErrorIs(t, err, ratchet.ErrMalformed)
+	})
+
+	t.Run("Invalid peer keys", func(t *testing.T) {
+		p256, err := ecdh.P256().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		keys := bob.peerKeys(false)
+		keys.SignedPreKey = p256.PublicKey()
+		_, err = ratchet.Initiate(alice.id, alice.identity, bob.id, keys)
+		assert.Error(t, err)
+
+		keys = bob.peerKeys(true)
+		keys.OneTimePreKeyID = 0
+		_, err = ratchet.Initiate(alice.id, alice.identity, bob.id, keys)
+		assert.Error(t, err)
+
+		_, err = ratchet.Initiate(alice.id, nil, bob.id, bob.peerKeys(false))
+		assert.Error(t, err)
+	})
+}
+
+func TestPeerKeysFromBundle(t *testing.T) {
+	alice := newParty(t, "urn:sm:user:alice")
+	bob := newParty(t, "urn:sm:user:bob")
+	signingPub, signing, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	identity := transport.IdentityKey{ID: bob.id, SigningKey: signingPub, DHKey: bob.identity.PublicKey().Bytes()}
+	spk, err := transport.SignPreKey(identity, 7, bob.signedPreKey.PublicKey(), signing)
+	require.NoError(t, err)
+	bundle := &transport.PreKeyBundle{
+		IdentityKey:   identity,
+		SignedPreKey:  spk,
+		OneTimePreKey: &transport.PreKey{ID: 42, PublicKey: bob.oneTimePreKey.PublicKey().Bytes()},
+	}
+
+	peer, err := ratchet.PeerKeysFromBundle(bundle)
+	require.NoError(t, err)
+	assert.Equal(t, bob.peerKeys(true), peer)
+
+	// A session started from the bundle can be answered with bob's keys.
+	aliceSession, err := ratchet.Initiate(alice.id, alice.identity, bundle.ID(), peer)
+	require.NoError(t, err)
+	env, err := aliceSession.Encrypt([]byte("from a bundle"))
+	require.NoError(t, err)
+	msg, err := ratchet.ParsePreKeyMessage(env)
+	require.NoError(t, err)
+	_, plaintext, err := ratchet.Respond(bob.id, bob.responderKeys(msg), env)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from a bundle"), plaintext)
+
+	t.Run("Zero prekey IDs", func(t *testing.T) {
+		testCases := []struct {
+			name       string
+			zeroBundle func(b *transport.PreKeyBundle)
+			zeroPeer   func(p *ratchet.PeerKeys)
+		}{
+			{
+				name:       "Signed prekey",
+				zeroBundle: func(b *transport.PreKeyBundle) { b.SignedPreKey.ID = 0 },
+				zeroPeer:   func(p *ratchet.PeerKeys) { p.SignedPreKeyID = 0 },
+			},
+			{
+				name: "One-time prekey",
+				zeroBundle: func(b *transport.PreKeyBundle) {
+					b.OneTimePreKey = &transport.PreKey{PublicKey: b.OneTimePreKey.PublicKey}
+				},
+				zeroPeer: func(p *ratchet.PeerKeys) { p.OneTimePreKeyID = 0 },
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				// Initiate treats a zero ID as no prekey, so the bundle must be
+				// rejected before a one-time prekey is spent on it.
+				b := *bundle
+				tc.zeroBundle(&b)
+				_, err := ratchet.PeerKeysFromBundle(&b)
+				assert.ErrorIs(t, err, transport.ErrInvalidKey)
+
+				keys := peer
+				tc.zeroPeer(&keys)
+				_, err = ratchet.Initiate(alice.id, alice.identity, b.ID(), keys)
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("Substituted identity DH key is rejected", func(t *testing.T) {
+		b := *bundle
+		b.IdentityKey.DHKey = mustX25519(t).PublicKey().Bytes()
+		_, err := ratchet.PeerKeysFromBundle(&b)
+		assert.ErrorIs(t, err, transport.ErrInvalidSignature)
+	})
+
+	t.Run("Unverified bundle is rejected", func(t *testing.T) {
+		bundle.SignedPreKey.Signature[0] ^= 1
+		_, err := ratchet.PeerKeysFromBundle(bundle)
+		assert.ErrorIs(t, err, transport.ErrInvalidSignature)
+	})
+}
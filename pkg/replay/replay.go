@@ -0,0 +1,134 @@
+// Package replay detects envelopes that are delivered more than once.
+//
+// A Guard remembers the (SenderID, MessageID) pair of every envelope it
+// accepts for a bounded window of time, and rejects envelopes it has already
+// seen or that are too old to be checked against what it remembers. Seen
+// pairs are kept in a pluggable Store; MemoryStore keeps them in memory with
+// a capacity bound, and once it is full of live pairs it forgets the least
+// recently seen, whose replays then go undetected.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+const (
+	// DefaultWindow is the default length of time envelopes are remembered.
+	DefaultWindow = 24 * time.Hour
+	// DefaultClockSkew is the default amount by which an envelope's
+	// timestamp may be ahead of the local clock.
+	DefaultClockSkew = 5 * time.Minute
+)
+
+var (
+	// ErrDuplicate is returned for an envelope whose sender and message ID
+	// have already been seen within the window.
+	ErrDuplicate = errors.New("duplicate envelope")
+	// ErrTooOld is returned for an envelope sent before the window, which
+	// can no longer be told apart from a replay.
+	ErrTooOld = errors.New("envelope is too old")
+	// ErrFromFuture is returned for an envelope whose timestamp is further
+	// ahead of the local clock than the allowed skew.
+	ErrFromFuture = errors.New("envelope timestamp is in the future")
+)
+
+// Key identifies an envelope for replay detection.
+type Key struct {
+	SenderID  urn.URN
+	MessageID string
+}
+
+func (k Key) String() string {
+	return k.SenderID.String() + "/" + k.MessageID
+}
+
+// Option configures a Guard.
+type Option func(*Guard)
+
+// WithWindow sets how long envelopes are remembered and how old an envelope
+// may be. The default is DefaultWindow.
+func WithWindow(d time.Duration) Option {
+	return func(g *Guard) { g.window = d }
+}
+
+// WithClockSkew sets how far ahead of the local clock an envelope's
+// timestamp may be. The default is DefaultClockSkew.
+func WithClockSkew(d time.Duration) Option {
+	return func(g *Guard) { g.skew = d }
+}
+
+// WithClock sets the function the Guard reads the current time from. The
+// default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(g *Guard) { g.now = now }
+}
+
+// Guard rejects envelopes that have been seen before or fall outside its
+// time window. It is safe for concurrent use if its Store is.
+type Guard struct {
+	store  Store
+	window time.Duration
+	skew   time.Duration
+	now    func() time.Time
+}
+
+// NewGuard creates a Guard that records seen envelopes in store.
+func NewGuard(store Store, opts ...Option) *Guard {
+	g := &Guard{store: store, window: DefaultWindow, skew: DefaultClockSkew, now: time.Now}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Check records env as seen and returns nil the first time it is called
+// for an envelope within the window. sentAt is the time the envelope was
+// sent, as stamped by the delivery service; if it is zero, the envelope's
+// signed SentAt is used instead.
+//
+// Check must be called after the envelope's signature has been verified:
+// SenderID and MessageID are only trustworthy once it has, and checking
+// forged envelopes would let an attacker mark genuine message IDs as seen.
+func (g *Guard) Check(ctx context.Context, env *transport.SecureEnvelope, sentAt time.Time) error {
+	if env == nil {
+		return errors.New("cannot check a nil envelope")
+	}
+	if env.SenderID.IsZero() || env.MessageID == "" {
+		return fmt.Errorf("%w: replay detection needs a sender and message ID", transport.ErrMissingField)
+	}
+	if sentAt.IsZero() {
+		if env.SentAt.IsZero() {
+			return fmt.Errorf("%w: replay detection needs a send time", transport.ErrMissingField)
+		}
+		sentAt = env.SentAt
+	}
+	return g.CheckKey(ctx, Key{SenderID: env.SenderID, MessageID: env.MessageID}, sentAt)
+}
+
+// CheckKey is Check for an envelope already reduced to its Key.
+func (g *Guard) CheckKey(ctx context.Context, key Key, sentAt time.Time) error {
+	now := g.now()
+	switch {
+	case !sentAt.After(now.Add(-g.window)):
+		return fmt.Errorf("%w: %s was sent at %s", ErrTooOld, key, sentAt.UTC().Format(time.RFC3339))
+	case sentAt.After(now.Add(g.skew)):
+		return fmt.Errorf("%w: %s was sent at %s", ErrFromFuture, key, sentAt.UTC().Format(time.RFC3339))
+	}
+
+	// An envelope from the edge of the clock skew must be remembered until
+	// its own timestamp falls out of the window.
+	added, err := g.store.Add(ctx, key, sentAt.Add(g.window), now)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return fmt.Errorf("%w: %s", ErrDuplicate, key)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package replay_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/replay"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source for tests.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func envelope(t *testing.T, sender, messageID string) *transport.SecureEnvelope {
+	t.Helper()
+	senderURN, err := urn.Parse("urn:sm:user:" + sender)
+	require.NoError(t, err)
+	return &transport.SecureEnvelope{SenderID: senderURN, MessageID: messageID}
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	clk := newClock()
+	guard := replay.NewGuard(replay.NewMemoryStore(0),
+		replay.WithWindow(time.Hour), replay.WithClockSkew(time.Minute), replay.WithClock(clk.Now))
+
+	env := envelope(t, "alice", "msg-1")
+	require.NoError(t, guard.Check(ctx, env, clk.Now()))
+
+	t.Run("Duplicate", func(t *testing.T) {
+		err := guard.Check(ctx, envelope(t, "alice", "msg-1"), clk.Now())
+		assert.ErrorIs(t, err, replay.ErrDuplicate)
+		assert.Contains(t, err.Error(), "urn:sm:user:alice/msg-1")
+	})
+
+	t.Run("Same ID from another sender", func(t *testing.T) {
+		assert.NoError(t, guard.Check(ctx, envelope(t, "bob", "msg-1"), clk.Now()))
+	})
+
+	testCases := []struct {
+		name          string
+		sentAt        time.Duration
+		expectedErrIs error
+	}{
+		{name: "Edge of window", sentAt: -time.Hour + time.Second},
+		{name: "Too old", sentAt: -time.Hour, expectedErrIs: replay.ErrTooOld},
+		{name: "Within clock skew", sentAt: time.Minute},
+		{name: "From future", sentAt: time.Minute + time.Second, expectedErrIs: replay.ErrFromFuture},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := guard.Check(ctx, envelope(t, "carol", tc.name), clk.Now().Add(tc.sentAt))
+			if tc.expectedErrIs == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.expectedErrIs)
+			}
+		})
+	}
+
+	t.Run("Envelope SentAt", func(t *testing.T) {
+		env := envelope(t, "erin", "msg-1")
+		env.SentAt = clk.Now().Add(-2 * time.Hour)
+		assert.ErrorIs(t, guard.Check(ctx, env, time.Time{}), replay.ErrTooOld)
+		assert.NoError(t, guard.Check(ctx, env, clk.Now()), "an explicit sentAt takes precedence")
+
+		env = envelope(t, "erin", "msg-2")
+		env.SentAt = clk.Now()
+		require.NoError(t, guard.Check(ctx, env, time.Time{}))
+		assert.ErrorIs(t, guard.Check(ctx, env, time.Time{}), replay.ErrDuplicate)
+	})
+
+	t.Run("Replay after the window is too old", func(t *testing.T) {
+		sentAt := clk.Now()
+		require.NoError(t, guard.Check(ctx, envelope(t, "dave", "msg-1"), sentAt))
+		clk.Advance(time.Hour + time.Second)
+		assert.ErrorIs(t, guard.Check(ctx, envelope(t, "dave", "msg-1"), sentAt), replay.ErrTooOld)
+	})
+
+	t.Run("Missing fields", func(t *testing.T) {
+		assert.ErrorIs(t, guard.Check(ctx, envelope(t, "alice", ""), clk.Now()), transport.ErrMissingField)
+		assert.ErrorIs(t, guard.Check(ctx, &transport.SecureEnvelope{MessageID: "x"}, clk.Now()), transport.ErrMissingField)
+		assert.ErrorIs(t, guard.Check(ctx, envelope(t, "alice", "msg-2"), time.Time{}), transport.ErrMissingField)
+		assert.Error(t, guard.Check(ctx, nil, clk.Now()))
+	})
+}
+
+func TestGuardFutureTimestampIsRememberedLonger(t *testing.T) {
+	ctx := context.Background()
+	clk := newClock()
+	guard := replay.NewGuard(replay.NewMemoryStore(0),
+		replay.WithWindow(time.Hour), replay.WithClockSkew(10*time.Minute), replay.WithClock(clk.Now))
+
+	sentAt := clk.Now().Add(10 * time.Minute)
+	require.NoError(t, guard.Check(ctx, envelope(t, "alice", "msg-1"), sentAt))
+
+	// An hour later the envelope is still inside its own window, so the
+	// replay must still be recognised.
+	clk.Advance(time.Hour + 5*time.Minute)
+	assert.ErrorIs(t, guard.Check(ctx, envelope(t, "alice", "msg-1"), sentAt), replay.ErrDuplicate)
+}
+
+func TestGuardConcurrent(t *testing.T) {
+	ctx := context.Background()
+	guard := replay.NewGuard(replay.NewMemoryStore(0))
+	env := envelope(t, "alice", "msg-1")
+	sentAt := time.Now()
+
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if guard.Check(ctx, env, sentAt) == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), accepted.Load(), "exactly one delivery is accepted")
+}
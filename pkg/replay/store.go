@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is the default number of keys a MemoryStore holds.
+const DefaultCapacity = 100_000
+
+// Store records the keys of seen envelopes. Implementations backed by a
+// shared database let several receivers guard the same inbox.
+type Store interface {
+	// Add records key until expiresAt and reports whether it was added. It
+	// returns false without changing anything if key is already recorded
+	// and has not expired at now. The check and the insert must be atomic.
+	Add(ctx context.Context, key Key, expiresAt, now time.Time) (bool, error)
+}
+
+type entry struct {
+	key       Key
+	expiresAt time.Time
+	el        *list.Element
+	index     int
+}
+
+// expiryHeap orders entries by expiry, soonest first.
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// MemoryStore is an in-memory Store that holds at most a fixed number of
+// keys. When it is full, expired keys are dropped first. If every key is
+// still live, the least recently seen one is evicted: a replay of that
+// envelope is then accepted as new, so the capacity should comfortably
+// exceed the number of envelopes expected within the window. Add takes
+// O(log n) time.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[Key]*entry
+	lru      *list.List
+	expiry   expiryHeap
+}
+
+// NewMemoryStore creates a MemoryStore holding up to capacity keys, or
+// DefaultCapacity if capacity is not positive.
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &MemoryStore{capacity: capacity, entries: make(map[Key]*entry), lru: list.New()}
+}
+
+// Add implements Store.
+func (m *MemoryStore) Add(_ context.Context, key Key, expiresAt, now time.Time) (bool, error) {
+	if !expiresAt.After(now) {
+		return false, errors.New("key would expire immediately")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		if e.expiresAt.After(now) {
+			m.lru.MoveToFront(e.el)
+			return false, nil
+		}
+		m.remove(e)
+	}
+
+	for m.lru.Len() >= m.capacity && !m.expiry[0].expiresAt.After(now) {
+		m.remove(m.expiry[0])
+	}
+	for m.lru.Len() >= m.capacity {
+		m.remove(m.lru.Back().Value.(*entry))
+	}
+	e := &entry{key: key, expiresAt: expiresAt}
+	e.el = m.lru.PushFront(e)
+	heap.Push(&m.expiry, e)
+	m.entries[key] = e
+	return true, nil
+}
+
+// Len returns the number of keys held, including any that have expired but
+// not yet been dropped.
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+func (m *MemoryStore) remove(e *entry) {
+	delete(m.entries, e.key)
+	m.lru.Remove(e.el)
+	heap.Remove(&m.expiry, e.index)
+}
@@ -0,0 +1,113 @@
+package replay_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/replay"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func key(t *testing.T, id string) replay.Key {
+	t.Helper()
+	sender, err := urn.Parse("urn:sm:user:alice")
+	require.NoError(t, err)
+	return replay.Key{SenderID: sender, MessageID: id}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	expiry := now.Add(time.Hour)
+
+	t.Run("Add And Expire", func(t *testing.T) {
+		store := replay.NewMemoryStore(10)
+		added, err := store.Add(ctx, key(t, "a"), expiry, now)
+		require.NoError(t, err)
+		assert.True(t, added)
+
+		added, err = store.Add(ctx, key(t, "a"), expiry, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, added)
+
+		added, err = store.Add(ctx, key(t, "a"), expiry.Add(time.Hour), expiry)
+		require.NoError(t, err)
+		assert.True(t, added, "an expired key can be added again")
+		assert.Equal(t, 1, store.Len())
+	})
+
+	t.Run("Expired Keys Dropped Before Live Ones", func(t *testing.T) {
+		store := replay.NewMemoryStore(3)
+		for i, ttl := range []time.Duration{time.Minute, time.Hour, time.Hour} {
+			added, err := store.Add(ctx, key(t, fmt.Sprint(i)), now.Add(ttl), now)
+			require.NoError(t, err)
+			require.True(t, added)
+		}
+		later := now.Add(2 * time.Minute)
+		added, err := store.Add(ctx, key(t, "3"), later.Add(time.Hour), later)
+		require.NoError(t, err)
+		require.True(t, added)
+
+		for _, id := range []string{"1", "2", "3"} {
+			added, err := store.Add(ctx, key(t, id), later.Add(time.Hour), later)
+			require.NoError(t, err)
+			assert.False(t, added, "live key %s must survive", id)
+		}
+	})
+
+	t.Run("Expired Keys Dropped Soonest First", func(t *testing.T) {
+		store := replay.NewMemoryStore(4)
+		for i, ttl := range []time.Duration{4 * time.Minute, time.Minute, 3 * time.Minute, 2 * time.Minute} {
+			added, err := store.Add(ctx, key(t, fmt.Sprint(i)), now.Add(ttl), now)
+			require.NoError(t, err)
+			require.True(t, added)
+		}
+		// Keys 1 and 3 have expired; adding two more drops only them.
+		later := now.Add(150 * time.Second)
+		for _, id := range []string{"4", "5"} {
+			added, err := store.Add(ctx, key(t, id), later.Add(time.Hour), later)
+			require.NoError(t, err)
+			require.True(t, added)
+		}
+		assert.Equal(t, 4, store.Len())
+
+		for _, id := range []string{"0", "2", "4", "5"} {
+			added, err := store.Add(ctx, key(t, id), later.Add(time.Hour), later)
+			require.NoError(t, err)
+			assert.False(t, added, "live key %s must survive", id)
+		}
+	})
+
+	t.Run("Least Recently Seen Evicted When Full", func(t *testing.T) {
+		store := replay.NewMemoryStore(2)
+		for _, id := range []string{"a", "b"} {
+			_, err := store.Add(ctx, key(t, id), expiry, now)
+			require.NoError(t, err)
+		}
+		// Seeing "a" again makes "b" the least recently seen.
+		added, err := store.Add(ctx, key(t, "a"), expiry, now)
+		require.NoError(t, err)
+		require.False(t, added)
+
+		_, err = store.Add(ctx, key(t, "c"), expiry, now)
+		require.NoError(t, err)
+		assert.Equal(t, 2, store.Len())
+
+		added, err = store.Add(ctx, key(t, "a"), expiry, now)
+		require.NoError(t, err)
+		assert.False(t, added)
+		added, err = store.Add(ctx, key(t, "b"), expiry, now)
+		require.NoError(t, err)
+		assert.True(t, added, "the evicted key is forgotten")
+	})
+
+	t.Run("Immediate Expiry Rejected", func(t *testing.T) {
+		store := replay.NewMemoryStore(1)
+		_, err := store.Add(ctx, key(t, "a"), now, now)
+		assert.Error(t, err)
+	})
+}
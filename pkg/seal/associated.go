@@ -0,0 +1,78 @@
+package seal
+
+import (
+	"encoding/binary"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// Contexts that prefix the AEAD associated data, so the payload, the snippet
+// and a wrapped key can never be substituted for one another.
+const (
+	dataADContext    = "go-secure-messaging/seal/v1 data"
+	snippetADContext = "go-secure-messaging/seal/v1 snippet"
+	keyWrapADContext = "go-secure-messaging/seal/v1 key-wrap-ad"
+)
+
+// header holds the routed envelope fields that are bound to the ciphertext
+// as AEAD associated data. Altering any of them in transit makes Open fail
+// with ErrDecryptionFailed.
+//
+// RecipientID is bound to the wrapped key rather than the payload, because a
+// group payload is shared by every member; each member's wrapped key is bound
+// to that member's ID instead.
+type header struct {
+	suite          transport.SuiteID
+	messageID      string
+	senderID       urn.URN
+	groupID        urn.URN
+	conversationID urn.URN
+}
+
+// headerOf returns the header of env. A fanned-out group envelope does not
+// name its group, so the caller supplies it as fallbackGroup.
+func headerOf(env *transport.SecureEnvelope, fallbackGroup urn.URN) header {
+	h := header{
+		suite:          env.Suite,
+		messageID:      env.MessageID,
+		senderID:       env.SenderID,
+		groupID:        env.GroupID,
+		conversationID: env.ConversationID,
+	}
+	if h.groupID.IsZero() {
+		h.groupID = fallbackGroup
+	}
+	return h
+}
+
+// dataAD returns the associated data for EncryptedData.
+func (h header) dataAD() []byte {
+	return h.appendTo([]byte(dataADContext))
+}
+
+// snippetAD returns the associated data for EncryptedSnippet.
+func (h header) snippetAD() []byte {
+	return h.appendTo([]byte(snippetADContext))
+}
+
+// keyWrapAD returns the associated data for a content key wrapped for
+// recipient.
+func (h header) keyWrapAD(recipient urn.URN) []byte {
+	return appendField(h.appendTo([]byte(keyWrapADContext)), recipient.String())
+}
+
+// appendTo appends the header fields to b, each prefixed with its length as
+// a big-endian uint32 in the same way as transport.SigningPayload.
+func (h header) appendTo(b []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(h.suite))
+	b = appendField(b, h.messageID)
+	b = appendField(b, h.senderID.String())
+	b = appendField(b, h.groupID.String())
+	return appendField(b, h.conversationID.String())
+}
+
+func appendField(b []byte, f string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(f)))
+	return append(b, f...)
+}
@@ -0,0 +1,223 @@
+package seal
+
+import (
+	"crypto/ecdh"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// keyMapVersion is the first byte of an encoded key map.
+const keyMapVersion = 0x01
+
+// ErrNotAMember is returned by OpenGroup when the key map has no entry for
+// the given member.
+var ErrNotAMember = errors.New("no wrapped key for member")
+
+// Member is a recipient of a group envelope: typically one device of one
+// group member.
+type Member struct {
+	ID        urn.URN
+	PublicKey *ecdh.PublicKey
+}
+
+// WrappedKey is one entry of a group envelope's key map: the content key
+// wrapped for a single member.
+type WrappedKey struct {
+	RecipientID urn.URN
+	Key         []byte
+}
+
+// SealGroup encrypts plaintext once and wraps the content key for every
+// member. The returned envelope is addressed to groupID and carries the
+// wrapped keys as an encoded key map in EncryptedSymmetricKey. Use OpenGroup
+// to read it, or FanOut to split it into one envelope per member.
+//
+// Group envelopes require a ConversationID to pass Validate; set it with
+// WithConversationID.
+func SealGroup(plaintext []byte, sender, groupID urn.URN, members []Member, opts ...Option) (*transport.SecureEnvelope, error) {
+	o := newOptions(opts)
+	if groupID.IsZero() {
+		return nil, errors.New("group ID is required")
+	}
+	if len(members) == 0 {
+		return nil, errors.New("at least one member is required")
+	}
+
+	for i, m := range members {
+		if m.ID.IsZero() || m.PublicKey == nil {
+			return nil, fmt.Errorf("member %d: ID and public key are required", i)
+		}
+		if m.PublicKey.Curve() != members[0].PublicKey.Curve() {
+			return nil, fmt.Errorf("%w: member %s uses %s, expected %s", ErrSuiteMismatch, m.ID, m.PublicKey.Curve(), members[0].PublicKey.Curve())
+		}
+	}
+	if err := o.resolveSuite(members[0].PublicKey.Curve()); err != nil {
+		return nil, err
+	}
+
+	o.recipientID = urn.URN{}
+	env, contentKey, err := sealContent(plaintext, sender, groupID, o)
+	if err != nil {
+		return nil, err
+	}
+	h := headerOf(env, urn.URN{})
+
+	keys := make([]WrappedKey, len(members))
+	for i, m := range members {
+		wrapped, err := wrapKey(o.cipher, contentKey, m.PublicKey, h.keyWrapAD(m.ID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap content key for %s: %w", m.ID, err)
+		}
+		keys[i] = WrappedKey{RecipientID: m.ID, Key: wrapped}
+	}
+	keyMap, err := EncodeKeyMap(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	env.EncryptedSymmetricKey = keyMap
+	return env, nil
+}
+
+// OpenGroup finds memberID's entry in a group envelope's key map, unwraps the
+// content key with memberPrivateKey and decrypts the payload.
+func OpenGroup(env *transport.SecureEnvelope, memberID urn.URN, memberPrivateKey *ecdh.PrivateKey, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	contentKey, h, err := unwrapGroupKey(env, memberID, memberPrivateKey, o)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(o.cipher, contentKey, env.EncryptedData, h.dataAD())
+}
+
+// OpenGroupSnippet is the OpenGroup counterpart of OpenSnippet.
+func OpenGroupSnippet(env *transport.SecureEnvelope, memberID urn.URN, memberPrivateKey *ecdh.PrivateKey, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	contentKey, h, err := unwrapGroupKey(env, memberID, memberPrivateKey, o)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.EncryptedSnippet) == 0 {
+		return nil, nil
+	}
+	return decrypt(o.cipher, contentKey, env.EncryptedSnippet, h.snippetAD())
+}
+
+// FanOut splits a group envelope into one envelope per key map entry. Each
+// envelope shares the original ciphertext, is addressed to its member via
+// RecipientID, keeps the ConversationID and carries only that member's
+// wrapped key, so it can be opened with Open. The envelopes no longer carry
+// the GroupID, which is bound to the ciphertext, so the receiver must pass
+// it to Open with WithGroupID.
+//
+// The sender's signature covers the whole key map and so does not carry over:
+// the returned envelopes are unsigned. A sender that wants per-recipient
+// signatures should fan out before signing.
+func FanOut(env *transport.SecureEnvelope) ([]*transport.SecureEnvelope, error) {
+	if env == nil {
+		return nil, errors.New("cannot fan out a nil envelope")
+	}
+	keys, err := DecodeKeyMap(env.EncryptedSymmetricKey)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*transport.SecureEnvelope, len(keys))
+	for i, k := range keys {
+		out[i] = &transport.SecureEnvelope{
+			Suite:                 env.Suite,
+			MessageID:             env.MessageID,
+			SenderID:              env.SenderID,
+			RecipientID:           k.RecipientID,
+			ConversationID:        env.ConversationID,
+			EncryptedData:         env.EncryptedData,
+			EncryptedSymmetricKey: k.Key,
+			EncryptedSnippet:      env.EncryptedSnippet,
+		}
+	}
+	return out, nil
+}
+
+// EncodeKeyMap serializes wrapped keys as a version byte followed by a
+// uvarint count and, for each entry sorted by recipient, the recipient in
+// the compact URN binary encoding and the length-prefixed wrapped key.
+func EncodeKeyMap(keys []WrappedKey) ([]byte, error) {
+	sorted := slices.Clone(keys)
+	slices.SortFunc(sorted, func(a, b WrappedKey) int { return a.RecipientID.Compare(b.RecipientID) })
+
+	buf := []byte{keyMapVersion}
+	buf = binary.AppendUvarint(buf, uint64(len(sorted)))
+	for i, k := range sorted {
+		if i > 0 && k.RecipientID.Equal(sorted[i-1].RecipientID) {
+			return nil, fmt.Errorf("duplicate key map recipient %s", k.RecipientID)
+		}
+		var err error
+		if buf, err = k.RecipientID.AppendBinary(buf); err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(k.Key)))
+		buf = append(buf, k.Key...)
+	}
+	return buf, nil
+}
+
+// DecodeKeyMap parses a key map written by EncodeKeyMap.
+func DecodeKeyMap(data []byte) ([]WrappedKey, error) {
+	if len(data) == 0 || data[0] != keyMapVersion {
+		return nil, fmt.Errorf("%w: not a key map", ErrMalformed)
+	}
+	data = data[1:]
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: invalid key map count", ErrMalformed)
+	}
+	data = data[n:]
+
+	keys := make([]WrappedKey, 0, count)
+	for i := uint64(0); i < count; i++ {
+		id, n, err := urn.DecodeBinary(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key map entry %d: %w", ErrMalformed, i, err)
+		}
+		data = data[n:]
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return nil, fmt.Errorf("%w: key map entry %d: truncated key", ErrMalformed, i)
+		}
+		data = data[n:]
+		keys = append(keys, WrappedKey{RecipientID: id, Key: data[:size:size]})
+		data = data[size:]
+	}
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes after key map", ErrMalformed, len(data))
+	}
+	return keys, nil
+}
+
+func unwrapGroupKey(env *transport.SecureEnvelope, memberID urn.URN, priv *ecdh.PrivateKey, o *options) ([]byte, header, error) {
+	if env == nil {
+		return nil, header{}, errors.New("cannot open a nil envelope")
+	}
+	if priv == nil {
+		return nil, header{}, errors.New("member private key is required")
+	}
+	if err := o.acceptSuite(env.Suite, priv.Curve()); err != nil {
+		return nil, header{}, err
+	}
+	keys, err := DecodeKeyMap(env.EncryptedSymmetricKey)
+	if err != nil {
+		return nil, header{}, err
+	}
+	h := headerOf(env, o.groupID)
+	for _, k := range keys {
+		if k.RecipientID.Equal(memberID) {
+			contentKey, err := unwrapKey(o.cipher, k.Key, priv, h.keyWrapAD(memberID))
+			return contentKey, h, err
+		}
+	}
+	return nil, header{}, fmt.Errorf("%w: %s", ErrNotAMember, memberID)
+}
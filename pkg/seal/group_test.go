@@ -0,0 +1,210 @@
+package seal_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/seal"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type groupMember struct {
+	id  urn.URN
+	key *ecdh.PrivateKey
+}
+
+func newGroupMembers(t *testing.T, ids ...string) ([]groupMember, []seal.Member) {
+	t.Helper()
+	var members []groupMember
+	var public []seal.Member
+	for _, id := range ids {
+		u, err := urn.Parse(id)
+		require.NoError(t, err)
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		members = append(members, groupMember{id: u, key: key})
+		public = append(public, seal.Member{ID: u, PublicKey: key.PublicKey()})
+	}
+	return members, public
+}
+
+func TestSealGroup(t *testing.T) {
+	senderURN, _ := urn.Parse("urn:sm:user:alice/device:laptop")
+	groupURN, _ := urn.Parse("urn:sm:group:book-club")
+	conversationURN, _ := urn.Parse("urn:sm:conversation:book-club-chat")
+	plaintext := []byte("meeting moved to thursday")
+	snippet := []byte("meeting moved…")
+
+	members, public := newGroupMembers(t,
+		"urn:sm:user:bob/device:phone",
+		"urn:sm:user:bob/device:tablet",
+		"urn:sm:user:carol/device:phone",
+	)
+
+	env, err := seal.SealGroup(plaintext, senderURN, groupURN, public,
+		seal.WithMessageID("msg-group-1"),
+		seal.WithConversationID(conversationURN),
+		seal.WithSnippet(snippet),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, groupURN, env.GroupID)
+	assert.True(t, env.RecipientID.IsZero())
+
+	roundTripped, err := transport.FromProto(transport.ToProto(env))
+	require.NoError(t, err)
+
+	t.Run("Key Map", func(t *testing.T) {
+		keys, err := seal.DecodeKeyMap(roundTripped.EncryptedSymmetricKey)
+		require.NoError(t, err)
+		require.Len(t, keys, len(members))
+		for i := 1; i < len(keys); i++ {
+			assert.Negative(t, keys[i-1].RecipientID.Compare(keys[i].RecipientID), "entries must be sorted")
+		}
+	})
+
+	t.Run("Open Group", func(t *testing.T) {
+		for _, m := range members {
+			opened, err := seal.OpenGroup(roundTripped, m.id, m.key)
+			require.NoError(t, err, m.id.String())
+			assert.Equal(t, plaintext, opened)
+
+			openedSnippet, err := seal.OpenGroupSnippet(roundTripped, m.id, m.key)
+			require.NoError(t, err)
+			assert.Equal(t, snippet, openedSnippet)
+		}
+	})
+
+	t.Run("Fan Out", func(t *testing.T) {
+		envs, err := seal.FanOut(roundTripped)
+		require.NoError(t, err)
+		require.Len(t, envs, len(members))
+
+		for i, e := range envs {
+			m := members[i] // members are already in key map order
+			assert.Equal(t, m.id, e.RecipientID)
+			assert.True(t, e.GroupID.IsZero())
+			assert.Equal(t, conversationURN, e.ConversationID)
+			assert.Equal(t, "msg-group-1", e.MessageID)
+			assert.Equal(t, roundTripped.EncryptedData, e.EncryptedData, "ciphertext is shared")
+			assert.Empty(t, e.Signature)
+			assert.Equal(t, transport.SuiteX25519AES256GCMEd25519, e.Suite)
+
+			opened, err := seal.Open(e, m.key, seal.WithGroupID(groupURN))
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, opened)
+		}
+
+		// Each fanned-out key only opens for its own member.
+		_, err = seal.Open(envs[0], members[1].key, seal.WithGroupID(groupURN))
+		assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+
+		// The group is bound to the ciphertext, so it must be supplied and correct.
+		_, err = seal.Open(envs[0], members[0].key)
+		assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+		otherGroupURN, _ := urn.Parse("urn:sm:group:other")
+		_, err = seal.Open(envs[0], members[0].key, seal.WithGroupID(otherGroupURN))
+		assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+
+		// Re-addressing a fanned-out envelope to another member fails too.
+		readdressed := *envs[0]
+		readdressed.RecipientID = members[1].id
+		_, err = seal.Open(&readdressed, members[0].key, seal.WithGroupID(groupURN))
+		assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+	})
+}
+
+func TestSealGroupFailures(t *testing.T) {
+	senderURN, _ := urn.Parse("urn:sm:user:alice")
+	groupURN, _ := urn.Parse("urn:sm:group:book-club")
+	members, public := newGroupMembers(t, "urn:sm:user:bob", "urn:sm:user:carol")
+	outsiderURN, _ := urn.Parse("urn:sm:user:mallory")
+
+	env, err := seal.SealGroup([]byte("hi"), senderURN, groupURN, public)
+	require.NoError(t, err)
+
+	t.Run("Not A Member", func(t *testing.T) {
+		_, err := seal.OpenGroup(env, outsiderURN, members[0].key)
+		assert.ErrorIs(t, err, seal.ErrNotAMember)
+	})
+
+	t.Run("Wrong Key For Member", func(t *testing.T) {
+		_, err := seal.OpenGroup(env, members[0].id, members[1].key)
+		assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+	})
+
+	t.Run("Single Recipient Envelope", func(t *testing.T) {
+		single, err := seal.Seal([]byte("hi"), senderURN, public[0].PublicKey)
+		require.NoError(t, err)
+		_, err = seal.OpenGroup(single, members[0].id, members[0].key)
+		assert.ErrorIs(t, err, seal.ErrMalformed)
+	})
+
+	t.Run("Tampered Headers", func(t *testing.T) {
+		otherGroupURN, _ := urn.Parse("urn:sm:group:other")
+		otherConversationURN, _ := urn.Parse("urn:sm:conversation:other")
+		for name, modify := range map[string]func(*transport.SecureEnvelope){
+			"GroupID":        func(e *transport.SecureEnvelope) { e.GroupID = otherGroupURN },
+			"ConversationID": func(e *transport.SecureEnvelope) { e.ConversationID = otherConversationURN },
+			"SenderID":       func(e *transport.SecureEnvelope) { e.SenderID = outsiderURN },
+			"MessageID":      func(e *transport.SecureEnvelope) { e.MessageID = "replayed" },
+		} {
+			t.Run(name, func(t *testing.T) {
+				tampered := *env
+				modify(&tampered)
+				_, err := seal.OpenGroup(&tampered, members[0].id, members[0].key)
+				assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+			})
+		}
+	})
+
+	t.Run("Duplicate Member", func(t *testing.T) {
+		_, err := seal.SealGroup([]byte("hi"), senderURN, groupURN, append(public, public[0]))
+		assert.Error(t, err)
+	})
+
+	t.Run("Missing Group", func(t *testing.T) {
+		_, err := seal.SealGroup([]byte("hi"), senderURN, urn.URN{}, public)
+		assert.Error(t, err)
+	})
+
+	t.Run("Mixed Curves", func(t *testing.T) {
+		p256Key, err := ecdh.P256().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		daveURN, _ := urn.Parse("urn:sm:user:dave")
+		mixed := append(public, seal.Member{ID: daveURN, PublicKey: p256Key.PublicKey()})
+		_, err = seal.SealGroup([]byte("hi"), senderURN, groupURN, mixed)
+		assert.ErrorIs(t, err, seal.ErrSuiteMismatch)
+	})
+
+	t.Run("No Members", func(t *testing.T) {
+		_, err := seal.SealGroup([]byte("hi"), senderURN, groupURN, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestDecodeKeyMapMalformed(t *testing.T) {
+	bob, _ := urn.Parse("urn:sm:user:bob")
+	valid, err := seal.EncodeKeyMap([]seal.WrappedKey{{RecipientID: bob, Key: []byte("wrapped")}})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Empty", data: nil},
+		{name: "Wrong Version", data: append([]byte{0x02}, valid[1:]...)},
+		{name: "Truncated", data: valid[:len(valid)-1]},
+		{name: "Trailing Bytes", data: append(append([]byte{}, valid...), 0x00)},
+		{name: "Huge Count", data: []byte{0x01, 0xff, 0x01}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := seal.DecodeKeyMap(tc.data)
+			assert.ErrorIs(t, err, seal.ErrMalformed)
+		})
+	}
+}
@@ -0,0 +1,415 @@
+// Package seal implements hybrid encryption of SecureEnvelopes.
+//
+// A random 256-bit content key encrypts the message payload and the optional
+// snippet. The content key is then wrapped for the recipient: an ephemeral
+// ECDH key pair is generated on the recipient's curve, the shared secret is
+// run through HKDF-SHA256 to derive a key-encryption key, and the content key
+// is sealed under it. The ephemeral public key travels in front of the
+// wrapped key in EncryptedSymmetricKey.
+//
+// The routed envelope fields are bound to the ciphertext and the wrapped key
+// as AEAD associated data, so an envelope cannot be re-addressed or moved to
+// another conversation without Open failing.
+//
+// SealGroup encrypts the payload once and wraps the same content key for each
+// member of a group; see group.go.
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/messageid"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	contentKeySize = 32
+	keyWrapInfo    = "go-secure-messaging/seal/v1 key-wrap"
+	kdfName        = "HKDF-SHA256"
+)
+
+var (
+	// ErrDecryptionFailed is returned when a ciphertext or wrapped key does
+	// not authenticate, which means it was tampered with, was encrypted for a
+	// different key, or was opened with the wrong cipher.
+	ErrDecryptionFailed = errors.New("decryption failed")
+	// ErrMalformed is returned when an envelope field is too short to hold
+	// the expected nonce, ephemeral key or tag.
+	ErrMalformed = errors.New("malformed sealed envelope")
+	// ErrSuiteMismatch is returned when a key is on a different curve from
+	// the one required by the envelope's cipher suite, or a suite names an
+	// algorithm this package does not implement.
+	ErrSuiteMismatch = errors.New("cipher suite mismatch")
+)
+
+// Cipher selects the AEAD used for the content and for key wrapping.
+type Cipher int
+
+const (
+	// AES256GCM uses AES-256 in Galois/Counter Mode with a 96-bit random nonce.
+	AES256GCM Cipher = iota
+	// XChaCha20Poly1305 uses XChaCha20-Poly1305 with a 192-bit random nonce.
+	XChaCha20Poly1305
+)
+
+func (c Cipher) String() string {
+	switch c {
+	case AES256GCM:
+		return "AES-256-GCM"
+	case XChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	}
+	return fmt.Sprintf("Cipher(%d)", int(c))
+}
+
+func (c Cipher) aead(key []byte) (cipher.AEAD, error) {
+	switch c {
+	case AES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	}
+	return nil, fmt.Errorf("unsupported cipher %s", c)
+}
+
+// cipherByName returns the Cipher whose String() is name.
+func cipherByName(name string) (Cipher, error) {
+	for _, c := range []Cipher{AES256GCM, XChaCha20Poly1305} {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unsupported AEAD %s", ErrSuiteMismatch, name)
+}
+
+// Option configures Seal and Open.
+type Option func(*options)
+
+type options struct {
+	cipher         Cipher
+	messageID      string
+	recipientID    urn.URN
+	conversationID urn.URN
+	snippet        []byte
+	groupID        urn.URN
+	suite          transport.SuiteID
+	suites         *transport.SuiteRegistry
+}
+
+// WithCipher selects the AEAD. The default is AES256GCM. Open only needs it
+// for envelopes that do not record a cipher suite.
+func WithCipher(c Cipher) Option {
+	return func(o *options) { o.cipher = c }
+}
+
+// WithMessageID sets the envelope's MessageID. If it is not given, Seal
+// generates a time-ordered one with messageid.New.
+func WithMessageID(id string) Option {
+	return func(o *options) { o.messageID = id }
+}
+
+// WithRecipientID sets the envelope's RecipientID.
+func WithRecipientID(id urn.URN) Option {
+	return func(o *options) { o.recipientID = id }
+}
+
+// WithConversationID sets the envelope's ConversationID.
+func WithConversationID(id urn.URN) Option {
+	return func(o *options) { o.conversationID = id }
+}
+
+// WithGroupID tells Open which group a fanned-out envelope belongs to. The
+// group is bound to the ciphertext, but FanOut removes GroupID from the
+// envelopes it produces, so the receiver must supply it to open them. It is
+// ignored for envelopes that carry a GroupID.
+func WithGroupID(id urn.URN) Option {
+	return func(o *options) { o.groupID = id }
+}
+
+// WithSnippet encrypts snippet with the content key into EncryptedSnippet.
+func WithSnippet(snippet []byte) Option {
+	return func(o *options) { o.snippet = snippet }
+}
+
+// WithSuite makes Seal use the algorithms of a registered cipher suite
+// instead of WithCipher. Without it, Seal records the first registered suite
+// matching the recipient's curve and the selected cipher.
+func WithSuite(id transport.SuiteID) Option {
+	return func(o *options) { o.suite = id }
+}
+
+// WithSuiteRegistry replaces transport.DefaultSuites as the registry used to
+// resolve and accept cipher suites.
+func WithSuiteRegistry(r *transport.SuiteRegistry) Option {
+	return func(o *options) { o.suites = r }
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{cipher: AES256GCM, suites: transport.DefaultSuites}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Seal encrypts plaintext for the holder of recipientPublicKey and returns an
+// envelope from sender. The recipient key may be on any curve supported by
+// crypto/ecdh; X25519 is recommended. The returned envelope is not signed;
+// use transport.Sign once any remaining fields have been set.
+//
+// The envelope's Suite records the algorithms used. Seal refuses deprecated
+// suites.
+func Seal(plaintext []byte, sender urn.URN, recipientPublicKey *ecdh.PublicKey, opts ...Option) (*transport.SecureEnvelope, error) {
+	o := newOptions(opts)
+	if recipientPublicKey == nil {
+		return nil, errors.New("recipient public key is required")
+	}
+	if err := o.resolveSuite(recipientPublicKey.Curve()); err != nil {
+		return nil, err
+	}
+
+	env, contentKey, err := sealContent(plaintext, sender, urn.URN{}, o)
+	if err != nil {
+		return nil, err
+	}
+	ad := headerOf(env, urn.URN{}).keyWrapAD(env.RecipientID)
+	if env.EncryptedSymmetricKey, err = wrapKey(o.cipher, contentKey, recipientPublicKey, ad); err != nil {
+		return nil, fmt.Errorf("failed to wrap content key: %w", err)
+	}
+	return env, nil
+}
+
+// sealContent generates a content key and builds an envelope holding the
+// encrypted payload and snippet, but no wrapped key.
+func sealContent(plaintext []byte, sender, groupID urn.URN, o *options) (*transport.SecureEnvelope, []byte, error) {
+	messageID := o.messageID
+	if messageID == "" {
+		var err error
+		if messageID, err = messageid.NewString(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	contentKey := make([]byte, contentKeySize)
+	if _, err := rand.Read(contentKey); err != nil {
+		return nil, nil, err
+	}
+
+	env := &transport.SecureEnvelope{
+		Suite:          o.suite,
+		MessageID:      messageID,
+		SenderID:       sender,
+		RecipientID:    o.recipientID,
+		GroupID:        groupID,
+		ConversationID: o.conversationID,
+	}
+	h := headerOf(env, urn.URN{})
+
+	var err error
+	if env.EncryptedData, err = encrypt(o.cipher, contentKey, plaintext, h.dataAD()); err != nil {
+		return nil, nil, fmt.Errorf("failed to encrypt payload: %w", err)
+	}
+	if o.snippet != nil {
+		if env.EncryptedSnippet, err = encrypt(o.cipher, contentKey, o.snippet, h.snippetAD()); err != nil {
+			return nil, nil, fmt.Errorf("failed to encrypt snippet: %w", err)
+		}
+	}
+	return env, contentKey, nil
+}
+
+// Open unwraps the content key with recipientPrivateKey and decrypts the
+// envelope's payload. It fails with ErrDecryptionFailed if any of the
+// envelope's routed fields (Suite, MessageID, SenderID, RecipientID, GroupID
+// or ConversationID) differ from when it was sealed.
+func Open(env *transport.SecureEnvelope, recipientPrivateKey *ecdh.PrivateKey, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	contentKey, h, err := unwrapEnvelopeKey(env, recipientPrivateKey, o)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(o.cipher, contentKey, env.EncryptedData, h.dataAD())
+}
+
+// OpenSnippet unwraps the content key with recipientPrivateKey and decrypts
+// the envelope's EncryptedSnippet. It returns nil if the envelope has no
+// snippet.
+func OpenSnippet(env *transport.SecureEnvelope, recipientPrivateKey *ecdh.PrivateKey, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	contentKey, h, err := unwrapEnvelopeKey(env, recipientPrivateKey, o)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.EncryptedSnippet) == 0 {
+		return nil, nil
+	}
+	return decrypt(o.cipher, contentKey, env.EncryptedSnippet, h.snippetAD())
+}
+
+func unwrapEnvelopeKey(env *transport.SecureEnvelope, priv *ecdh.PrivateKey, o *options) ([]byte, header, error) {
+	if env == nil {
+		return nil, header{}, errors.New("cannot open a nil envelope")
+	}
+	if priv == nil {
+		return nil, header{}, errors.New("recipient private key is required")
+	}
+	if err := o.acceptSuite(env.Suite, priv.Curve()); err != nil {
+		return nil, header{}, err
+	}
+	h := headerOf(env, o.groupID)
+	contentKey, err := unwrapKey(o.cipher, env.EncryptedSymmetricKey, priv, h.keyWrapAD(env.RecipientID))
+	return contentKey, h, err
+}
+
+// resolveSuite settles the suite and cipher used to seal for a key on curve.
+// An explicit suite must be accepted by the registry; otherwise the first
+// registered suite matching the curve and cipher is recorded, or none if no
+// suite describes the combination.
+func (o *options) resolveSuite(curve ecdh.Curve) error {
+	if o.suite != transport.SuiteUnspecified {
+		return o.acceptSuite(o.suite, curve)
+	}
+	var deprecated *transport.Suite
+	for _, s := range o.suites.Suites() {
+		if s.KeyAgreement != fmt.Sprint(curve) || s.AEAD != o.cipher.String() || s.KDF != kdfName {
+			continue
+		}
+		if s.Deprecated {
+			deprecated = &s
+			continue
+		}
+		o.suite = s.ID
+		return nil
+	}
+	if deprecated != nil {
+		return fmt.Errorf("%w: %s", transport.ErrDeprecatedSuite, deprecated.Name)
+	}
+	return nil
+}
+
+// acceptSuite checks that id may be used with a key on curve and selects its
+// cipher. SuiteUnspecified leaves the configured cipher in place.
+func (o *options) acceptSuite(id transport.SuiteID, curve ecdh.Curve) error {
+	if id == transport.SuiteUnspecified {
+		return nil
+	}
+	s, err := o.suites.Accept(id)
+	if err != nil {
+		return err
+	}
+	if s.KDF != kdfName {
+		return fmt.Errorf("%w: unsupported KDF %s", ErrSuiteMismatch, s.KDF)
+	}
+	if s.KeyAgreement != fmt.Sprint(curve) {
+		return fmt.Errorf("%w: %s requires %s keys, got %s", ErrSuiteMismatch, s.Name, s.KeyAgreement, curve)
+	}
+	if o.cipher, err = cipherByName(s.AEAD); err != nil {
+		return err
+	}
+	o.suite = id
+	return nil
+}
+
+// encrypt seals plaintext and ad under key with a fresh random nonce and
+// returns nonce || ciphertext.
+func encrypt(c Cipher, key, plaintext, ad []byte) ([]byte, error) {
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(out); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out, plaintext, ad), nil
+}
+
+// decrypt reverses encrypt.
+func decrypt(c Cipher, key, sealed, ad []byte) ([]byte, error) {
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrMalformed)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// wrapKey encrypts contentKey for recipient, authenticating ad, and returns
+// ephemeralPublicKey || AEAD(kek, contentKey).
+func wrapKey(c Cipher, contentKey []byte, recipient *ecdh.PublicKey, ad []byte) ([]byte, error) {
+	ephemeral, err := recipient.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := deriveKEK(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(kek)
+	if err != nil {
+		return nil, err
+	}
+	// The KEK is unique to this ephemeral key, so a fixed nonce is safe.
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(ephemeral.PublicKey().Bytes(), nonce, contentKey, ad), nil
+}
+
+// unwrapKey reverses wrapKey.
+func unwrapKey(c Cipher, wrapped []byte, recipient *ecdh.PrivateKey, ad []byte) ([]byte, error) {
+	keySize := len(recipient.PublicKey().Bytes())
+	if len(wrapped) <= keySize {
+		return nil, fmt.Errorf("%w: wrapped key too short", ErrMalformed)
+	}
+	ephemeral, err := recipient.Curve().NewPublicKey(wrapped[:keySize])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ephemeral key: %w", ErrMalformed, err)
+	}
+	shared, err := recipient.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key agreement failed: %w", ErrDecryptionFailed, err)
+	}
+	kek, err := deriveKEK(shared, ephemeral, recipient.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	contentKey, err := aead.Open(nil, nonce, wrapped[keySize:], ad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return contentKey, nil
+}
+
+// deriveKEK derives a key-encryption key from an ECDH shared secret, bound
+// to both the ephemeral and the recipient public keys.
+func deriveKEK(shared []byte, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+	return hkdf.Key(sha256.New, shared, salt, keyWrapInfo, contentKeySize)
+}
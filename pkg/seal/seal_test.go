@@ -0,0 +1,320 @@
+package seal_test
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/messageid"
+	"github.com/illmade-knight/go-secure-messaging/pkg/seal"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	senderURN, _ := urn.Parse("urn:sm:user:user-alice")
+	recipientURN, _ := urn.Parse("urn:sm:user:user-bob")
+	conversationURN, _ := urn.Parse("urn:sm:conversation:convo-123")
+	plaintext := []byte("hello, bob")
+	snippet := []byte("hello…")
+
+	testCases := []struct {
+		name   string
+		curve  ecdh.Curve
+		cipher seal.Cipher
+	}{
+		{name: "X25519 AES-256-GCM", curve: ecdh.X25519(), cipher: seal.AES256GCM},
+		{name: "X25519 XChaCha20-Poly1305", curve: ecdh.X25519(), cipher: seal.XChaCha20Poly1305},
+		{name: "P-256 AES-256-GCM", curve: ecdh.P256(), cipher: seal.AES256GCM},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recipientKey, err := tc.curve.GenerateKey(rand.Reader)
+			require.NoError(t, err)
+
+			env, err := seal.Seal(plaintext, senderURN, recipientKey.PublicKey(),
+				seal.WithCipher(tc.cipher),
+				seal.WithMessageID("msg-123"),
+				seal.WithRecipientID(recipientURN),
+				seal.WithConversationID(conversationURN),
+				seal.WithSnippet(snippet),
+			)
+			require.NoError(t, err)
+
+			assert.Equal(t, "msg-123", env.MessageID)
+			assert.Equal(t, senderURN, env.SenderID)
+			assert.Equal(t, recipientURN, env.RecipientID)
+			assert.Equal(t, conversationURN, env.ConversationID)
+			assert.NotContains(t, string(env.EncryptedData), string(plaintext))
+			assert.Empty(t, env.Signature)
+
+			// The envelope must survive the wire format.
+			roundTripped, err := transport.FromProto(transport.ToProto(env))
+			require.NoError(t, err)
+
+			opened, err := seal.Open(roundTripped, recipientKey, seal.WithCipher(tc.cipher))
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, opened)
+
+			openedSnippet, err := seal.OpenSnippet(roundTripped, recipientKey, seal.WithCipher(tc.cipher))
+			require.NoError(t, err)
+			assert.Equal(t, snippet, openedSnippet)
+		})
+	}
+}
+
+func TestSealDefaults(t *testing.T) {
+	recipientKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	senderURN, _ := urn.Parse("urn:sm:user:user-alice")
+
+	a, err := seal.Seal([]byte("one"), senderURN, recipientKey.PublicKey())
+	require.NoError(t, err)
+	b, err := seal.Seal([]byte("one"), senderURN, recipientKey.PublicKey())
+	require.NoError(t, err)
+
+	assert.NoError(t, messageid.Validate(a.MessageID), "generated message IDs must be UUIDv7")
+	assert.Less(t, a.MessageID, b.MessageID, "generated message IDs must be unique and time-ordered")
+	assert.NotEqual(t, a.EncryptedData, b.EncryptedData, "content keys and nonces must be fresh")
+	assert.Empty(t, a.EncryptedSnippet)
+
+	snippet, err := seal.OpenSnippet(a, recipientKey)
+	require.NoError(t, err)
+	assert.Nil(t, snippet)
+
+	t.Run("Sealed envelopes can be signed", func(t *testing.T) {
+		_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, transport.Sign(a, signingKey))
+		assert.NoError(t, transport.Verify(a, signingKey.Public()))
+	})
+}
+
+func TestOpenFailures(t *testing.T) {
+	recipientKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	senderURN, _ := urn.Parse("urn:sm:user:user-alice")
+
+	seal1 := func(t *testing.T) *transport.SecureEnvelope {
+		env, err := seal.Seal([]byte("secret"), senderURN, recipientKey.PublicKey(), seal.WithSnippet([]byte("s")))
+		require.NoError(t, err)
+		return env
+	}
+
+	testCases := []struct {
+		name          string
+		modify        func(env *transport.SecureEnvelope)
+		key           *ecdh.PrivateKey
+		opts          []seal.Option
+		expectedErrIs error
+	}{
+		{name: "Wrong recipient key", key: otherKey, expectedErrIs: seal.ErrDecryptionFailed},
+		{
+			name:          "Wrong cipher without suite",
+			modify:        func(env *transport.SecureEnvelope) { env.Suite = transport.SuiteUnspecified },
+			key:           recipientKey,
+			opts:          []seal.Option{seal.WithCipher(seal.XChaCha20Poly1305)},
+			expectedErrIs: seal.ErrDecryptionFailed,
+		},
+		{
+			name:          "Unknown suite",
+			modify:        func(env *transport.SecureEnvelope) { env.Suite = 0x7fff },
+			key:           recipientKey,
+			expectedErrIs: transport.ErrUnknownSuite,
+		},
+		{
+			name:          "Suite for another curve",
+			modify:        func(env *transport.SecureEnvelope) { env.Suite = transport.SuiteP256AES256GCMECDSAP256 },
+			key:           recipientKey,
+			expectedErrIs: seal.ErrSuiteMismatch,
+		},
+		{
+			name:          "Tampered ciphertext",
+			modify:        func(env *transport.SecureEnvelope) { env.EncryptedData[len(env.EncryptedData)-1] ^= 1 },
+			key:           recipientKey,
+			expectedErrIs: seal.ErrDecryptionFailed,
+		},
+		{
+			name:          "Tampered wrapped key",
+			modify:        func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey[40] ^= 1 },
+			key:           recipientKey,
+			expectedErrIs: seal.ErrDecryptionFailed,
+		},
+		{
+			name:          "Tampered ephemeral key",
+			modify:        func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey[0] ^= 1 },
+			key:           recipientKey,
+			expectedErrIs: seal.ErrDecryptionFailed,
+		},
+		{
+			name:          "Truncated ciphertext",
+			modify:        func(env *transport.SecureEnvelope) { env.EncryptedData = env.EncryptedData[:8] },
+			key:           recipientKey,
+			expectedErrIs: seal.ErrMalformed,
+		},
+		{
+			name:          "Truncated wrapped key",
+			modify:        func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey = env.EncryptedSymmetricKey[:16] },
+			key:           recipientKey,
+			expectedErrIs: seal.ErrMalformed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := seal1(t)
+			if tc.modify != nil {
+				tc.modify(env)
+			}
+			_, err := seal.Open(env, tc.key, tc.opts...)
+			assert.ErrorIs(t, err, tc.expectedErrIs)
+		})
+	}
+
+	t.Run("Tampered snippet", func(t *testing.T) {
+		env := seal1(t)
+		env.EncryptedSnippet[len(env.EncryptedSnippet)-1] ^= 1
+		_, err := seal.OpenSnippet(env, recipientKey)
+		assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+	})
+
+	t.Run("Nil arguments", func(t *testing.T) {
+		_, err := seal.Seal([]byte("x"), senderURN, nil)
+		assert.Error(t, err)
+		_, err = seal.Open(nil, recipientKey)
+		assert.Error(t, err)
+		_, err = seal.Open(seal1(t), nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestSuites(t *testing.T) {
+	senderURN, _ := urn.Parse("urn:sm:user:user-alice")
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	p256Key, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdh.P384().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("Recorded From Curve And Cipher", func(t *testing.T) {
+		testCases := []struct {
+			name     string
+			key      *ecdh.PrivateKey
+			opts     []seal.Option
+			expected transport.SuiteID
+		}{
+			{name: "X25519 default", key: x25519Key, expected: transport.SuiteX25519AES256GCMEd25519},
+			{name: "X25519 XChaCha", key: x25519Key, opts: []seal.Option{seal.WithCipher(seal.XChaCha20Poly1305)}, expected: transport.SuiteX25519XChaCha20Poly1305Ed25519},
+			{name: "P-256 default", key: p256Key, expected: transport.SuiteP256AES256GCMECDSAP256},
+			{name: "P-384 has no suite", key: p384Key, expected: transport.SuiteUnspecified},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				env, err := seal.Seal([]byte("hi"), senderURN, tc.key.PublicKey(), tc.opts...)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, env.Suite)
+
+				// The suite survives the wire format and selects the cipher on open.
+				roundTripped, err := transport.FromProto(transport.ToProto(env))
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, roundTripped.Suite)
+				opened, err := seal.Open(roundTripped, tc.key, tc.opts...)
+				require.NoError(t, err)
+				assert.Equal(t, []byte("hi"), opened)
+			})
+		}
+	})
+
+	t.Run("Explicit Suite Selects Cipher", func(t *testing.T) {
+		env, err := seal.Seal([]byte("hi"), senderURN, x25519Key.PublicKey(),
+			seal.WithSuite(transport.SuiteX25519XChaCha20Poly1305Ed25519))
+		require.NoError(t, err)
+		assert.Equal(t, transport.SuiteX25519XChaCha20Poly1305Ed25519, env.Suite)
+
+		// No WithCipher is needed to open: the suite says which one to use.
+		opened, err := seal.Open(env, x25519Key)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hi"), opened)
+	})
+
+	t.Run("Explicit Suite Wrong Curve", func(t *testing.T) {
+		_, err := seal.Seal([]byte("hi"), senderURN, p256Key.PublicKey(),
+			seal.WithSuite(transport.SuiteX25519AES256GCMEd25519))
+		assert.ErrorIs(t, err, seal.ErrSuiteMismatch)
+	})
+
+	t.Run("Deprecated Suite Rejected", func(t *testing.T) {
+		registry := transport.NewSuiteRegistry()
+		for _, s := range transport.DefaultSuites.Suites() {
+			registry.MustRegister(s)
+		}
+		env, err := seal.Seal([]byte("hi"), senderURN, x25519Key.PublicKey(), seal.WithSuiteRegistry(registry))
+		require.NoError(t, err)
+
+		require.NoError(t, registry.Deprecate(transport.SuiteX25519AES256GCMEd25519))
+
+		_, err = seal.Open(env, x25519Key, seal.WithSuiteRegistry(registry))
+		assert.ErrorIs(t, err, transport.ErrDeprecatedSuite)
+		_, err = seal.Seal([]byte("hi"), senderURN, x25519Key.PublicKey(), seal.WithSuiteRegistry(registry))
+		assert.ErrorIs(t, err, transport.ErrDeprecatedSuite)
+		_, err = seal.Seal([]byte("hi"), senderURN, x25519Key.PublicKey(),
+			seal.WithSuiteRegistry(registry), seal.WithSuite(transport.SuiteX25519AES256GCMEd25519))
+		assert.ErrorIs(t, err, transport.ErrDeprecatedSuite)
+	})
+}
+
+func TestAssociatedDataBinding(t *testing.T) {
+	recipientKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	senderURN, _ := urn.Parse("urn:sm:user:user-alice")
+	recipientURN, _ := urn.Parse("urn:sm:user:user-bob")
+	conversationURN, _ := urn.Parse("urn:sm:conversation:convo-123")
+	otherURN, _ := urn.Parse("urn:sm:user:mallory")
+	otherConversationURN, _ := urn.Parse("urn:sm:conversation:convo-999")
+	otherGroupURN, _ := urn.Parse("urn:sm:group:g1")
+
+	sealed := func(t *testing.T) *transport.SecureEnvelope {
+		env, err := seal.Seal([]byte("secret"), senderURN, recipientKey.PublicKey(),
+			seal.WithRecipientID(recipientURN),
+			seal.WithConversationID(conversationURN),
+			seal.WithSnippet([]byte("snippet")),
+		)
+		require.NoError(t, err)
+		return env
+	}
+
+	testCases := map[string]func(env *transport.SecureEnvelope){
+		"SenderID":       func(env *transport.SecureEnvelope) { env.SenderID = otherURN },
+		"RecipientID":    func(env *transport.SecureEnvelope) { env.RecipientID = otherURN },
+		"GroupID":        func(env *transport.SecureEnvelope) { env.GroupID = otherGroupURN },
+		"ConversationID": func(env *transport.SecureEnvelope) { env.ConversationID = otherConversationURN },
+		"MessageID":      func(env *transport.SecureEnvelope) { env.MessageID = "replayed-id" },
+		"Snippet swapped with data": func(env *transport.SecureEnvelope) {
+			env.EncryptedData, env.EncryptedSnippet = env.EncryptedSnippet, env.EncryptedData
+		},
+	}
+
+	for name, modify := range testCases {
+		t.Run(name, func(t *testing.T) {
+			env := sealed(t)
+			modify(env)
+			_, err := seal.Open(env, recipientKey)
+			assert.ErrorIs(t, err, seal.ErrDecryptionFailed)
+		})
+	}
+
+	t.Run("Untouched envelope opens", func(t *testing.T) {
+		env := sealed(t)
+		_, err := seal.Open(env, recipientKey)
+		assert.NoError(t, err)
+		_, err = seal.OpenSnippet(env, recipientKey)
+		assert.NoError(t, err)
+	})
+}
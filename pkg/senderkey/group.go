@@ -0,0 +1,306 @@
+package senderkey
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/chainkdf"
+	"github.com/illmade-knight/go-secure-messaging/internal/groupenv"
+	"github.com/illmade-knight/go-secure-messaging/pkg/messageid"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// Option configures a Group or a single Encrypt call.
+type Option = groupenv.Option
+
+// WithMessageID sets the envelope's MessageID. If it is not given, Encrypt
+// generates a time-ordered one with messageid.New.
+func WithMessageID(id string) Option {
+	return groupenv.WithMessageID(id)
+}
+
+// WithConversationID sets the envelope's ConversationID. Group envelopes
+// need one to pass Validate.
+func WithConversationID(id urn.URN) Option {
+	return groupenv.WithConversationID(id)
+}
+
+// WithSentAt sets the envelope's SentAt, which Encrypt signs. Without it,
+// SentAt is only set if WithTTL is given.
+func WithSentAt(t time.Time) Option {
+	return groupenv.WithSentAt(t)
+}
+
+// WithTTL sets the envelope's ExpiresAt to ttl after its SentAt, which
+// defaults to the current time.
+func WithTTL(ttl time.Duration) Option {
+	return groupenv.WithTTL(ttl)
+}
+
+// WithMaxSkip sets the group's skip limit when passed to NewGroup. The
+// default is DefaultMaxSkip.
+func WithMaxSkip(n int) Option {
+	return groupenv.WithMaxSkip(n)
+}
+
+// senderState holds a member's current sender key and the one it replaced,
+// so that messages sent just before a rotation can still be read.
+type senderState struct {
+	current, previous *receiverKey
+}
+
+func (s *senderState) find(keyID uint32) **receiverKey {
+	if s.current != nil && s.current.keyID == keyID {
+		return &s.current
+	}
+	if s.previous != nil && s.previous.keyID == keyID {
+		return &s.previous
+	}
+	return nil
+}
+
+// Group is one member's view of a sender key group: its own sender key and
+// the sender keys it has received from the other members. It is safe for
+// concurrent use.
+type Group struct {
+	mu       sync.Mutex
+	groupID  urn.URN
+	self     urn.URN
+	maxSkip  int
+	own      *senderKey
+	members  map[urn.URN]struct{}
+	received map[urn.URN]*senderState
+}
+
+// NewGroup creates self's view of groupID with the given other members and a
+// fresh sender key. Send Distribution to every member before encrypting.
+func NewGroup(groupID, self urn.URN, members []urn.URN, opts ...Option) (*Group, error) {
+	o := groupenv.NewOptions(opts, DefaultMaxSkip)
+	if groupID.IsZero() || self.IsZero() {
+		return nil, errors.New("group and self IDs are required")
+	}
+	own, err := newSenderKey()
+	if err != nil {
+		return nil, err
+	}
+	g := &Group{
+		groupID:  groupID,
+		self:     self,
+		maxSkip:  o.MaxSkip,
+		own:      own,
+		members:  make(map[urn.URN]struct{}),
+		received: make(map[urn.URN]*senderState),
+	}
+	for _, m := range members {
+		if !m.Equal(self) {
+			g.members[m] = struct{}{}
+		}
+	}
+	return g, nil
+}
+
+// ID returns the group's URN.
+func (g *Group) ID() urn.URN {
+	return g.groupID
+}
+
+// Members returns the other members of the group in sorted order.
+func (g *Group) Members() []urn.URN {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	members := make([]urn.URN, 0, len(g.members))
+	for m := range g.members {
+		members = append(members, m)
+	}
+	slices.SortFunc(members, urn.URN.Compare)
+	return members
+}
+
+// Distribution returns the message that hands self's current sender key to
+// the other members. Messages already sent with the key cannot be decrypted
+// with it, because it carries the chain key at its current iteration.
+func (g *Group) Distribution() *DistributionMessage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.own.distribution(g.groupID, g.self)
+}
+
+// AddMember adds a member and returns the distribution message to send to
+// them. The new member can read messages from now on but not earlier ones,
+// so the sender key does not need to rotate.
+func (g *Group) AddMember(id urn.URN) *DistributionMessage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !id.Equal(g.self) {
+		g.members[id] = struct{}{}
+	}
+	return g.own.distribution(g.groupID, g.self)
+}
+
+// RemoveMember removes a member, forgets their sender key and rotates self's
+// sender key, since the removed member knows the current one. The returned
+// distribution message must be sent to every remaining member as a rotation.
+//
+// The removed member also still holds every other member's sender key, so
+// each remaining member must call RemoveMember themselves, and distribute
+// the result, before the removed member is locked out of their messages.
+func (g *Group) RemoveMember(id urn.URN) (*DistributionMessage, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.members[id]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotMember, id)
+	}
+	if err := g.rotate(); err != nil {
+		return nil, err
+	}
+	delete(g.members, id)
+	delete(g.received, id)
+	return g.own.distribution(g.groupID, g.self), nil
+}
+
+// RotateKey replaces self's sender key with a fresh one and returns the
+// distribution message to send to every member as a rotation.
+func (g *Group) RotateKey() (*DistributionMessage, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.rotate(); err != nil {
+		return nil, err
+	}
+	return g.own.distribution(g.groupID, g.self), nil
+}
+
+func (g *Group) rotate() error {
+	own, err := newSenderKey()
+	if err != nil {
+		return err
+	}
+	g.own = own
+	return nil
+}
+
+// Process installs a member's sender key from a distribution message. from
+// is the member that sent d, as authenticated by the pairwise channel it
+// arrived on; members cannot distribute keys on each other's behalf. A
+// repeated distribution of a known key is ignored. A new key ID only
+// replaces the sender's current key if rotate is set, and the replaced key is
+// kept for messages still in flight.
+func (g *Group) Process(from urn.URN, d *DistributionMessage, rotate bool) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	if !d.GroupID.Equal(g.groupID) {
+		return fmt.Errorf("%w: distribution is for %s", ErrMalformed, d.GroupID)
+	}
+	if !from.Equal(d.SenderID) {
+		return fmt.Errorf("%w: %s sent a distribution for %s", ErrWrongSender, from, d.SenderID)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.members[d.SenderID]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotMember, d.SenderID)
+	}
+	state, ok := g.received[d.SenderID]
+	if !ok {
+		state = &senderState{}
+		g.received[d.SenderID] = state
+	}
+	if state.find(d.KeyID) != nil {
+		return nil
+	}
+	if state.current != nil && !rotate {
+		return fmt.Errorf("%w: %s already has key %d", ErrKeyInstalled, d.SenderID, state.current.keyID)
+	}
+	state.previous, state.current = state.current, newReceiverKey(d)
+	return nil
+}
+
+// Encrypt ratchets self's sender key and returns plaintext in an envelope
+// addressed to the group and signed with the sender key's signing key.
+func (g *Group) Encrypt(plaintext []byte, opts ...Option) (*transport.SecureEnvelope, error) {
+	o := groupenv.NewOptions(opts, DefaultMaxSkip)
+	messageID := o.MessageID
+	if messageID == "" {
+		var err error
+		if messageID, err = messageid.NewString(); err != nil {
+			return nil, err
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	iteration, messageKey := g.own.next()
+	env := &transport.SecureEnvelope{
+		Suite:                 transport.SuiteSenderKeyAES256GCMEd25519,
+		MessageID:             messageID,
+		SenderID:              g.self,
+		GroupID:               g.groupID,
+		ConversationID:        o.ConversationID,
+		EncryptedSymmetricKey: marshalHeader(g.own.keyID, iteration),
+	}
+
+	aead, nonce, err := chainkdf.MessageAEAD(messageKey, messageInfo)
+	if err != nil {
+		return nil, err
+	}
+	env.EncryptedData = aead.Seal(nil, nonce, plaintext, groupenv.AssociatedData(adContext, env))
+	o.Stamp(env)
+	if err := transport.Sign(env, g.own.signingKey); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Decrypt verifies the sender's signature on a group envelope and decrypts
+// it. The sender's chain only advances if decryption succeeds.
+func (g *Group) Decrypt(env *transport.SecureEnvelope) ([]byte, error) {
+	if env == nil {
+		return nil, errors.New("cannot decrypt a nil envelope")
+	}
+	if env.Suite != transport.SuiteSenderKeyAES256GCMEd25519 {
+		return nil, fmt.Errorf("%w: envelope suite is %s", ErrMalformed, env.Suite)
+	}
+	if !env.GroupID.Equal(g.groupID) {
+		return nil, fmt.Errorf("%w: envelope is for %s", ErrMalformed, env.GroupID)
+	}
+	keyID, iteration, err := parseHeader(env.EncryptedSymmetricKey)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.members[env.SenderID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotMember, env.SenderID)
+	}
+	state, ok := g.received[env.SenderID]
+	if !ok {
+		return nil, fmt.Errorf("%w: no sender key from %s", ErrUnknownSenderKey, env.SenderID)
+	}
+	slot := state.find(keyID)
+	if slot == nil {
+		return nil, fmt.Errorf("%w: key %d from %s", ErrUnknownSenderKey, keyID, env.SenderID)
+	}
+	if err := transport.Verify(env, (*slot).signingKey); err != nil {
+		return nil, err
+	}
+
+	next, messageKey, err := (*slot).messageKey(iteration, g.maxSkip)
+	if err != nil {
+		return nil, err
+	}
+	aead, nonce, err := chainkdf.MessageAEAD(messageKey, messageInfo)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, env.EncryptedData, groupenv.AssociatedData(adContext, env))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	*slot = next
+	return plaintext, nil
+}
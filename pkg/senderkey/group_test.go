@@ -0,0 +1,306 @@
+package senderkey_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/seal"
+	"github.com/illmade-knight/go-secure-messaging/pkg/senderkey"
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGroup is a group of members that have exchanged sender keys.
+type testGroup struct {
+	id           urn.URN
+	conversation urn.URN
+	members      map[string]*senderkey.Group
+}
+
+func newTestGroup(t *testing.T, names ...string) *testGroup {
+	t.Helper()
+	tg := &testGroup{
+		id:           mustParse(t, "urn:sm:group:book-club"),
+		conversation: mustParse(t, "urn:sm:conversation:book-club-chat"),
+		members:      make(map[string]*senderkey.Group),
+	}
+	var ids []urn.URN
+	for _, name := range names {
+		ids = append(ids, mustParse(t, "urn:sm:user:"+name))
+	}
+	for i, name := range names {
+		g, err := senderkey.NewGroup(tg.id, ids[i], ids)
+		require.NoError(t, err)
+		tg.members[name] = g
+	}
+	for _, from := range names {
+		tg.distribute(t, from, tg.members[from].Distribution(), false)
+	}
+	return tg
+}
+
+// distribute delivers d from one member to all others through a sealed
+// pairwise envelope, as a real client would.
+func (tg *testGroup) distribute(t *testing.T, from string, d *senderkey.DistributionMessage, rotate bool) {
+	t.Helper()
+	payload, err := json.Marshal(d)
+	require.NoError(t, err)
+	for name, g := range tg.members {
+		if name == from {
+			continue
+		}
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		env, err := seal.Seal(payload, d.SenderID, key.PublicKey())
+		require.NoError(t, err)
+		opened, err := seal.Open(env, key)
+		require.NoError(t, err)
+
+		var received senderkey.DistributionMessage
+		require.NoError(t, json.Unmarshal(opened, &received))
+		require.NoError(t, g.Process(mustParse(t, "urn:sm:user:"+from), &received, rotate))
+	}
+}
+
+func (tg *testGroup) encrypt(t *testing.T, from, text string) *transport.SecureEnvelope {
+	t.Helper()
+	env, err := tg.members[from].Encrypt([]byte(text), senderkey.WithConversationID(tg.conversation))
+	require.NoError(t, err)
+	return env
+}
+
+func TestGroupMessaging(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob", "carol")
+
+	for round := 0; round < 3; round++ {
+		for _, from := range []string{"alice", "bob", "carol"} {
+			text := fmt.Sprintf("%s says %d", from, round)
+			env := tg.encrypt(t, from, text)
+			require.NoError(t, env.Validate(), "sender key envelopes are complete group envelopes")
+			assert.Equal(t, tg.id, env.GroupID)
+			assert.Equal(t, transport.SuiteSenderKeyAES256GCMEd25519, env.Suite)
+
+			wire, err := transport.FromProto(transport.ToProto(env))
+			require.NoError(t, err)
+			for name, g := range tg.members {
+				if name == from {
+					continue
+				}
+				plaintext, err := g.Decrypt(wire)
+				require.NoError(t, err, "%s reading %s", name, from)
+				assert.Equal(t, text, string(plaintext))
+			}
+		}
+	}
+}
+
+func TestGroupOutOfOrderAndReplay(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob")
+	bob := tg.members["bob"]
+
+	var envs []*transport.SecureEnvelope
+	for i := 0; i < 4; i++ {
+		envs = append(envs, tg.encrypt(t, "alice", fmt.Sprintf("msg %d", i)))
+	}
+	for _, i := range []int{2, 0, 3, 1} {
+		plaintext, err := bob.Decrypt(envs[i])
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("msg %d", i), string(plaintext))
+	}
+	for _, env := range envs {
+		_, err := bob.Decrypt(env)
+		assert.ErrorIs(t, err, senderkey.ErrDecryptionFailed, "replays are rejected")
+	}
+}
+
+func TestGroupRejectsForgeries(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob", "carol")
+	bob := tg.members["bob"]
+	otherConversation := mustParse(t, "urn:sm:conversation:other")
+
+	t.Run("Member impersonating another", func(t *testing.T) {
+		// Carol knows alice's chain key but not her signing key.
+		env := tg.encrypt(t, "carol", "i am alice")
+		env.SenderID = mustParse(t, "urn:sm:user:alice")
+		_, err := bob.Decrypt(env)
+		assert.Error(t, err)
+	})
+
+	testCases := []struct {
+		name          string
+		modify        func(env *transport.SecureEnvelope)
+		expectedErrIs error
+	}{
+		{name: "Tampered ciphertext", modify: func(env *transport.SecureEnvelope) { env.EncryptedData[0] ^= 1 }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Moved conversation", modify: func(env *transport.SecureEnvelope) { env.ConversationID = otherConversation }, expectedErrIs: transport.ErrInvalidSignature},
+		{name: "Unknown key ID", modify: func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey[1] ^= 1 }, expectedErrIs: senderkey.ErrUnknownSenderKey},
+		{name: "Malformed header", modify: func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey = []byte{1} }, expectedErrIs: senderkey.ErrMalformed},
+		{name: "Wrong suite", modify: func(env *transport.SecureEnvelope) { env.Suite = transport.SuiteX25519AES256GCMEd25519 }, expectedErrIs: senderkey.ErrMalformed},
+		{name: "Non-member sender", modify: func(env *transport.SecureEnvelope) { env.SenderID = mustParse(t, "urn:sm:user:mallory") }, expectedErrIs: senderkey.ErrNotMember},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := tg.encrypt(t, "alice", "secret")
+			tc.modify(env)
+			_, err := bob.Decrypt(env)
+			assert.ErrorIs(t, err, tc.expectedErrIs)
+		})
+	}
+
+	t.Run("Chain unaffected by rejected messages", func(t *testing.T) {
+		env := tg.encrypt(t, "alice", "genuine")
+		plaintext, err := bob.Decrypt(env)
+		require.NoError(t, err)
+		assert.Equal(t, "genuine", string(plaintext))
+	})
+}
+
+func TestGroupEnvelopeExpiry(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob")
+	sentAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	encrypt := func(t *testing.T) *transport.SecureEnvelope {
+		env, err := tg.members["alice"].Encrypt([]byte("disappearing"),
+			senderkey.WithConversationID(tg.conversation), senderkey.WithSentAt(sentAt), senderkey.WithTTL(time.Hour))
+		require.NoError(t, err)
+		return env
+	}
+
+	env := encrypt(t)
+	assert.Equal(t, sentAt, env.SentAt)
+	assert.Equal(t, sentAt.Add(time.Hour), env.ExpiresAt)
+	assert.True(t, env.IsExpired(sentAt.Add(time.Hour)))
+
+	wire, err := transport.FromProto(transport.ToProto(env))
+	require.NoError(t, err)
+	plaintext, err := tg.members["bob"].Decrypt(wire)
+	require.NoError(t, err)
+	assert.Equal(t, "disappearing", string(plaintext))
+
+	extended := encrypt(t)
+	extended.ExpiresAt = extended.ExpiresAt.Add(time.Hour)
+	_, err = tg.members["bob"].Decrypt(extended)
+	assert.ErrorIs(t, err, transport.ErrInvalidSignature, "the expiry is signed")
+}
+
+func TestGroupMembershipChanges(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob", "carol")
+	alice, bob, carol := tg.members["alice"], tg.members["bob"], tg.members["carol"]
+	carolURN := mustParse(t, "urn:sm:user:carol")
+
+	inFlight := tg.encrypt(t, "alice", "sent before removal")
+
+	// Everyone removes carol; alice and bob rotate their sender keys.
+	delete(tg.members, "carol")
+	for _, name := range []string{"alice", "bob"} {
+		d, err := tg.members[name].RemoveMember(carolURN)
+		require.NoError(t, err)
+		tg.distribute(t, name, d, true)
+	}
+	assert.Equal(t, []urn.URN{mustParse(t, "urn:sm:user:bob")}, alice.Members())
+
+	for from, to := range map[string]*senderkey.Group{"alice": bob, "bob": alice} {
+		after := tg.encrypt(t, from, "carol cannot read this")
+		plaintext, err := to.Decrypt(after)
+		require.NoError(t, err)
+		assert.Equal(t, "carol cannot read this", string(plaintext))
+
+		_, err = carol.Decrypt(after)
+		assert.ErrorIs(t, err, senderkey.ErrUnknownSenderKey, "the removed member never received %s's new key", from)
+	}
+
+	plaintext, err := bob.Decrypt(inFlight)
+	require.NoError(t, err, "messages under the previous key still open")
+	assert.Equal(t, "sent before removal", string(plaintext))
+
+	_, err = bob.Decrypt(tg.encrypt(t, "bob", "x"))
+	assert.Error(t, err, "members do not hold their own key as a receiver")
+
+	t.Run("Removed member's messages are rejected", func(t *testing.T) {
+		env, err := carol.Encrypt([]byte("still here?"), senderkey.WithConversationID(tg.conversation))
+		require.NoError(t, err)
+		_, err = bob.Decrypt(env)
+		assert.ErrorIs(t, err, senderkey.ErrNotMember)
+		assert.ErrorIs(t, bob.Process(carolURN, carol.Distribution(), false), senderkey.ErrNotMember)
+	})
+
+	t.Run("Added member reads from now on", func(t *testing.T) {
+		dave := mustParse(t, "urn:sm:user:dave")
+		earlier := tg.encrypt(t, "alice", "before dave")
+
+		daveGroup, err := senderkey.NewGroup(tg.id, dave, []urn.URN{mustParse(t, "urn:sm:user:alice")})
+		require.NoError(t, err)
+		require.NoError(t, daveGroup.Process(mustParse(t, "urn:sm:user:alice"), alice.AddMember(dave), false))
+
+		later := tg.encrypt(t, "alice", "welcome dave")
+		plaintext, err := daveGroup.Decrypt(later)
+		require.NoError(t, err)
+		assert.Equal(t, "welcome dave", string(plaintext))
+
+		_, err = daveGroup.Decrypt(earlier)
+		assert.ErrorIs(t, err, senderkey.ErrDecryptionFailed)
+	})
+
+	_, err = alice.RemoveMember(carolURN)
+	assert.ErrorIs(t, err, senderkey.ErrNotMember)
+}
+
+func TestGroupProcess(t *testing.T) {
+	tg := newTestGroup(t, "alice", "bob", "carol")
+	alice, bob, carol := tg.members["alice"], tg.members["bob"], tg.members["carol"]
+	aliceURN, bobURN, carolURN := mustParse(t, "urn:sm:user:alice"), mustParse(t, "urn:sm:user:bob"), mustParse(t, "urn:sm:user:carol")
+
+	t.Run("Member installs a key for another", func(t *testing.T) {
+		// Bob makes up a sender key for carol and sends it to alice.
+		forger, err := senderkey.NewGroup(tg.id, carolURN, []urn.URN{aliceURN})
+		require.NoError(t, err)
+		forged := forger.Distribution()
+
+		assert.ErrorIs(t, alice.Process(bobURN, forged, false), senderkey.ErrWrongSender)
+		assert.ErrorIs(t, alice.Process(bobURN, forged, true), senderkey.ErrWrongSender)
+
+		forgedEnv, err := forger.Encrypt([]byte("i am carol"), senderkey.WithConversationID(tg.conversation))
+		require.NoError(t, err)
+		_, err = alice.Decrypt(forgedEnv)
+		assert.ErrorIs(t, err, senderkey.ErrUnknownSenderKey, "carol's real key is still installed")
+	})
+
+	t.Run("Replacement requires rotation", func(t *testing.T) {
+		d, err := carol.RotateKey()
+		require.NoError(t, err)
+		assert.ErrorIs(t, bob.Process(carolURN, d, false), senderkey.ErrKeyInstalled)
+
+		require.NoError(t, bob.Process(carolURN, d, true))
+		require.NoError(t, bob.Process(carolURN, d, false), "repeated distributions are ignored")
+
+		plaintext, err := bob.Decrypt(tg.encrypt(t, "carol", "rotated"))
+		require.NoError(t, err)
+		assert.Equal(t, "rotated", string(plaintext))
+	})
+}
+
+func TestGroupMaxSkip(t *testing.T) {
+	groupURN := mustParse(t, "urn:sm:group:g")
+	aliceURN, bobURN := mustParse(t, "urn:sm:user:alice"), mustParse(t, "urn:sm:user:bob")
+	members := []urn.URN{aliceURN, bobURN}
+
+	alice, err := senderkey.NewGroup(groupURN, aliceURN, members)
+	require.NoError(t, err)
+	bob, err := senderkey.NewGroup(groupURN, bobURN, members, senderkey.WithMaxSkip(2))
+	require.NoError(t, err)
+	require.NoError(t, bob.Process(aliceURN, alice.Distribution(), false))
+
+	var last *transport.SecureEnvelope
+	for i := 0; i < 4; i++ {
+		last, err = alice.Encrypt([]byte("x"))
+		require.NoError(t, err)
+	}
+	_, err = bob.Decrypt(last)
+	assert.ErrorIs(t, err, senderkey.ErrTooManySkipped)
+}
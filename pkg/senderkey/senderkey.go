@@ -0,0 +1,185 @@
+// Package senderkey implements Sender Keys for group messaging.
+//
+// Each member of a group generates a sender key: a random chain key and an
+// Ed25519 signing key. The member sends a DistributionMessage holding the
+// current chain key and the public signing key to every other member once,
+// over a pairwise encrypted channel such as a sealed envelope or a ratchet
+// session. After that, each group message costs one encryption: the chain
+// key is ratcheted forward to derive a fresh message key, and the envelope
+// is signed with the signing key so members can tell who sent it.
+//
+// Sender key envelopes are addressed by GroupID, marked with
+// transport.SuiteSenderKeyAES256GCMEd25519, carry the key ID and iteration
+// in EncryptedSymmetricKey and pass SecureEnvelope.Validate.
+package senderkey
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/internal/chainkdf"
+	"github.com/illmade-knight/go-secure-messaging/internal/groupenv"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+const (
+	chainKeySize  = chainkdf.KeySize
+	headerVersion = 0x01
+	headerSize    = 9
+	messageInfo   = "go-secure-messaging/senderkey/v1 message"
+	adContext     = "go-secure-messaging/senderkey/v1 ad"
+
+	// DefaultMaxSkip is the default limit on how far ahead of the last
+	// received message a sender's chain may be advanced in one step, and on
+	// the number of skipped message keys kept per sender.
+	DefaultMaxSkip = 2000
+)
+
+var (
+	// ErrDecryptionFailed is returned when a message does not authenticate
+	// or its message key has already been used or discarded.
+	ErrDecryptionFailed = errors.New("decryption failed")
+	// ErrMalformed is returned for envelopes and distribution messages that
+	// are not well formed.
+	ErrMalformed = errors.New("malformed sender key message")
+	// ErrUnknownSenderKey is returned when a message uses a sender key that
+	// has not been distributed to this member.
+	ErrUnknownSenderKey = errors.New("unknown sender key")
+	// ErrNotMember is returned when a message or distribution comes from, or
+	// names, someone who is not a member of the group.
+	ErrNotMember = errors.New("not a group member")
+	// ErrWrongSender is returned when a distribution message arrives from
+	// someone other than the member it names as sender.
+	ErrWrongSender = errors.New("distribution from wrong sender")
+	// ErrKeyInstalled is returned when a distribution message would replace
+	// a member's sender key without being processed as a rotation.
+	ErrKeyInstalled = errors.New("sender key already installed")
+	// ErrTooManySkipped is returned when a message would require skipping
+	// more message keys than allowed.
+	ErrTooManySkipped = groupenv.ErrTooManySkipped
+)
+
+// DistributionMessage hands a member's sender key to the rest of the group.
+// It contains secret key material and must only be sent over a pairwise
+// encrypted and authenticated channel.
+type DistributionMessage struct {
+	GroupID    urn.URN           `json:"groupId"`
+	SenderID   urn.URN           `json:"senderId"`
+	KeyID      uint32            `json:"keyId"`
+	Iteration  uint32            `json:"iteration"`
+	ChainKey   []byte            `json:"chainKey"`
+	SigningKey ed25519.PublicKey `json:"signingKey"`
+}
+
+// Validate checks that the message is complete and its keys well formed.
+func (d *DistributionMessage) Validate() error {
+	switch {
+	case d.GroupID.IsZero() || d.SenderID.IsZero():
+		return fmt.Errorf("%w: distribution must name a group and sender", ErrMalformed)
+	case len(d.ChainKey) != chainKeySize:
+		return fmt.Errorf("%w: chain key must be %d bytes", ErrMalformed, chainKeySize)
+	case len(d.SigningKey) != ed25519.PublicKeySize:
+		return fmt.Errorf("%w: signing key must be %d bytes", ErrMalformed, ed25519.PublicKeySize)
+	}
+	return nil
+}
+
+// senderKey is the sending half of a sender key.
+type senderKey struct {
+	keyID      uint32
+	iteration  uint32
+	chainKey   []byte
+	signingKey ed25519.PrivateKey
+}
+
+func newSenderKey() (*senderKey, error) {
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	chainKey := make([]byte, chainKeySize)
+	if _, err := rand.Read(chainKey); err != nil {
+		return nil, err
+	}
+	_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &senderKey{keyID: binary.BigEndian.Uint32(id[:]), chainKey: chainKey, signingKey: signingKey}, nil
+}
+
+func (k *senderKey) distribution(group, sender urn.URN) *DistributionMessage {
+	return &DistributionMessage{
+		GroupID:    group,
+		SenderID:   sender,
+		KeyID:      k.keyID,
+		Iteration:  k.iteration,
+		ChainKey:   append([]byte(nil), k.chainKey...),
+		SigningKey: k.signingKey.Public().(ed25519.PublicKey),
+	}
+}
+
+// next returns the message key for the current iteration and advances the
+// chain.
+func (k *senderKey) next() (uint32, []byte) {
+	iteration := k.iteration
+	var messageKey []byte
+	k.chainKey, messageKey = chainkdf.Next(k.chainKey)
+	k.iteration++
+	return iteration, messageKey
+}
+
+// receiverKey is the receiving half of another member's sender key.
+type receiverKey struct {
+	keyID      uint32
+	signingKey ed25519.PublicKey
+	chain      *groupenv.Chain
+}
+
+func newReceiverKey(d *DistributionMessage) *receiverKey {
+	return &receiverKey{
+		keyID:      d.KeyID,
+		signingKey: d.SigningKey,
+		chain:      groupenv.NewChain(d.Iteration, bytes.Clone(d.ChainKey), chainStep),
+	}
+}
+
+// messageKey returns the key for iteration. The caller must only commit the
+// result if the message decrypts, so it operates on and returns a copy.
+func (k *receiverKey) messageKey(iteration uint32, maxSkip int) (*receiverKey, []byte, error) {
+	chain, key, err := k.chain.MessageKey(iteration, maxSkip)
+	if errors.Is(err, groupenv.ErrKeyUsed) {
+		return nil, nil, fmt.Errorf("%w: %w", ErrDecryptionFailed, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	next := *k
+	next.chain = chain
+	return &next, key, nil
+}
+
+func chainStep(chainKey []byte, _ uint32) ([]byte, []byte) {
+	return chainkdf.Next(chainKey)
+}
+
+// --- Message format ---
+
+// header is carried in EncryptedSymmetricKey: version (1), key ID (4) and
+// iteration (4), big-endian.
+func marshalHeader(keyID, iteration uint32) []byte {
+	b := []byte{headerVersion}
+	b = binary.BigEndian.AppendUint32(b, keyID)
+	return binary.BigEndian.AppendUint32(b, iteration)
+}
+
+func parseHeader(b []byte) (keyID, iteration uint32, err error) {
+	if len(b) != headerSize || b[0] != headerVersion {
+		return 0, 0, fmt.Errorf("%w: invalid header", ErrMalformed)
+	}
+	return binary.BigEndian.Uint32(b[1:]), binary.BigEndian.Uint32(b[5:]), nil
+}
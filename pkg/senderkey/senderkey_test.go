@@ -0,0 +1,62 @@
+package senderkey_test
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/senderkey"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, s string) urn.URN {
+	t.Helper()
+	u, err := urn.Parse(s)
+	require.NoError(t, err)
+	return u
+}
+
+func TestDistributionMessage(t *testing.T) {
+	groupURN := mustParse(t, "urn:sm:group:book-club")
+	alice := mustParse(t, "urn:sm:user:alice")
+
+	g, err := senderkey.NewGroup(groupURN, alice, nil)
+	require.NoError(t, err)
+	d := g.Distribution()
+	require.NoError(t, d.Validate())
+	assert.Equal(t, groupURN, d.GroupID)
+	assert.Equal(t, alice, d.SenderID)
+	assert.Zero(t, d.Iteration)
+
+	t.Run("JSON round-trip", func(t *testing.T) {
+		data, err := json.Marshal(d)
+		require.NoError(t, err)
+		var decoded senderkey.DistributionMessage
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, d, &decoded)
+	})
+
+	t.Run("Does not expose internal state", func(t *testing.T) {
+		d.ChainKey[0] ^= 1
+		assert.NotEqual(t, d.ChainKey, g.Distribution().ChainKey)
+	})
+
+	testCases := []struct {
+		name   string
+		modify func(d *senderkey.DistributionMessage)
+	}{
+		{name: "Missing group", modify: func(d *senderkey.DistributionMessage) { d.GroupID = urn.URN{} }},
+		{name: "Missing sender", modify: func(d *senderkey.DistributionMessage) { d.SenderID = urn.URN{} }},
+		{name: "Short chain key", modify: func(d *senderkey.DistributionMessage) { d.ChainKey = d.ChainKey[:16] }},
+		{name: "Short signing key", modify: func(d *senderkey.DistributionMessage) { d.SigningKey = ed25519.PublicKey{1} }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			invalid := g.Distribution()
+			tc.modify(invalid)
+			assert.ErrorIs(t, invalid.Validate(), senderkey.ErrMalformed)
+		})
+	}
+}
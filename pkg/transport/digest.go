@@ -49,7 +49,7 @@ func DigestToProto(native *EncryptedDigest) *EncryptedDigestPb {
 }
 
 // DigestFromProto converts the Protobuf digest representation into the idiomatic Go struct.
-// It validates that the string identifiers are valid URNs.
+// It validates that the string identifiers are valid, non-empty URNs.
 func DigestFromProto(proto *EncryptedDigestPb) (*EncryptedDigest, error) {
 	if proto == nil {
 		return nil, nil
@@ -61,6 +61,10 @@ func DigestFromProto(proto *EncryptedDigestPb) (*EncryptedDigest, error) {
 			continue // Skip nil items in the slice
 		}
 
+		// Unlike envelopes, every digest item must belong to a conversation.
+		if item.ConversationId == "" {
+			return nil, fmt.Errorf("item %d: failed to parse conversation id: %w", i, ErrMissingField)
+		}
 		conversationID, err := urn.Parse(item.ConversationId)
 		if err != nil {
 			return nil, fmt.Errorf("item %d: failed to parse conversation id: %w", i, err)
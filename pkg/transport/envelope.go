@@ -2,6 +2,8 @@ package transport
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
 	smv1 "github.com/tinywideclouds/go-action-intention-protos/src/action_intention/envelope/v1"
@@ -24,6 +26,15 @@ type SecureEnvelope struct {
 	EncryptedSymmetricKey []byte
 	Signature             []byte
 	EncryptedSnippet      []byte // ADDED
+	// Suite records the algorithms used for this envelope. It is carried in
+	// the protobuf form as an extension field; see extension.go.
+	Suite SuiteID
+	// SentAt is when the sender created the envelope, and ExpiresAt is when
+	// it should be discarded unread. Both are optional, carried with
+	// millisecond precision as extension fields, and covered by the
+	// signature. Use Stamp to set them before signing.
+	SentAt    time.Time
+	ExpiresAt time.Time
 }
 
 // ToProto converts the idiomatic Go struct into its Protobuf representation.
@@ -31,7 +42,7 @@ func ToProto(native *SecureEnvelope) *SecureEnvelopePb {
 	if native == nil {
 		return nil
 	}
-	return &SecureEnvelopePb{
+	pb := &SecureEnvelopePb{
 		MessageId:             native.MessageID,
 		SenderId:              native.SenderID.String(),
 		RecipientId:           native.RecipientID.String(),
@@ -42,6 +53,10 @@ func ToProto(native *SecureEnvelope) *SecureEnvelopePb {
 		Signature:             native.Signature,
 		EncryptedSnippet:      native.EncryptedSnippet, // ADDED
 	}
+	appendExtVarint(pb.ProtoReflect(), extSuite, uint64(native.Suite))
+	appendExtVarint(pb.ProtoReflect(), extSentAt, unixMilli(native.SentAt))
+	appendExtVarint(pb.ProtoReflect(), extExpiresAt, unixMilli(native.ExpiresAt))
+	return pb
 }
 
 // FromProto converts the Protobuf representation into the idiomatic Go struct.
@@ -70,6 +85,24 @@ func FromProto(proto *SecureEnvelopePb) (*SecureEnvelope, error) {
 		return nil, fmt.Errorf("failed to parse conversation id: %w", err)
 	}
 
+	suite, err := extVarint(proto.ProtoReflect(), extSuite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse suite: %w", err)
+	}
+	if suite > math.MaxUint16 {
+		return nil, fmt.Errorf("failed to parse suite: %d is out of range", suite)
+	}
+
+	sentAt, err := extTime(proto.ProtoReflect(), extSentAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sent at: %w", err)
+	}
+
+	expiresAt, err := extTime(proto.ProtoReflect(), extExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expires at: %w", err)
+	}
+
 	return &SecureEnvelope{
 		MessageID:             proto.MessageId,
 		SenderID:              senderID,
@@ -80,6 +113,9 @@ func FromProto(proto *SecureEnvelopePb) (*SecureEnvelope, error) {
 		EncryptedSymmetricKey: proto.EncryptedSymmetricKey,
 		Signature:             proto.Signature,
 		EncryptedSnippet:      proto.EncryptedSnippet, // ADDED
+		Suite:                 SuiteID(suite),
+		SentAt:                sentAt,
+		ExpiresAt:             expiresAt,
 	}, nil
 }
 
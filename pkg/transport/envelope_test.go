@@ -2,11 +2,14 @@ package transport_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
 	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestEnvelopeConversions(t *testing.T) {
@@ -49,6 +52,71 @@ func TestEnvelopeConversions(t *testing.T) {
 		assert.Equal(t, nativeEnvelope.EncryptedSnippet, roundTrippedEnvelope.EncryptedSnippet)
 	})
 
+	t.Run("Reserved Characters Round-trip", func(t *testing.T) {
+		emailSender, err := urn.New(urn.SecureMessaging, urn.EntityTypeUser, "alice@example.com:work")
+		require.NoError(t, err)
+
+		envelope := &transport.SecureEnvelope{
+			MessageID:   "msg-456",
+			SenderID:    emailSender,
+			RecipientID: recipientURN,
+		}
+
+		roundTripped, err := transport.FromProto(transport.ToProto(envelope))
+		require.NoError(t, err)
+		assert.Equal(t, envelope, roundTripped)
+	})
+
+	t.Run("Suite Round-trip", func(t *testing.T) {
+		envelope := &transport.SecureEnvelope{
+			MessageID: "msg-789",
+			SenderID:  senderURN,
+			Suite:     transport.SuiteX25519XChaCha20Poly1305Ed25519,
+		}
+
+		// The suite is an extension field, so it must survive real wire
+		// encoding, not just the in-memory conversion.
+		wire, err := proto.Marshal(transport.ToProto(envelope))
+		require.NoError(t, err)
+		var decoded transport.SecureEnvelopePb
+		require.NoError(t, proto.Unmarshal(wire, &decoded))
+
+		roundTripped, err := transport.FromProto(&decoded)
+		require.NoError(t, err)
+		assert.Equal(t, envelope, roundTripped)
+
+		// An envelope without a suite encodes no extension bytes.
+		envelope.Suite = transport.SuiteUnspecified
+		assert.Empty(t, transport.ToProto(envelope).ProtoReflect().GetUnknown())
+	})
+
+	t.Run("Timestamps Round-trip", func(t *testing.T) {
+		envelope := &transport.SecureEnvelope{
+			MessageID: "msg-790",
+			SenderID:  senderURN,
+			SentAt:    time.Date(2025, 6, 1, 12, 0, 0, int(123*time.Millisecond), time.UTC),
+			ExpiresAt: time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC),
+		}
+
+		wire, err := proto.Marshal(transport.ToProto(envelope))
+		require.NoError(t, err)
+		var decoded transport.SecureEnvelopePb
+		require.NoError(t, proto.Unmarshal(wire, &decoded))
+
+		roundTripped, err := transport.FromProto(&decoded)
+		require.NoError(t, err)
+		assert.Equal(t, envelope, roundTripped)
+
+		// Times are carried in milliseconds, and unset times are not written.
+		envelope.SentAt = envelope.SentAt.Add(456 * time.Microsecond)
+		roundTripped, err = transport.FromProto(transport.ToProto(envelope))
+		require.NoError(t, err)
+		assert.Equal(t, envelope.SentAt.Truncate(time.Millisecond), roundTripped.SentAt)
+
+		envelope.SentAt, envelope.ExpiresAt = time.Time{}, time.Time{}
+		assert.Empty(t, transport.ToProto(envelope).ProtoReflect().GetUnknown())
+	})
+
 	t.Run("FromProto Error Handling", func(t *testing.T) {
 		// Base valid proto for modification
 		baseProto := func() *transport.SecureEnvelopePb {
@@ -93,6 +161,38 @@ func TestEnvelopeConversions(t *testing.T) {
 				},
 				expectedError: "failed to parse conversation id",
 			},
+			{
+				name: "Suite Out Of Range",
+				modifier: func(pb *transport.SecureEnvelopePb) {
+					unknown := protowire.AppendTag(nil, 1000, protowire.VarintType)
+					pb.ProtoReflect().SetUnknown(protowire.AppendVarint(unknown, 1<<16))
+				},
+				expectedError: "failed to parse suite",
+			},
+			{
+				name: "Suite Wrong Wire Type",
+				modifier: func(pb *transport.SecureEnvelopePb) {
+					unknown := protowire.AppendTag(nil, 1000, protowire.BytesType)
+					pb.ProtoReflect().SetUnknown(protowire.AppendBytes(unknown, []byte{1}))
+				},
+				expectedError: "failed to parse suite",
+			},
+			{
+				name: "SentAt Wrong Wire Type",
+				modifier: func(pb *transport.SecureEnvelopePb) {
+					unknown := protowire.AppendTag(nil, 1001, protowire.Fixed64Type)
+					pb.ProtoReflect().SetUnknown(protowire.AppendFixed64(unknown, 1))
+				},
+				expectedError: "failed to parse sent at",
+			},
+			{
+				name: "ExpiresAt Out Of Range",
+				modifier: func(pb *transport.SecureEnvelopePb) {
+					unknown := protowire.AppendTag(nil, 1002, protowire.VarintType)
+					pb.ProtoReflect().SetUnknown(protowire.AppendVarint(unknown, 1<<63))
+				},
+				expectedError: "failed to parse expires at",
+			},
 		}
 
 		for _, tc := range testCases {
@@ -0,0 +1,53 @@
+package transport
+
+import "time"
+
+// Stamp sets SentAt to sentAt, or to the current time if sentAt is zero, and,
+// if ttl is positive, sets ExpiresAt to ttl after it. Both are truncated to
+// milliseconds so that they survive the protobuf form unchanged. Stamp must
+// be called before the envelope is signed.
+func (e *SecureEnvelope) Stamp(sentAt time.Time, ttl time.Duration) {
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+	e.SentAt = time.UnixMilli(sentAt.UnixMilli()).UTC()
+	if ttl > 0 {
+		e.ExpiresAt = e.SentAt.Add(ttl.Truncate(time.Millisecond))
+	}
+}
+
+// IsExpired reports whether the envelope has an ExpiresAt that is not after
+// now. An envelope without one never expires. ExpiresAt is compared as is:
+// only Stamp truncates it to milliseconds, so one set directly with a finer
+// precision expires up to 1ms later once it has been through the protobuf
+// form.
+func (e *SecureEnvelope) IsExpired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// Filter returns a new list holding the envelopes for which keep returns
+// true, in their original order. Nil envelopes are dropped.
+func (l *SecureEnvelopeList) Filter(keep func(*SecureEnvelope) bool) *SecureEnvelopeList {
+	filtered := &SecureEnvelopeList{}
+	if l == nil {
+		return filtered
+	}
+	for _, env := range l.Envelopes {
+		if env != nil && keep(env) {
+			filtered.Envelopes = append(filtered.Envelopes, env)
+		}
+	}
+	return filtered
+}
+
+// Unexpired returns a new list without the envelopes that have expired at
+// now.
+func (l *SecureEnvelopeList) Unexpired(now time.Time) *SecureEnvelopeList {
+	return l.Filter(func(e *SecureEnvelope) bool { return !e.IsExpired(now) })
+}
+
+// Expired returns a new list of the envelopes that have expired at now, for
+// example to delete them from a queue.
+func (l *SecureEnvelopeList) Expired(now time.Time) *SecureEnvelopeList {
+	return l.Filter(func(e *SecureEnvelope) bool { return e.IsExpired(now) })
+}
@@ -0,0 +1,77 @@
+package transport_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStamp(t *testing.T) {
+	sentAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)).Add(1500 * time.Microsecond)
+
+	t.Run("Sent At And TTL", func(t *testing.T) {
+		env := &transport.SecureEnvelope{}
+		env.Stamp(sentAt, time.Hour+time.Microsecond)
+		assert.Equal(t, time.Date(2025, 6, 1, 10, 0, 0, int(time.Millisecond), time.UTC), env.SentAt)
+		assert.Equal(t, env.SentAt.Add(time.Hour), env.ExpiresAt)
+	})
+
+	t.Run("No TTL", func(t *testing.T) {
+		env := &transport.SecureEnvelope{}
+		env.Stamp(sentAt, 0)
+		assert.False(t, env.SentAt.IsZero())
+		assert.True(t, env.ExpiresAt.IsZero())
+	})
+
+	t.Run("Defaults To Now", func(t *testing.T) {
+		before := time.Now().Truncate(time.Millisecond)
+		env := &transport.SecureEnvelope{}
+		env.Stamp(time.Time{}, time.Minute)
+		assert.False(t, env.SentAt.Before(before))
+		assert.False(t, env.SentAt.After(time.Now()))
+		assert.Equal(t, time.Minute, env.ExpiresAt.Sub(env.SentAt))
+	})
+}
+
+func TestIsExpired(t *testing.T) {
+	expiresAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		expiresAt time.Time
+		now       time.Time
+		expected  bool
+	}{
+		{name: "Before Expiry", expiresAt: expiresAt, now: expiresAt.Add(-time.Millisecond)},
+		{name: "At Expiry", expiresAt: expiresAt, now: expiresAt, expected: true},
+		{name: "After Expiry", expiresAt: expiresAt, now: expiresAt.Add(time.Hour), expected: true},
+		{name: "No Expiry", now: expiresAt.Add(100 * 365 * 24 * time.Hour)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := &transport.SecureEnvelope{ExpiresAt: tc.expiresAt}
+			assert.Equal(t, tc.expected, env.IsExpired(tc.now))
+		})
+	}
+}
+
+func TestListExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	expired := &transport.SecureEnvelope{MessageID: "expired", ExpiresAt: now.Add(-time.Second)}
+	live := &transport.SecureEnvelope{MessageID: "live", ExpiresAt: now.Add(time.Second)}
+	forever := &transport.SecureEnvelope{MessageID: "forever"}
+	list := &transport.SecureEnvelopeList{Envelopes: []*transport.SecureEnvelope{expired, live, nil, forever}}
+
+	assert.Equal(t, []*transport.SecureEnvelope{live, forever}, list.Unexpired(now).Envelopes)
+	assert.Equal(t, []*transport.SecureEnvelope{expired}, list.Expired(now).Envelopes)
+	assert.Len(t, list.Envelopes, 4, "filtering must not modify the original list")
+
+	assert.Equal(t, []*transport.SecureEnvelope{forever}, list.Filter(func(e *transport.SecureEnvelope) bool {
+		return e.MessageID == "forever"
+	}).Envelopes)
+
+	var nilList *transport.SecureEnvelopeList
+	assert.Empty(t, nilList.Unexpired(now).Envelopes)
+}
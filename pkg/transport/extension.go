@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The SecureEnvelopePb schema is owned by the shared protos module, so fields
+// added by this library travel as unknown fields with numbers from a range
+// the schema does not use. Generated code in other languages preserves
+// unknown fields, so they survive relays that do not understand them.
+const (
+	// extSuite carries SecureEnvelope.Suite as a varint.
+	extSuite protowire.Number = 1000
+	// extSentAt carries SecureEnvelope.SentAt as Unix milliseconds.
+	extSentAt protowire.Number = 1001
+	// extExpiresAt carries SecureEnvelope.ExpiresAt as Unix milliseconds.
+	extExpiresAt protowire.Number = 1002
+)
+
+// appendExtVarint appends a varint extension field to the message's unknown
+// fields. A zero value is not written.
+func appendExtVarint(m protoreflect.Message, num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+	b := m.GetUnknown()
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	b = protowire.AppendVarint(b, v)
+	m.SetUnknown(b)
+}
+
+// extVarint returns the last value of a varint extension field, or 0 if the
+// field is absent.
+func extVarint(m protoreflect.Message, num protowire.Number) (uint64, error) {
+	var v uint64
+	b := m.GetUnknown()
+	for len(b) > 0 {
+		n, typ, tagLen := protowire.ConsumeTag(b)
+		if tagLen < 0 {
+			return 0, protowire.ParseError(tagLen)
+		}
+		if n == num && typ == protowire.VarintType {
+			val, valLen := protowire.ConsumeVarint(b[tagLen:])
+			if valLen < 0 {
+				return 0, protowire.ParseError(valLen)
+			}
+			v = val
+			b = b[tagLen+valLen:]
+			continue
+		}
+		if n == num {
+			return 0, fmt.Errorf("extension field %d has wire type %d, expected varint", num, typ)
+		}
+		fieldLen := protowire.ConsumeFieldValue(n, typ, b[tagLen:])
+		if fieldLen < 0 {
+			return 0, protowire.ParseError(fieldLen)
+		}
+		b = b[tagLen+fieldLen:]
+	}
+	return v, nil
+}
+
+// unixMilli returns t as Unix milliseconds, or 0 for a zero time. Times less
+// than 1ms after the Unix epoch cannot be represented and also return 0, so
+// they read back as unset; Validate rejects them.
+func unixMilli(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	ms := t.UnixMilli()
+	if ms <= 0 {
+		return 0
+	}
+	return uint64(ms)
+}
+
+// extTime returns the time held in a Unix milliseconds extension field, or
+// the zero time if the field is absent.
+func extTime(m protoreflect.Message, num protowire.Number) (time.Time, error) {
+	ms, err := extVarint(m, num)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ms == 0 {
+		return time.Time{}, nil
+	}
+	if ms > math.MaxInt64 {
+		return time.Time{}, fmt.Errorf("%d is out of range", ms)
+	}
+	return time.UnixMilli(int64(ms)).UTC(), nil
+}
@@ -0,0 +1,173 @@
+package transport
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// signedPreKeyContext prefixes the payload signed for a signed prekey.
+const signedPreKeyContext = "go-secure-messaging/signed-prekey/v1"
+
+// ErrInvalidKey is returned when a key in a prekey bundle is missing or
+// malformed.
+var ErrInvalidKey = errors.New("invalid key")
+
+// IdentityKey is the long-term public identity of a user or device. The
+// Ed25519 SigningKey signs prekeys and envelopes; the X25519 DHKey takes part
+// in session key agreement.
+type IdentityKey struct {
+	ID         urn.URN           `json:"id"`
+	SigningKey ed25519.PublicKey `json:"signingKey"`
+	DHKey      []byte            `json:"dhKey"`
+}
+
+// PreKey is an X25519 public prekey and the ID its owner uses to find the
+// matching private key. IDs must be non-zero; zero means "no prekey" to the
+// session layer.
+type PreKey struct {
+	ID        uint32 `json:"id"`
+	PublicKey []byte `json:"publicKey"`
+}
+
+// SignedPreKey is a medium-term prekey signed by its owner's identity key.
+type SignedPreKey struct {
+	PreKey
+	Signature []byte `json:"signature"`
+}
+
+// PreKeyBundle is everything needed to start a session with an offline
+// owner: their identity, a signed prekey and, if any were left, a one-time
+// prekey.
+type PreKeyBundle struct {
+	IdentityKey   IdentityKey  `json:"identityKey"`
+	SignedPreKey  SignedPreKey `json:"signedPreKey"`
+	OneTimePreKey *PreKey      `json:"oneTimePreKey,omitempty"`
+}
+
+// ID returns the URN of the bundle's owner.
+func (b *PreKeyBundle) ID() urn.URN {
+	return b.IdentityKey.ID
+}
+
+// Validate checks that the identity key is well formed.
+func (k IdentityKey) Validate() error {
+	if k.ID.IsZero() {
+		return fmt.Errorf("%w: identity key has no owner", ErrInvalidKey)
+	}
+	if len(k.SigningKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: signing key must be %d bytes, got %d", ErrInvalidKey, ed25519.PublicKeySize, len(k.SigningKey))
+	}
+	_, err := k.ECDH()
+	return err
+}
+
+// ECDH returns the identity's X25519 key agreement key.
+func (k IdentityKey) ECDH() (*ecdh.PublicKey, error) {
+	pub, err := ecdh.X25519().NewPublicKey(k.DHKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: identity DH key: %w", ErrInvalidKey, err)
+	}
+	return pub, nil
+}
+
+// Validate checks that the prekey has a non-zero ID and a well-formed
+// X25519 public key.
+func (k PreKey) Validate() error {
+	if k.ID == 0 {
+		return fmt.Errorf("%w: prekey ID must be non-zero", ErrInvalidKey)
+	}
+	_, err := k.ECDH()
+	return err
+}
+
+// ECDH returns the prekey as an X25519 public key.
+func (k PreKey) ECDH() (*ecdh.PublicKey, error) {
+	pub, err := ecdh.X25519().NewPublicKey(k.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: prekey %d: %w", ErrInvalidKey, k.ID, err)
+	}
+	return pub, nil
+}
+
+// SignPreKey signs an X25519 prekey for identity. The signer must hold the
+// Ed25519 private key matching identity.SigningKey. The signature also
+// covers identity.DHKey, so the identity's key agreement key cannot be
+// swapped without invalidating its prekeys.
+func SignPreKey(identity IdentityKey, id uint32, publicKey *ecdh.PublicKey, signer crypto.Signer) (SignedPreKey, error) {
+	if err := identity.Validate(); err != nil {
+		return SignedPreKey{}, err
+	}
+	if id == 0 {
+		return SignedPreKey{}, fmt.Errorf("%w: prekey ID must be non-zero", ErrInvalidKey)
+	}
+	if publicKey == nil || publicKey.Curve() != ecdh.X25519() {
+		return SignedPreKey{}, fmt.Errorf("%w: signed prekey must be X25519", ErrInvalidKey)
+	}
+	signingKey, ok := signer.Public().(ed25519.PublicKey)
+	if !ok {
+		return SignedPreKey{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, signer.Public())
+	}
+	if !signingKey.Equal(identity.SigningKey) {
+		return SignedPreKey{}, fmt.Errorf("%w: signer does not match the identity's signing key", ErrInvalidKey)
+	}
+	spk := SignedPreKey{PreKey: PreKey{ID: id, PublicKey: publicKey.Bytes()}}
+	sig, err := signer.Sign(rand.Reader, signedPreKeyPayload(identity, spk.PreKey), crypto.Hash(0))
+	if err != nil {
+		return SignedPreKey{}, fmt.Errorf("failed to sign prekey: %w", err)
+	}
+	spk.Signature = sig
+	return spk, nil
+}
+
+// VerifyPreKey checks that spk was signed by identity for its owner and DH
+// key.
+func VerifyPreKey(identity IdentityKey, spk SignedPreKey) error {
+	if err := identity.Validate(); err != nil {
+		return err
+	}
+	if err := spk.PreKey.Validate(); err != nil {
+		return err
+	}
+	if !ed25519.Verify(identity.SigningKey, signedPreKeyPayload(identity, spk.PreKey), spk.Signature) {
+		return fmt.Errorf("%w: signed prekey %d", ErrInvalidSignature, spk.ID)
+	}
+	return nil
+}
+
+// Verify checks the bundle's identity key, the signature on its signed
+// prekey and the format of its one-time prekey, including that both prekey
+// IDs are non-zero. A bundle must be verified
+// before its keys are used.
+func (b *PreKeyBundle) Verify() error {
+	if err := VerifyPreKey(b.IdentityKey, b.SignedPreKey); err != nil {
+		return err
+	}
+	if b.OneTimePreKey != nil {
+		if err := b.OneTimePreKey.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signedPreKeyPayload binds a prekey to its owner and the owner's identity
+// DH key, so a signed prekey cannot be presented as belonging to anyone else
+// or alongside a substituted DH key.
+func signedPreKeyPayload(identity IdentityKey, k PreKey) []byte {
+	o := identity.ID.String()
+	b := make([]byte, 0, len(signedPreKeyContext)+4+len(o)+4+len(identity.DHKey)+4+len(k.PublicKey))
+	b = append(b, signedPreKeyContext...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(o)))
+	b = append(b, o...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(identity.DHKey)))
+	b = append(b, identity.DHKey...)
+	b = binary.BigEndian.AppendUint32(b, k.ID)
+	return append(b, k.PublicKey...)
+}
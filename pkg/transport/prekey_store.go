@@ -0,0 +1,173 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+)
+
+// ErrPreKeyNotFound is returned by a PreKeyStore when an owner has not
+// published an identity key and signed prekey.
+var ErrPreKeyNotFound = errors.New("prekey bundle not found")
+
+// PreKeyStore is the interface to a key server. Owners publish their
+// identity key, a signed prekey and a batch of one-time prekeys; anyone
+// wanting to start a session fetches a bundle, which consumes one of the
+// one-time prekeys.
+type PreKeyStore interface {
+	// PublishIdentity sets the owner's identity key. Replacing an identity
+	// key discards the owner's prekeys, which were signed by the old key.
+	PublishIdentity(ctx context.Context, identity IdentityKey) error
+	// PublishSignedPreKey replaces the owner's signed prekey. It fails unless
+	// the prekey verifies against the owner's published identity key.
+	PublishSignedPreKey(ctx context.Context, owner urn.URN, spk SignedPreKey) error
+	// PublishOneTimePreKeys adds one-time prekeys for the owner. It fails if
+	// any ID is zero or is already in use by an unconsumed prekey.
+	PublishOneTimePreKeys(ctx context.Context, owner urn.URN, keys []PreKey) error
+	// FetchBundle returns a bundle for owner, removing the one-time prekey it
+	// contains from the store. The bundle has no one-time prekey once they
+	// run out.
+	FetchBundle(ctx context.Context, owner urn.URN) (*PreKeyBundle, error)
+	// OneTimePreKeyCount reports how many one-time prekeys the owner has
+	// left, so clients know when to publish more.
+	OneTimePreKeyCount(ctx context.Context, owner urn.URN) (int, error)
+}
+
+type preKeyRecord struct {
+	identity     IdentityKey
+	signedPreKey *SignedPreKey
+	oneTime      []PreKey
+}
+
+// MemoryPreKeyStore is an in-memory PreKeyStore. One-time prekeys are handed
+// out in the order they were published.
+type MemoryPreKeyStore struct {
+	mu      sync.Mutex
+	records map[urn.URN]*preKeyRecord
+}
+
+// NewMemoryPreKeyStore creates an empty MemoryPreKeyStore.
+func NewMemoryPreKeyStore() *MemoryPreKeyStore {
+	return &MemoryPreKeyStore{records: make(map[urn.URN]*preKeyRecord)}
+}
+
+// PublishIdentity implements PreKeyStore.
+func (m *MemoryPreKeyStore) PublishIdentity(_ context.Context, identity IdentityKey) error {
+	if err := identity.Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.records[identity.ID]; ok && r.identity.SigningKey.Equal(identity.SigningKey) && string(r.identity.DHKey) == string(identity.DHKey) {
+		return nil
+	}
+	m.records[identity.ID] = &preKeyRecord{identity: cloneIdentityKey(identity)}
+	return nil
+}
+
+// PublishSignedPreKey implements PreKeyStore.
+func (m *MemoryPreKeyStore) PublishSignedPreKey(_ context.Context, owner urn.URN, spk SignedPreKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, err := m.record(owner)
+	if err != nil {
+		return err
+	}
+	if err := VerifyPreKey(r.identity, spk); err != nil {
+		return err
+	}
+	spk = cloneSignedPreKey(spk)
+	r.signedPreKey = &spk
+	return nil
+}
+
+// PublishOneTimePreKeys implements PreKeyStore.
+func (m *MemoryPreKeyStore) PublishOneTimePreKeys(_ context.Context, owner urn.URN, keys []PreKey) error {
+	for _, k := range keys {
+		if err := k.Validate(); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, err := m.record(owner)
+	if err != nil {
+		return err
+	}
+	ids := make(map[uint32]bool, len(r.oneTime)+len(keys))
+	for _, k := range r.oneTime {
+		ids[k.ID] = true
+	}
+	for _, k := range keys {
+		if ids[k.ID] {
+			return fmt.Errorf("%w: duplicate one-time prekey ID %d", ErrInvalidKey, k.ID)
+		}
+		ids[k.ID] = true
+	}
+	for _, k := range keys {
+		r.oneTime = append(r.oneTime, clonePreKey(k))
+	}
+	return nil
+}
+
+// FetchBundle implements PreKeyStore.
+func (m *MemoryPreKeyStore) FetchBundle(_ context.Context, owner urn.URN) (*PreKeyBundle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, err := m.record(owner)
+	if err != nil {
+		return nil, err
+	}
+	if r.signedPreKey == nil {
+		return nil, fmt.Errorf("%w: %s has no signed prekey", ErrPreKeyNotFound, owner)
+	}
+	bundle := &PreKeyBundle{IdentityKey: cloneIdentityKey(r.identity), SignedPreKey: cloneSignedPreKey(*r.signedPreKey)}
+	if len(r.oneTime) > 0 {
+		otk := clonePreKey(r.oneTime[0])
+		r.oneTime = r.oneTime[1:]
+		bundle.OneTimePreKey = &otk
+	}
+	return bundle, nil
+}
+
+// OneTimePreKeyCount implements PreKeyStore.
+func (m *MemoryPreKeyStore) OneTimePreKeyCount(_ context.Context, owner urn.URN) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, err := m.record(owner)
+	if err != nil {
+		return 0, err
+	}
+	return len(r.oneTime), nil
+}
+
+func (m *MemoryPreKeyStore) record(owner urn.URN) (*preKeyRecord, error) {
+	r, ok := m.records[owner]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPreKeyNotFound, owner)
+	}
+	return r, nil
+}
+
+// cloneIdentityKey and the functions below copy key material, so that the
+// store never shares slices with publishers or with callers of FetchBundle.
+func cloneIdentityKey(k IdentityKey) IdentityKey {
+	k.SigningKey = bytes.Clone(k.SigningKey)
+	k.DHKey = bytes.Clone(k.DHKey)
+	return k
+}
+
+func clonePreKey(k PreKey) PreKey {
+	k.PublicKey = bytes.Clone(k.PublicKey)
+	return k
+}
+
+func cloneSignedPreKey(k SignedPreKey) SignedPreKey {
+	k.PreKey = clonePreKey(k.PreKey)
+	k.Signature = bytes.Clone(k.Signature)
+	return k
+}
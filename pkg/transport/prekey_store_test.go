@@ -0,0 +1,99 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryPreKeyStore(t *testing.T) {
+	ctx := context.Background()
+	bob := newTestIdentity(t, "urn:sm:user:bob/device:phone")
+	mallory := newTestIdentity(t, "urn:sm:user:mallory")
+
+	var store transport.PreKeyStore = transport.NewMemoryPreKeyStore()
+
+	_, err := store.FetchBundle(ctx, bob.public.ID)
+	assert.ErrorIs(t, err, transport.ErrPreKeyNotFound)
+	assert.ErrorIs(t, store.PublishSignedPreKey(ctx, bob.public.ID, bob.signedPreKey(t, 1)), transport.ErrPreKeyNotFound)
+
+	require.NoError(t, store.PublishIdentity(ctx, bob.public))
+
+	_, err = store.FetchBundle(ctx, bob.public.ID)
+	assert.ErrorIs(t, err, transport.ErrPreKeyNotFound, "no signed prekey yet")
+
+	assert.ErrorIs(t, store.PublishSignedPreKey(ctx, bob.public.ID, mallory.signedPreKey(t, 1)), transport.ErrInvalidSignature)
+	require.NoError(t, store.PublishSignedPreKey(ctx, bob.public.ID, bob.signedPreKey(t, 1)))
+
+	oneTime := []transport.PreKey{
+		{ID: 10, PublicKey: newX25519(t).PublicKey().Bytes()},
+		{ID: 11, PublicKey: newX25519(t).PublicKey().Bytes()},
+	}
+	require.NoError(t, store.PublishOneTimePreKeys(ctx, bob.public.ID, oneTime))
+	assert.ErrorIs(t, store.PublishOneTimePreKeys(ctx, bob.public.ID, []transport.PreKey{{ID: 12}}), transport.ErrInvalidKey)
+	assert.ErrorIs(t, store.PublishOneTimePreKeys(ctx, bob.public.ID, []transport.PreKey{{ID: 0, PublicKey: newX25519(t).PublicKey().Bytes()}}), transport.ErrInvalidKey, "zero ID")
+	assert.ErrorIs(t, store.PublishOneTimePreKeys(ctx, bob.public.ID, []transport.PreKey{{ID: 10, PublicKey: newX25519(t).PublicKey().Bytes()}}), transport.ErrInvalidKey, "ID already published")
+	assert.ErrorIs(t, store.PublishOneTimePreKeys(ctx, bob.public.ID, []transport.PreKey{
+		{ID: 12, PublicKey: newX25519(t).PublicKey().Bytes()},
+		{ID: 12, PublicKey: newX25519(t).PublicKey().Bytes()},
+	}), transport.ErrInvalidKey, "ID repeated in batch")
+
+	count, err := store.OneTimePreKeyCount(ctx, bob.public.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Each fetch consumes one one-time prekey, in publication order.
+	for _, expected := range []uint32{10, 11} {
+		bundle, err := store.FetchBundle(ctx, bob.public.ID)
+		require.NoError(t, err)
+		require.NoError(t, bundle.Verify())
+		require.NotNil(t, bundle.OneTimePreKey)
+		assert.Equal(t, expected, bundle.OneTimePreKey.ID)
+	}
+	bundle, err := store.FetchBundle(ctx, bob.public.ID)
+	require.NoError(t, err)
+	assert.Nil(t, bundle.OneTimePreKey, "bundles are still served once one-time prekeys run out")
+
+	t.Run("Bundles do not share memory with the store", func(t *testing.T) {
+		otk := transport.PreKey{ID: 20, PublicKey: newX25519(t).PublicKey().Bytes()}
+		published := bytes.Clone(otk.PublicKey)
+		require.NoError(t, store.PublishOneTimePreKeys(ctx, bob.public.ID, []transport.PreKey{otk}))
+		otk.PublicKey[0] ^= 1
+
+		bundle, err := store.FetchBundle(ctx, bob.public.ID)
+		require.NoError(t, err)
+		require.NotNil(t, bundle.OneTimePreKey)
+		assert.Equal(t, published, bundle.OneTimePreKey.PublicKey, "publishers cannot change stored keys")
+
+		bundle.IdentityKey.SigningKey[0] ^= 1
+		bundle.IdentityKey.DHKey[0] ^= 1
+		bundle.SignedPreKey.PublicKey[0] ^= 1
+		bundle.SignedPreKey.Signature[0] ^= 1
+		bundle, err = store.FetchBundle(ctx, bob.public.ID)
+		require.NoError(t, err)
+		assert.NoError(t, bundle.Verify(), "callers cannot change stored keys")
+	})
+
+	t.Run("Republishing the same identity keeps prekeys", func(t *testing.T) {
+		require.NoError(t, store.PublishIdentity(ctx, bob.public))
+		_, err := store.FetchBundle(ctx, bob.public.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Replacing the identity discards prekeys", func(t *testing.T) {
+		rotated := newTestIdentity(t, bob.public.ID.String())
+		require.NoError(t, store.PublishIdentity(ctx, rotated.public))
+		_, err := store.FetchBundle(ctx, bob.public.ID)
+		assert.ErrorIs(t, err, transport.ErrPreKeyNotFound)
+	})
+
+	t.Run("Invalid identity", func(t *testing.T) {
+		invalid := mallory.public
+		invalid.DHKey = nil
+		assert.ErrorIs(t, store.PublishIdentity(ctx, invalid), transport.ErrInvalidKey)
+	})
+}
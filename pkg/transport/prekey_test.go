@@ -0,0 +1,191 @@
+package transport_test
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testIdentity is an owner's private key material for prekey tests.
+type testIdentity struct {
+	public  transport.IdentityKey
+	signing ed25519.PrivateKey
+}
+
+func newTestIdentity(t *testing.T, owner string) testIdentity {
+	t.Helper()
+	id, err := urn.Parse(owner)
+	require.NoError(t, err)
+	signingPub, signing, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return testIdentity{
+		public:  transport.IdentityKey{ID: id, SigningKey: signingPub, DHKey: newX25519(t).PublicKey().Bytes()},
+		signing: signing,
+	}
+}
+
+func newX25519(t *testing.T) *ecdh.PrivateKey {
+	t.Helper()
+	k, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return k
+}
+
+func (i testIdentity) signedPreKey(t *testing.T, id uint32) transport.SignedPreKey {
+	t.Helper()
+	spk, err := transport.SignPreKey(i.public, id, newX25519(t).PublicKey(), i.signing)
+	require.NoError(t, err)
+	return spk
+}
+
+func TestPreKeyBundleVerify(t *testing.T) {
+	bob := newTestIdentity(t, "urn:sm:user:bob/device:phone")
+	mallory := newTestIdentity(t, "urn:sm:user:mallory")
+
+	bundle := func() *transport.PreKeyBundle {
+		return &transport.PreKeyBundle{
+			IdentityKey:   bob.public,
+			SignedPreKey:  bob.signedPreKey(t, 1),
+			OneTimePreKey: &transport.PreKey{ID: 100, PublicKey: newX25519(t).PublicKey().Bytes()},
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		b := bundle()
+		require.NoError(t, b.Verify())
+		assert.Equal(t, bob.public.ID, b.ID())
+
+		b.OneTimePreKey = nil
+		assert.NoError(t, b.Verify(), "one-time prekeys are optional")
+	})
+
+	testCases := []struct {
+		name          string
+		modify        func(b *transport.PreKeyBundle)
+		expectedErrIs error
+	}{
+		{
+			name:          "Signed by another identity",
+			modify:        func(b *transport.PreKeyBundle) { b.SignedPreKey = mallory.signedPreKey(t, 1) },
+			expectedErrIs: transport.ErrInvalidSignature,
+		},
+		{
+			name: "Transplanted to another owner",
+			modify: func(b *transport.PreKeyBundle) {
+				b.IdentityKey.ID = mallory.public.ID
+			},
+			expectedErrIs: transport.ErrInvalidSignature,
+		},
+		{
+			name:          "Prekey ID changed",
+			modify:        func(b *transport.PreKeyBundle) { b.SignedPreKey.ID = 2 },
+			expectedErrIs: transport.ErrInvalidSignature,
+		},
+		{
+			name:          "Prekey swapped",
+			modify:        func(b *transport.PreKeyBundle) { b.SignedPreKey.PublicKey = newX25519(t).PublicKey().Bytes() },
+			expectedErrIs: transport.ErrInvalidSignature,
+		},
+		{
+			name:          "Identity DH key substituted",
+			modify:        func(b *transport.PreKeyBundle) { b.IdentityKey.DHKey = newX25519(t).PublicKey().Bytes() },
+			expectedErrIs: transport.ErrInvalidSignature,
+		},
+		{
+			name:          "Short signing key",
+			modify:        func(b *transport.PreKeyBundle) { b.IdentityKey.SigningKey = b.IdentityKey.SigningKey[:16] },
+			expectedErrIs: transport.ErrInvalidKey,
+		},
+		{
+			name:          "Bad identity DH key",
+			modify:        func(b *transport.PreKeyBundle) { b.IdentityKey.DHKey = []byte{1, 2, 3} },
+			expectedErrIs: transport.ErrInvalidKey,
+		},
+		{
+			name:          "Bad one-time prekey",
+			modify:        func(b *transport.PreKeyBundle) { b.OneTimePreKey.PublicKey = nil },
+			expectedErrIs: transport.ErrInvalidKey,
+		},
+		{
+			name:          "Zero signed prekey ID",
+			modify:        func(b *transport.PreKeyBundle) { b.SignedPreKey.ID = 0 },
+			expectedErrIs: transport.ErrInvalidKey,
+		},
+		{
+			name:          "Zero one-time prekey ID",
+			modify:        func(b *transport.PreKeyBundle) { b.OneTimePreKey.ID = 0 },
+			expectedErrIs: transport.ErrInvalidKey,
+		},
+		{
+			name:          "Missing owner",
+			modify:        func(b *transport.PreKeyBundle) { b.IdentityKey.ID = urn.URN{} },
+			expectedErrIs: transport.ErrInvalidKey,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := bundle()
+			tc.modify(b)
+			assert.ErrorIs(t, b.Verify(), tc.expectedErrIs)
+		})
+	}
+}
+
+func TestSignPreKeyErrors(t *testing.T) {
+	bob := newTestIdentity(t, "urn:sm:user:bob")
+
+	p256, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = transport.SignPreKey(bob.public, 1, p256.PublicKey(), bob.signing)
+	assert.ErrorIs(t, err, transport.ErrInvalidKey)
+
+	_, err = transport.SignPreKey(bob.public, 0, newX25519(t).PublicKey(), bob.signing)
+	assert.ErrorIs(t, err, transport.ErrInvalidKey, "zero ID")
+
+	mallory := newTestIdentity(t, "urn:sm:user:mallory")
+	_, err = transport.SignPreKey(bob.public, 1, newX25519(t).PublicKey(), mallory.signing)
+	assert.ErrorIs(t, err, transport.ErrInvalidKey, "signer is not the identity's")
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = transport.SignPreKey(bob.public, 1, newX25519(t).PublicKey(), ecdsaKey)
+	assert.ErrorIs(t, err, transport.ErrUnsupportedKey)
+}
+
+func TestPreKeyBundleJSON(t *testing.T) {
+	bob := newTestIdentity(t, "urn:sm:user:bob")
+	bundle := &transport.PreKeyBundle{
+		IdentityKey:   bob.public,
+		SignedPreKey:  bob.signedPreKey(t, 5),
+		OneTimePreKey: &transport.PreKey{ID: 9, PublicKey: newX25519(t).PublicKey().Bytes()},
+	}
+
+	data, err := json.Marshal(bundle)
+	require.NoError(t, err)
+
+	var fields map[string]map[string]any
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, "urn:sm:user:bob", fields["identityKey"]["id"])
+	assert.Equal(t, float64(5), fields["signedPreKey"]["id"], "the embedded PreKey fields are flattened")
+
+	var decoded transport.PreKeyBundle
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, bundle, &decoded)
+	assert.NoError(t, decoded.Verify())
+
+	t.Run("Without one-time prekey", func(t *testing.T) {
+		bundle.OneTimePreKey = nil
+		data, err := json.Marshal(bundle)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "oneTimePreKey")
+	})
+}
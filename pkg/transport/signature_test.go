@@ -0,0 +1,230 @@
+package transport_test
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// vectorEnvelope is the fixed envelope used by the signature test vectors.
+func vectorEnvelope(t *testing.T) *transport.SecureEnvelope {
+	t.Helper()
+	senderURN, err := urn.Parse("urn:sm:user:user-alice")
+	require.NoError(t, err)
+	recipientURN, err := urn.Parse("urn:sm:user:user-bob")
+	require.NoError(t, err)
+	return &transport.SecureEnvelope{
+		MessageID:             "msg-123",
+		SenderID:              senderURN,
+		RecipientID:           recipientURN,
+		EncryptedData:         []byte("encrypted-data"),
+		EncryptedSymmetricKey: []byte("encrypted-key"),
+		EncryptedSnippet:      []byte("snippet"),
+	}
+}
+
+// stampedEnvelope is vectorEnvelope with a send time and expiry.
+func stampedEnvelope(t *testing.T) *transport.SecureEnvelope {
+	t.Helper()
+	env := vectorEnvelope(t)
+	env.Stamp(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), time.Hour)
+	return env
+}
+
+func TestSigningPayloadVector(t *testing.T) {
+	payload := transport.SigningPayload(vectorEnvelope(t))
+
+	expected := "go-secure-messaging/envelope-signature/v1" +
+		"\x00\x00\x00\x07msg-123" +
+		"\x00\x00\x00\x16urn:sm:user:user-alice" +
+		"\x00\x00\x00\x14urn:sm:user:user-bob" +
+		"\x00\x00\x00\x00" +
+		"\x00\x00\x00\x00" +
+		"\x00\x00\x00\x0eencrypted-data" +
+		"\x00\x00\x00\x0dencrypted-key" +
+		"\x00\x00\x00\x07snippet"
+	assert.Equal(t, expected, string(payload))
+
+	// The payload ignores any existing signature.
+	signed := vectorEnvelope(t)
+	signed.Signature = []byte("old-signature")
+	assert.Equal(t, payload, transport.SigningPayload(signed))
+
+	// A suite is appended as a trailer: field number, then length-prefixed value.
+	withSuite := vectorEnvelope(t)
+	withSuite.Suite = transport.SuiteX25519AES256GCMEd25519
+	assert.Equal(t, expected+"\x00\x00\x03\xe8\x00\x00\x00\x02\x00\x01", string(transport.SigningPayload(withSuite)))
+
+	// Timestamps follow as Unix milliseconds in eight bytes, ignoring any
+	// precision the wire form does not carry.
+	withTimes := vectorEnvelope(t)
+	withTimes.Suite = transport.SuiteX25519AES256GCMEd25519
+	withTimes.SentAt = time.UnixMilli(0x0102030405).Add(999 * time.Microsecond)
+	withTimes.ExpiresAt = time.UnixMilli(0x0102030406)
+	assert.Equal(t, expected+"\x00\x00\x03\xe8\x00\x00\x00\x02\x00\x01"+
+		"\x00\x00\x03\xe9\x00\x00\x00\x08\x00\x00\x00\x01\x02\x03\x04\x05"+
+		"\x00\x00\x03\xea\x00\x00\x00\x08\x00\x00\x00\x01\x02\x03\x04\x06",
+		string(transport.SigningPayload(withTimes)))
+}
+
+func TestEd25519Vector(t *testing.T) {
+	seed := sha256.Sum256([]byte("go-secure-messaging test key"))
+	key := ed25519.NewKeyFromSeed(seed[:])
+	require.Equal(t, "34042c12c2bcdf1e7ea56430f88efa3b5316a5e2f573efa1d3257a643f24d6c6", hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+
+	env := vectorEnvelope(t)
+	require.NoError(t, transport.Sign(env, key))
+
+	// Ed25519 signatures are deterministic, so this value is fixed.
+	assert.Equal(t, "34dccde04e1b5a7e6d557a3f052fdec41f9a6d84a4483211982f761ddcee8107ca5c145bfcf3d8cacc84c8235fe874c4b9db8723849537abd47177f08ee23c01", hex.EncodeToString(env.Signature))
+	assert.NoError(t, transport.Verify(env, key.Public()))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		sign      func(env *transport.SecureEnvelope) error
+		publicKey any
+		otherKey  any
+	}{
+		{
+			name:      "Ed25519",
+			sign:      func(env *transport.SecureEnvelope) error { return transport.Sign(env, edPriv) },
+			publicKey: edPub,
+			otherKey:  mustEd25519Public(t),
+		},
+		{
+			name:      "ECDSA P-256",
+			sign:      func(env *transport.SecureEnvelope) error { return transport.Sign(env, ecPriv) },
+			publicKey: &ecPriv.PublicKey,
+			otherKey:  mustECDSAPublic(t),
+		},
+	}
+
+	tamper := map[string]func(env *transport.SecureEnvelope){
+		"MessageID": func(env *transport.SecureEnvelope) { env.MessageID = "msg-999" },
+		"SenderID": func(env *transport.SecureEnvelope) {
+			env.SenderID, _ = urn.Parse("urn:sm:user:mallory")
+		},
+		"RecipientID": func(env *transport.SecureEnvelope) {
+			env.RecipientID, _ = urn.Parse("urn:sm:user:mallory")
+		},
+		"GroupID": func(env *transport.SecureEnvelope) {
+			env.GroupID, _ = urn.Parse("urn:sm:group:g1")
+		},
+		"ConversationID": func(env *transport.SecureEnvelope) {
+			env.ConversationID, _ = urn.Parse("urn:sm:conversation:c1")
+		},
+		"EncryptedData":         func(env *transport.SecureEnvelope) { env.EncryptedData[0] ^= 1 },
+		"EncryptedSymmetricKey": func(env *transport.SecureEnvelope) { env.EncryptedSymmetricKey[0] ^= 1 },
+		"EncryptedSnippet":      func(env *transport.SecureEnvelope) { env.EncryptedSnippet = nil },
+		"Signature":             func(env *transport.SecureEnvelope) { env.Signature[len(env.Signature)-1] ^= 1 },
+		"Suite":                 func(env *transport.SecureEnvelope) { env.Suite = transport.SuiteP256AES256GCMECDSAP256 },
+		"SentAt":                func(env *transport.SecureEnvelope) { env.SentAt = env.SentAt.Add(time.Millisecond) },
+		"ExpiresAt":             func(env *transport.SecureEnvelope) { env.ExpiresAt = time.Time{} },
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := stampedEnvelope(t)
+			require.NoError(t, tc.sign(env))
+			require.NotEmpty(t, env.Signature)
+			require.NoError(t, transport.Verify(env, tc.publicKey))
+
+			t.Run("Survives proto round-trip", func(t *testing.T) {
+				roundTripped, err := transport.FromProto(transport.ToProto(env))
+				require.NoError(t, err)
+				assert.NoError(t, transport.Verify(roundTripped, tc.publicKey))
+			})
+
+			t.Run("Wrong key", func(t *testing.T) {
+				assert.ErrorIs(t, transport.Verify(env, tc.otherKey), transport.ErrInvalidSignature)
+			})
+
+			for field, modify := range tamper {
+				t.Run("Tampered "+field, func(t *testing.T) {
+					tampered := stampedEnvelope(t)
+					require.NoError(t, tc.sign(tampered))
+					modify(tampered)
+					assert.ErrorIs(t, transport.Verify(tampered, tc.publicKey), transport.ErrInvalidSignature)
+				})
+			}
+		})
+	}
+}
+
+func TestSignatureErrors(t *testing.T) {
+	t.Run("Unsigned envelope", func(t *testing.T) {
+		err := transport.Verify(vectorEnvelope(t), mustEd25519Public(t))
+		assert.ErrorIs(t, err, transport.ErrInvalidSignature)
+	})
+
+	t.Run("Unsupported curve", func(t *testing.T) {
+		p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		require.NoError(t, err)
+
+		env := vectorEnvelope(t)
+		assert.ErrorIs(t, transport.Sign(env, p384), transport.ErrUnsupportedKey)
+
+		env.Signature = []byte("sig")
+		assert.ErrorIs(t, transport.Verify(env, &p384.PublicKey), transport.ErrUnsupportedKey)
+	})
+
+	t.Run("Unsupported key type", func(t *testing.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		env := vectorEnvelope(t)
+		assert.ErrorIs(t, transport.Sign(env, rsaKey), transport.ErrUnsupportedKey)
+
+		x25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		env.Signature = []byte("sig")
+		assert.ErrorIs(t, transport.Verify(env, x25519.PublicKey()), transport.ErrUnsupportedKey)
+	})
+
+	t.Run("Nil envelope", func(t *testing.T) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		assert.Error(t, transport.Sign(nil, priv))
+		assert.Error(t, transport.Verify(nil, priv.Public()))
+	})
+
+	t.Run("Nil key", func(t *testing.T) {
+		env := vectorEnvelope(t)
+		assert.ErrorIs(t, transport.Sign(env, nil), transport.ErrUnsupportedKey)
+
+		env.Signature = []byte("sig")
+		assert.ErrorIs(t, transport.Verify(env, nil), transport.ErrUnsupportedKey)
+	})
+}
+
+func mustEd25519Public(t *testing.T) ed25519.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return pub
+}
+
+func mustECDSAPublic(t *testing.T) *ecdsa.PublicKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &key.PublicKey
+}
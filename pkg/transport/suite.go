@@ -0,0 +1,186 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrUnknownSuite is returned when an envelope names a cipher suite that
+	// is not in the registry.
+	ErrUnknownSuite = errors.New("unknown cipher suite")
+	// ErrDeprecatedSuite is returned when an envelope names a cipher suite
+	// that has been deprecated and must no longer be accepted.
+	ErrDeprecatedSuite = errors.New("deprecated cipher suite")
+)
+
+// SuiteID identifies the set of algorithms that produced an envelope's
+// EncryptedData, EncryptedSymmetricKey and Signature. IDs are assigned once
+// and never reused; a new combination of algorithms always gets a new ID.
+type SuiteID uint16
+
+// SuiteUnspecified is the zero SuiteID. It marks envelopes created before
+// suites were recorded, whose algorithms must be agreed out of band.
+const SuiteUnspecified SuiteID = 0
+
+// Built-in cipher suites. All use HKDF-SHA256 to derive key-encryption keys.
+const (
+	// SuiteX25519AES256GCMEd25519 wraps keys with X25519, encrypts with
+	// AES-256-GCM and signs with Ed25519.
+	SuiteX25519AES256GCMEd25519 SuiteID = 0x0001
+	// SuiteX25519XChaCha20Poly1305Ed25519 wraps keys with X25519, encrypts
+	// with XChaCha20-Poly1305 and signs with Ed25519.
+	SuiteX25519XChaCha20Poly1305Ed25519 SuiteID = 0x0002
+	// SuiteP256AES256GCMECDSAP256 wraps keys with ECDH on P-256, encrypts
+	// with AES-256-GCM and signs with ECDSA P-256 over SHA-256.
+	SuiteP256AES256GCMECDSAP256 SuiteID = 0x0003
+	// SuiteX3DHDoubleRatchetAES256GCMEd25519 is used by ratchet sessions:
+	// X3DH over X25519 for the initial agreement, the Double Ratchet for
+	// per-message keys, AES-256-GCM for encryption and Ed25519 signatures.
+	SuiteX3DHDoubleRatchetAES256GCMEd25519 SuiteID = 0x0004
+	// SuiteSenderKeyAES256GCMEd25519 is used by sender key group messages:
+	// a per-sender hash ratchet for message keys, AES-256-GCM for encryption
+	// and an Ed25519 signature by the sender's distributed signing key.
+	SuiteSenderKeyAES256GCMEd25519 SuiteID = 0x0005
+	// SuiteMLSX25519AES256GCMEd25519 is used by the experimental mls
+	// package: TreeKEM over X25519 for group key agreement, per-sender hash
+	// ratchets for message keys, AES-256-GCM and Ed25519 signatures.
+	SuiteMLSX25519AES256GCMEd25519 SuiteID = 0x0006
+)
+
+func (id SuiteID) String() string {
+	if s, err := DefaultSuites.Lookup(id); err == nil {
+		return s.Name
+	}
+	return fmt.Sprintf("Suite(0x%04x)", uint16(id))
+}
+
+// Suite describes the algorithms behind a SuiteID. The algorithm names are
+// the String() forms used by crypto/ecdh curves and by the seal package's
+// ciphers, so implementations can map them to concrete primitives.
+type Suite struct {
+	ID           SuiteID
+	Name         string
+	KeyAgreement string // e.g. "X25519", "P-256"
+	KDF          string // e.g. "HKDF-SHA256"
+	AEAD         string // e.g. "AES-256-GCM", "XChaCha20-Poly1305"
+	Signature    string // e.g. "Ed25519", "ECDSA-P256-SHA256"
+	Deprecated   bool
+}
+
+// SuiteRegistry maps suite IDs to their algorithms. It is safe for
+// concurrent use.
+type SuiteRegistry struct {
+	mu     sync.RWMutex
+	suites map[SuiteID]Suite
+}
+
+// NewSuiteRegistry creates an empty SuiteRegistry.
+func NewSuiteRegistry() *SuiteRegistry {
+	return &SuiteRegistry{suites: make(map[SuiteID]Suite)}
+}
+
+// DefaultSuites holds the built-in suites. Applications may register their
+// own suites in it or deprecate built-in ones at start-up.
+var DefaultSuites = newDefaultSuites()
+
+func newDefaultSuites() *SuiteRegistry {
+	r := NewSuiteRegistry()
+	r.MustRegister(Suite{
+		ID: SuiteX25519AES256GCMEd25519, Name: "SM1_X25519_HKDF-SHA256_AES-256-GCM_Ed25519",
+		KeyAgreement: "X25519", KDF: "HKDF-SHA256", AEAD: "AES-256-GCM", Signature: "Ed25519",
+	})
+	r.MustRegister(Suite{
+		ID: SuiteX25519XChaCha20Poly1305Ed25519, Name: "SM1_X25519_HKDF-SHA256_XChaCha20-Poly1305_Ed25519",
+		KeyAgreement: "X25519", KDF: "HKDF-SHA256", AEAD: "XChaCha20-Poly1305", Signature: "Ed25519",
+	})
+	r.MustRegister(Suite{
+		ID: SuiteP256AES256GCMECDSAP256, Name: "SM1_P-256_HKDF-SHA256_AES-256-GCM_ECDSA-P256-SHA256",
+		KeyAgreement: "P-256", KDF: "HKDF-SHA256", AEAD: "AES-256-GCM", Signature: "ECDSA-P256-SHA256",
+	})
+	r.MustRegister(Suite{
+		ID: SuiteX3DHDoubleRatchetAES256GCMEd25519, Name: "SM1_X3DH-X25519_DoubleRatchet_HKDF-SHA256_AES-256-GCM_Ed25519",
+		KeyAgreement: "X3DH-X25519", KDF: "HKDF-SHA256", AEAD: "AES-256-GCM", Signature: "Ed25519",
+	})
+	r.MustRegister(Suite{
+		ID: SuiteSenderKeyAES256GCMEd25519, Name: "SM1_SenderKey_HKDF-SHA256_AES-256-GCM_Ed25519",
+		KeyAgreement: "SenderKey", KDF: "HKDF-SHA256", AEAD: "AES-256-GCM", Signature: "Ed25519",
+	})
+	r.MustRegister(Suite{
+		ID: SuiteMLSX25519AES256GCMEd25519, Name: "SM1_MLS-TreeKEM-X25519_HKDF-SHA256_AES-256-GCM_Ed25519",
+		KeyAgreement: "MLS-TreeKEM-X25519", KDF: "HKDF-SHA256", AEAD: "AES-256-GCM", Signature: "Ed25519",
+	})
+	return r
+}
+
+// Register adds a suite. Its ID must be non-zero and not already registered.
+func (r *SuiteRegistry) Register(s Suite) error {
+	if s.ID == SuiteUnspecified {
+		return errors.New("suite ID must not be zero")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.suites[s.ID]; exists {
+		return fmt.Errorf("suite 0x%04x is already registered", uint16(s.ID))
+	}
+	r.suites[s.ID] = s
+	return nil
+}
+
+// MustRegister is like Register but panics on error.
+func (r *SuiteRegistry) MustRegister(s Suite) {
+	if err := r.Register(s); err != nil {
+		panic(err)
+	}
+}
+
+// Deprecate marks a registered suite as deprecated so that Accept rejects it.
+func (r *SuiteRegistry) Deprecate(id SuiteID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.suites[id]
+	if !ok {
+		return fmt.Errorf("%w: 0x%04x", ErrUnknownSuite, uint16(id))
+	}
+	s.Deprecated = true
+	r.suites[id] = s
+	return nil
+}
+
+// Lookup returns the suite registered under id, deprecated or not.
+func (r *SuiteRegistry) Lookup(id SuiteID) (Suite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.suites[id]
+	if !ok {
+		return Suite{}, fmt.Errorf("%w: 0x%04x", ErrUnknownSuite, uint16(id))
+	}
+	return s, nil
+}
+
+// Accept returns the suite registered under id if it may be used to process
+// an envelope: it must be registered and not deprecated.
+func (r *SuiteRegistry) Accept(id SuiteID) (Suite, error) {
+	s, err := r.Lookup(id)
+	if err != nil {
+		return Suite{}, err
+	}
+	if s.Deprecated {
+		return Suite{}, fmt.Errorf("%w: %s", ErrDeprecatedSuite, s.Name)
+	}
+	return s, nil
+}
+
+// Suites returns every registered suite ordered by ID.
+func (r *SuiteRegistry) Suites() []Suite {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	suites := make([]Suite, 0, len(r.suites))
+	for _, s := range r.suites {
+		suites = append(suites, s)
+	}
+	slices.SortFunc(suites, func(a, b Suite) int { return int(a.ID) - int(b.ID) })
+	return suites
+}
@@ -0,0 +1,65 @@
+package transport_test
+
+import (
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultSuites(t *testing.T) {
+	suites := transport.DefaultSuites.Suites()
+	require.Len(t, suites, 6)
+	for i, s := range suites {
+		assert.Equal(t, transport.SuiteID(i+1), s.ID, "suites must be ordered by ID")
+		assert.False(t, s.Deprecated)
+		assert.Equal(t, "HKDF-SHA256", s.KDF)
+	}
+
+	assert.Equal(t, "SM1_X25519_HKDF-SHA256_AES-256-GCM_Ed25519", transport.SuiteX25519AES256GCMEd25519.String())
+	assert.Equal(t, "Suite(0x1234)", transport.SuiteID(0x1234).String())
+}
+
+func TestSuiteRegistry(t *testing.T) {
+	custom := transport.Suite{ID: 0x8001, Name: "CUSTOM", KeyAgreement: "X25519", KDF: "HKDF-SHA256", AEAD: "AES-256-GCM", Signature: "Ed25519"}
+
+	r := transport.NewSuiteRegistry()
+	require.NoError(t, r.Register(custom))
+
+	t.Run("Lookup And Accept", func(t *testing.T) {
+		s, err := r.Lookup(custom.ID)
+		require.NoError(t, err)
+		assert.Equal(t, custom, s)
+
+		s, err = r.Accept(custom.ID)
+		require.NoError(t, err)
+		assert.Equal(t, custom, s)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := r.Lookup(transport.SuiteX25519AES256GCMEd25519)
+		assert.ErrorIs(t, err, transport.ErrUnknownSuite)
+		_, err = r.Accept(transport.SuiteUnspecified)
+		assert.ErrorIs(t, err, transport.ErrUnknownSuite)
+		assert.ErrorIs(t, r.Deprecate(0x9999), transport.ErrUnknownSuite)
+	})
+
+	t.Run("Invalid Registration", func(t *testing.T) {
+		assert.Error(t, r.Register(custom), "duplicate ID")
+		assert.Error(t, r.Register(transport.Suite{Name: "zero"}), "zero ID")
+		assert.Panics(t, func() { r.MustRegister(custom) })
+	})
+
+	t.Run("Deprecation", func(t *testing.T) {
+		require.NoError(t, r.Deprecate(custom.ID))
+
+		_, err := r.Accept(custom.ID)
+		assert.ErrorIs(t, err, transport.ErrDeprecatedSuite)
+
+		// Deprecated suites can still be looked up, e.g. for diagnostics.
+		s, err := r.Lookup(custom.ID)
+		require.NoError(t, err)
+		assert.True(t, s.Deprecated)
+	})
+}
@@ -0,0 +1,182 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	// ErrMissingField is wrapped by a FieldError when a required field is empty.
+	ErrMissingField = errors.New("required field is missing")
+	// ErrConflictingFields is wrapped by a FieldError when two fields that are
+	// mutually exclusive are both set.
+	ErrConflictingFields = errors.New("conflicting fields")
+	// ErrInvalidField is wrapped by a FieldError when a field is present but
+	// malformed.
+	ErrInvalidField = errors.New("invalid field")
+)
+
+// FieldError describes a single envelope field that failed validation.
+// Field is the name of the SecureEnvelope struct field.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists every field of an envelope that failed validation.
+// It supports errors.Is and errors.As against the individual FieldErrors and
+// the sentinel errors they wrap.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return "invalid envelope: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, f := range e.Fields {
+		errs[i] = f
+	}
+	return errs
+}
+
+// Validate checks the envelope against the addressing and content rules:
+//
+//   - MessageID and SenderID are required.
+//   - Exactly one of RecipientID and GroupID must be set.
+//   - ConversationID is required for group messages.
+//   - EncryptedData, EncryptedSymmetricKey and Signature must be non-empty.
+//   - SentAt and ExpiresAt, if set, must be at least 1ms after the Unix
+//     epoch, and ExpiresAt must be after SentAt.
+//
+// It returns nil or a *ValidationError listing every violation.
+func (e *SecureEnvelope) Validate() error {
+	if e == nil {
+		return &ValidationError{Fields: []*FieldError{{Field: "SecureEnvelope", Err: ErrMissingField}}}
+	}
+	return e.validate(nil)
+}
+
+// ValidateProto validates a Protobuf envelope. URN fields that fail to parse
+// are reported as ErrInvalidField alongside any rule violations, rather than
+// stopping at the first error as FromProto does.
+func ValidateProto(proto *SecureEnvelopePb) error {
+	if proto == nil {
+		return &ValidationError{Fields: []*FieldError{{Field: "SecureEnvelope", Err: ErrMissingField}}}
+	}
+
+	var invalid []*FieldError
+	parse := func(field, value string) urn.URN {
+		u, err := urn.Parse(value)
+		if err != nil {
+			invalid = append(invalid, &FieldError{Field: field, Err: fmt.Errorf("%w: %w", ErrInvalidField, err)})
+		}
+		return u
+	}
+
+	parseTime := func(field string, num protowire.Number) time.Time {
+		t, err := extTime(proto.ProtoReflect(), num)
+		if err != nil {
+			invalid = append(invalid, &FieldError{Field: field, Err: fmt.Errorf("%w: %w", ErrInvalidField, err)})
+		}
+		return t
+	}
+
+	native := &SecureEnvelope{
+		MessageID:             proto.MessageId,
+		SenderID:              parse("SenderID", proto.SenderId),
+		RecipientID:           parse("RecipientID", proto.RecipientId),
+		GroupID:               parse("GroupID", proto.GroupId),
+		ConversationID:        parse("ConversationID", proto.ConversationId),
+		EncryptedData:         proto.EncryptedData,
+		EncryptedSymmetricKey: proto.EncryptedSymmetricKey,
+		Signature:             proto.Signature,
+		SentAt:                parseTime("SentAt", extSentAt),
+		ExpiresAt:             parseTime("ExpiresAt", extExpiresAt),
+	}
+	return native.validate(invalid)
+}
+
+// validate applies the envelope rules, skipping checks on fields that
+// already have an entry in invalid.
+func (e *SecureEnvelope) validate(invalid []*FieldError) error {
+	errs := invalid
+	failed := func(field string) bool {
+		for _, f := range invalid {
+			if f.Field == field {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(field string, err error) {
+		if !failed(field) {
+			errs = append(errs, &FieldError{Field: field, Err: err})
+		}
+	}
+
+	if e.MessageID == "" {
+		add("MessageID", ErrMissingField)
+	}
+	if e.SenderID.IsZero() {
+		add("SenderID", ErrMissingField)
+	}
+
+	hasRecipient, hasGroup := !e.RecipientID.IsZero(), !e.GroupID.IsZero()
+	switch {
+	case !hasRecipient && !hasGroup && !failed("RecipientID") && !failed("GroupID"):
+		add("RecipientID", fmt.Errorf("%w: one of RecipientID or GroupID is required", ErrMissingField))
+	case hasRecipient && hasGroup:
+		add("GroupID", fmt.Errorf("%w: RecipientID and GroupID are mutually exclusive", ErrConflictingFields))
+	}
+	if hasGroup && e.ConversationID.IsZero() {
+		add("ConversationID", fmt.Errorf("%w: required for group messages", ErrMissingField))
+	}
+
+	if len(e.EncryptedData) == 0 {
+		add("EncryptedData", ErrMissingField)
+	}
+	if len(e.EncryptedSymmetricKey) == 0 {
+		add("EncryptedSymmetricKey", ErrMissingField)
+	}
+	if len(e.Signature) == 0 {
+		add("Signature", ErrMissingField)
+	}
+
+	// Times are carried as positive Unix milliseconds, so earlier ones
+	// would be read back as unset.
+	beforeEpoch := func(t time.Time) bool { return !t.IsZero() && t.UnixMilli() < 1 }
+	if beforeEpoch(e.SentAt) {
+		add("SentAt", fmt.Errorf("%w: must be at least 1ms after the Unix epoch", ErrInvalidField))
+	}
+	switch {
+	case beforeEpoch(e.ExpiresAt):
+		add("ExpiresAt", fmt.Errorf("%w: must be at least 1ms after the Unix epoch", ErrInvalidField))
+	case !e.SentAt.IsZero() && !e.ExpiresAt.IsZero() && !e.ExpiresAt.After(e.SentAt):
+		add("ExpiresAt", fmt.Errorf("%w: must be after SentAt", ErrInvalidField))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: errs}
+}
@@ -0,0 +1,240 @@
+package transport_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/transport"
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestEnvelopeValidate(t *testing.T) {
+	senderURN, _ := urn.Parse("urn:sm:user:user-alice")
+	recipientURN, _ := urn.Parse("urn:sm:user:user-bob")
+	groupURN, _ := urn.Parse("urn:sm:group:group-1")
+	conversationURN, _ := urn.Parse("urn:sm:conversation:convo-123")
+
+	validDirect := func() *transport.SecureEnvelope {
+		return &transport.SecureEnvelope{
+			MessageID:             "msg-123",
+			SenderID:              senderURN,
+			RecipientID:           recipientURN,
+			EncryptedData:         []byte("encrypted-data"),
+			EncryptedSymmetricKey: []byte("encrypted-key"),
+			Signature:             []byte("signature-data"),
+		}
+	}
+	validGroup := func() *transport.SecureEnvelope {
+		env := validDirect()
+		env.RecipientID = urn.URN{}
+		env.GroupID = groupURN
+		env.ConversationID = conversationURN
+		return env
+	}
+
+	testCases := []struct {
+		name           string
+		envelope       *transport.SecureEnvelope
+		expectedFields map[string]error
+	}{
+		{name: "Valid direct message", envelope: validDirect()},
+		{name: "Valid group message", envelope: validGroup()},
+		{
+			name:           "Nil envelope",
+			envelope:       nil,
+			expectedFields: map[string]error{"SecureEnvelope": transport.ErrMissingField},
+		},
+		{
+			name: "No recipient or group",
+			envelope: func() *transport.SecureEnvelope {
+				env := validDirect()
+				env.RecipientID = urn.URN{}
+				return env
+			}(),
+			expectedFields: map[string]error{"RecipientID": transport.ErrMissingField},
+		},
+		{
+			name: "Both recipient and group",
+			envelope: func() *transport.SecureEnvelope {
+				env := validGroup()
+				env.RecipientID = recipientURN
+				return env
+			}(),
+			expectedFields: map[string]error{"GroupID": transport.ErrConflictingFields},
+		},
+		{
+			name: "Group without conversation",
+			envelope: func() *transport.SecureEnvelope {
+				env := validGroup()
+				env.ConversationID = urn.URN{}
+				return env
+			}(),
+			expectedFields: map[string]error{"ConversationID": transport.ErrMissingField},
+		},
+		{
+			name: "Expires before sent",
+			envelope: func() *transport.SecureEnvelope {
+				env := validDirect()
+				env.SentAt = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+				env.ExpiresAt = env.SentAt
+				return env
+			}(),
+			expectedFields: map[string]error{"ExpiresAt": transport.ErrInvalidField},
+		},
+		{
+			name: "Expiry without send time",
+			envelope: func() *transport.SecureEnvelope {
+				env := validDirect()
+				env.ExpiresAt = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+				return env
+			}(),
+		},
+		{
+			name:     "Everything missing",
+			envelope: &transport.SecureEnvelope{},
+			expectedFields: map[string]error{
+				"MessageID":             transport.ErrMissingField,
+				"SenderID":              transport.ErrMissingField,
+				"RecipientID":           transport.ErrMissingField,
+				"EncryptedData":         transport.ErrMissingField,
+				"EncryptedSymmetricKey": transport.ErrMissingField,
+				"Signature":             transport.ErrMissingField,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.envelope.Validate()
+			assertFieldErrors(t, err, tc.expectedFields)
+
+			// The proto path must agree with the native path.
+			if tc.envelope != nil {
+				assertFieldErrors(t, transport.ValidateProto(transport.ToProto(tc.envelope)), tc.expectedFields)
+			}
+		})
+	}
+}
+
+func TestEnvelopeValidateTimes(t *testing.T) {
+	senderURN, _ := urn.Parse("urn:sm:user:user-alice")
+	recipientURN, _ := urn.Parse("urn:sm:user:user-bob")
+	epoch := time.Unix(0, 0)
+
+	// Times this early cannot be encoded, so unlike the other rules
+	// these are only checked on the native form.
+	testCases := []struct {
+		name           string
+		sentAt         time.Time
+		expiresAt      time.Time
+		expectedFields map[string]error
+	}{
+		{name: "Just after epoch", sentAt: epoch.Add(time.Millisecond), expiresAt: epoch.Add(2 * time.Millisecond)},
+		{name: "Expires at epoch", expiresAt: epoch, expectedFields: map[string]error{"ExpiresAt": transport.ErrInvalidField}},
+		{name: "Expires before epoch", expiresAt: epoch.Add(-time.Hour), expectedFields: map[string]error{"ExpiresAt": transport.ErrInvalidField}},
+		{name: "Expires within 1ms of epoch", expiresAt: epoch.Add(time.Microsecond), expectedFields: map[string]error{"ExpiresAt": transport.ErrInvalidField}},
+		{
+			name:           "Sent before epoch",
+			sentAt:         epoch.Add(-time.Hour),
+			expiresAt:      epoch.Add(time.Hour),
+			expectedFields: map[string]error{"SentAt": transport.ErrInvalidField},
+		},
+		{
+			name:      "Both before epoch",
+			sentAt:    epoch.Add(-2 * time.Hour),
+			expiresAt: epoch.Add(-time.Hour),
+			expectedFields: map[string]error{
+				"SentAt":    transport.ErrInvalidField,
+				"ExpiresAt": transport.ErrInvalidField,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := &transport.SecureEnvelope{
+				MessageID:             "msg-123",
+				SenderID:              senderURN,
+				RecipientID:           recipientURN,
+				EncryptedData:         []byte("encrypted-data"),
+				EncryptedSymmetricKey: []byte("encrypted-key"),
+				Signature:             []byte("signature-data"),
+				SentAt:                tc.sentAt,
+				ExpiresAt:             tc.expiresAt,
+			}
+			assertFieldErrors(t, env.Validate(), tc.expectedFields)
+		})
+	}
+}
+
+func TestValidateProto(t *testing.T) {
+	t.Run("Reports unparseable URNs with other violations", func(t *testing.T) {
+		err := transport.ValidateProto(&transport.SecureEnvelopePb{
+			MessageId:   "msg-1",
+			SenderId:    "not-a-valid-urn",
+			RecipientId: "also-not-a-urn",
+		})
+		assertFieldErrors(t, err, map[string]error{
+			"SenderID":              transport.ErrInvalidField,
+			"RecipientID":           transport.ErrInvalidField,
+			"EncryptedData":         transport.ErrMissingField,
+			"EncryptedSymmetricKey": transport.ErrMissingField,
+			"Signature":             transport.ErrMissingField,
+		})
+		assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+	})
+
+	t.Run("Reports malformed timestamps", func(t *testing.T) {
+		pb := &transport.SecureEnvelopePb{
+			MessageId:             "msg-1",
+			SenderId:              "urn:sm:user:alice",
+			RecipientId:           "urn:sm:user:bob",
+			EncryptedData:         []byte("encrypted-data"),
+			EncryptedSymmetricKey: []byte("encrypted-key"),
+			Signature:             []byte("signature-data"),
+		}
+		unknown := protowire.AppendTag(nil, 1001, protowire.BytesType)
+		unknown = protowire.AppendBytes(unknown, []byte{1})
+		unknown = protowire.AppendTag(unknown, 1002, protowire.VarintType)
+		pb.ProtoReflect().SetUnknown(protowire.AppendVarint(unknown, 1<<63))
+		assertFieldErrors(t, transport.ValidateProto(pb), map[string]error{
+			"SentAt":    transport.ErrInvalidField,
+			"ExpiresAt": transport.ErrInvalidField,
+		})
+	})
+
+	t.Run("Nil proto", func(t *testing.T) {
+		assert.ErrorIs(t, transport.ValidateProto(nil), transport.ErrMissingField)
+	})
+}
+
+func assertFieldErrors(t *testing.T, err error, expected map[string]error) {
+	t.Helper()
+	if len(expected) == 0 {
+		assert.NoError(t, err)
+		return
+	}
+	require.Error(t, err)
+
+	var validationErr *transport.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+
+	actual := make(map[string]error, len(validationErr.Fields))
+	for _, f := range validationErr.Fields {
+		actual[f.Field] = f.Err
+	}
+	require.Len(t, actual, len(expected), "unexpected fields in %v", err)
+	for field, sentinel := range expected {
+		require.Contains(t, actual, field)
+		assert.ErrorIs(t, actual[field], sentinel, "field %s", field)
+		assert.ErrorIs(t, err, sentinel)
+		assert.Contains(t, err.Error(), field)
+	}
+
+	var fieldErr *transport.FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+}
@@ -0,0 +1,176 @@
+package urn
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+var _ encoding.BinaryAppender = URN{}
+
+// Compact binary encoding
+//
+// A URN is encoded as:
+//
+//	namespace   1 byte code, followed by a length-prefixed string for binaryLiteral
+//	segments    uvarint count of segments, root first
+//	  type      1 byte code, followed by a length-prefixed string for binaryLiteral
+//	  id        length-prefixed string
+//
+// Length prefixes are uvarints. The zero-value URN is encoded as the single
+// byte binaryZero. The code tables below are part of the wire format: codes
+// may be added but existing values must never change.
+const (
+	binaryZero    byte = 0x00
+	binaryLiteral byte = 0x01
+)
+
+var namespaceCodes = []string{
+	0x02: SecureMessaging,
+}
+
+var entityTypeCodes = []string{
+	0x02: EntityTypeUser,
+	0x03: EntityTypeGroup,
+	0x04: EntityTypeDevice,
+	0x05: EntityTypeConversation,
+	0x06: EntityTypeMessage,
+	0x07: EntityTypeKey,
+	0x08: EntityTypeBlob,
+}
+
+// AppendBinary implements the encoding.BinaryAppender interface, appending
+// the compact binary encoding of u to b. Well-known namespaces and entity
+// types are stored as single-byte codes, so a typical URN is several bytes
+// shorter than its string form.
+func (u URN) AppendBinary(b []byte) ([]byte, error) {
+	if u.IsZero() {
+		return append(b, binaryZero), nil
+	}
+	b = appendCoded(b, u.namespace, namespaceCodes)
+	b = binary.AppendUvarint(b, uint64(u.Depth()))
+	for _, a := range u.Ancestors() {
+		b = appendCoded(b, a.entityType, entityTypeCodes)
+		b = appendString(b, a.entityID)
+	}
+	b = appendCoded(b, u.entityType, entityTypeCodes)
+	b = appendString(b, u.entityID)
+	return b, nil
+}
+
+// DecodeBinary decodes a URN written by AppendBinary from the start of data.
+// It returns the URN and the number of bytes consumed, so that several URNs
+// can be read from one buffer.
+func DecodeBinary(data []byte) (URN, int, error) {
+	d := binaryDecoder{data: data}
+
+	code, ok := d.byte()
+	if !ok {
+		return URN{}, 0, fmt.Errorf("%w: empty binary URN", ErrInvalidFormat)
+	}
+	if code == binaryZero {
+		return URN{}, d.pos, nil
+	}
+	namespace, err := d.coded(code, namespaceCodes)
+	if err != nil {
+		return URN{}, 0, err
+	}
+
+	count, ok := d.uvarint()
+	// Each segment takes at least two bytes, which bounds a sane count.
+	if !ok || count == 0 || count > uint64(len(data)) {
+		return URN{}, 0, fmt.Errorf("%w: invalid binary URN segment count", ErrInvalidFormat)
+	}
+
+	var parent strings.Builder
+	var u URN
+	for i := uint64(0); i < count; i++ {
+		if i > 0 {
+			if parent.Len() > 0 {
+				parent.WriteString(segmentDelimiter)
+			}
+			parent.WriteString(u.path())
+		}
+		code, ok := d.byte()
+		if !ok {
+			return URN{}, 0, fmt.Errorf("%w: truncated binary URN", ErrInvalidFormat)
+		}
+		entityType, err := d.coded(code, entityTypeCodes)
+		if err != nil {
+			return URN{}, 0, err
+		}
+		entityID, ok := d.string()
+		if !ok {
+			return URN{}, 0, fmt.Errorf("%w: truncated binary URN", ErrInvalidFormat)
+		}
+		if u, err = New(namespace, entityType, entityID); err != nil {
+			return URN{}, 0, err
+		}
+	}
+	u.parent = parent.String()
+	return u, d.pos, nil
+}
+
+func appendCoded(b []byte, s string, codes []string) []byte {
+	for code, v := range codes {
+		if v != "" && v == s {
+			return append(b, byte(code))
+		}
+	}
+	return appendString(append(b, binaryLiteral), s)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+type binaryDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *binaryDecoder) byte() (byte, bool) {
+	if d.pos >= len(d.data) {
+		return 0, false
+	}
+	c := d.data[d.pos]
+	d.pos++
+	return c, true
+}
+
+func (d *binaryDecoder) uvarint() (uint64, bool) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, false
+	}
+	d.pos += n
+	return v, true
+}
+
+func (d *binaryDecoder) string() (string, bool) {
+	n, ok := d.uvarint()
+	if !ok || n > uint64(len(d.data)-d.pos) {
+		return "", false
+	}
+	s := string(d.data[d.pos : d.pos+int(n)])
+	d.pos += int(n)
+	return s, true
+}
+
+// coded resolves a namespace or entity type from its code, reading the
+// literal string when the code is binaryLiteral.
+func (d *binaryDecoder) coded(code byte, codes []string) (string, error) {
+	if code == binaryLiteral {
+		s, ok := d.string()
+		if !ok {
+			return "", fmt.Errorf("%w: truncated binary URN", ErrInvalidFormat)
+		}
+		return s, nil
+	}
+	if int(code) >= len(codes) || codes[code] == "" {
+		return "", fmt.Errorf("%w: unknown binary URN code 0x%02x", ErrInvalidFormat, code)
+	}
+	return codes[code], nil
+}
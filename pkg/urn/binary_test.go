@@ -0,0 +1,186 @@
+package urn_test
+
+import (
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryEncoding(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []byte
+	}{
+		{
+			name:     "Well-known namespace and type",
+			input:    "urn:sm:user:alice",
+			expected: []byte{0x02, 0x01, 0x02, 0x05, 'a', 'l', 'i', 'c', 'e'},
+		},
+		{
+			name:     "Literal namespace and type",
+			input:    "urn:ex:thing:x",
+			expected: []byte{0x01, 0x02, 'e', 'x', 0x01, 0x01, 0x05, 't', 'h', 'i', 'n', 'g', 0x01, 'x'},
+		},
+		{
+			name:     "Hierarchical",
+			input:    "urn:sm:user:a/device:b",
+			expected: []byte{0x02, 0x02, 0x02, 0x01, 'a', 0x04, 0x01, 'b'},
+		},
+		{
+			name:     "Reserved characters are stored unescaped",
+			input:    "urn:sm:user:a%3Ab",
+			expected: []byte{0x02, 0x01, 0x02, 0x03, 'a', ':', 'b'},
+		},
+		{
+			name:     "Blob type",
+			input:    "urn:sm:blob:b1",
+			expected: []byte{0x02, 0x01, 0x08, 0x02, 'b', '1'},
+		},
+		{
+			name:     "Zero-value",
+			input:    "",
+			expected: []byte{0x00},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := mustParse(t, tc.input)
+
+			encoded, err := u.AppendBinary(nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, encoded)
+
+			decoded, n, err := urn.DecodeBinary(encoded)
+			require.NoError(t, err)
+			assert.Equal(t, len(encoded), n)
+			assert.Equal(t, u, decoded)
+		})
+	}
+}
+
+func TestBinaryEncodingIsCompact(t *testing.T) {
+	u := mustParse(t, "urn:sm:conversation:0f8fad5b-d9cb-469f-a165-70867728950e")
+	encoded, err := u.MarshalBinary()
+	require.NoError(t, err)
+	// namespace code, segment count, type code and ID length, plus the ID.
+	assert.Len(t, encoded, 4+len(u.EntityID()))
+	assert.Less(t, len(encoded), len(u.String()))
+}
+
+func TestDecodeBinaryStream(t *testing.T) {
+	urns := []urn.URN{
+		mustParse(t, "urn:sm:user:alice"),
+		{},
+		mustParse(t, "urn:sm:group:g1"),
+		mustParse(t, "urn:other:user:bob/device:phone1"),
+	}
+
+	var buf []byte
+	for _, u := range urns {
+		var err error
+		buf, err = u.AppendBinary(buf)
+		require.NoError(t, err)
+	}
+
+	var decoded []urn.URN
+	for len(buf) > 0 {
+		u, n, err := urn.DecodeBinary(buf)
+		require.NoError(t, err)
+		decoded = append(decoded, u)
+		buf = buf[n:]
+	}
+	assert.Equal(t, urns, decoded)
+}
+
+func TestDecodeBinaryErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: nil},
+		{name: "Unknown namespace code", input: []byte{0x7f, 0x01, 0x02, 0x01, 'a'}},
+		{name: "Unknown type code", input: []byte{0x02, 0x01, 0x7f, 0x01, 'a'}},
+		{name: "Zero segments", input: []byte{0x02, 0x00}},
+		{name: "Huge segment count", input: []byte{0x02, 0xff, 0xff, 0x03}},
+		{name: "Truncated ID", input: []byte{0x02, 0x01, 0x02, 0x05, 'a'}},
+		{name: "Truncated literal namespace", input: []byte{0x01, 0x05, 's'}},
+		{name: "Missing segment", input: []byte{0x02, 0x02, 0x02, 0x01, 'a'}},
+		{name: "Empty ID", input: []byte{0x02, 0x01, 0x02, 0x00}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := urn.DecodeBinary(tc.input)
+			assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+		})
+	}
+
+	t.Run("UnmarshalBinary rejects trailing bytes", func(t *testing.T) {
+		var u urn.URN
+		err := u.UnmarshalBinary([]byte{0x02, 0x01, 0x02, 0x01, 'a', 0x00})
+		assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+	})
+}
+
+// FuzzDecodeBinary checks that DecodeBinary never panics and that anything it
+// accepts re-encodes to the same bytes.
+func FuzzDecodeBinary(f *testing.F) {
+	f.Add([]byte{0x02, 0x01, 0x02, 0x05, 'a', 'l', 'i', 'c', 'e'})
+	f.Add([]byte{0x02, 0x02, 0x02, 0x01, 'a', 0x04, 0x01, 'b'})
+	f.Add([]byte{0x01, 0x02, 'e', 'x', 0x01, 0x01, 0x01, 't', 0x01, 'x'})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		u, n, err := urn.DecodeBinary(data)
+		if err != nil {
+			return
+		}
+		encoded, err := u.AppendBinary(nil)
+		require.NoError(t, err)
+
+		// A literal that matches a well-known value re-encodes as its code,
+		// so compare the decoded values rather than the raw bytes.
+		redecoded, m, err := urn.DecodeBinary(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, len(encoded), m)
+		assert.LessOrEqual(t, m, n)
+		assert.Equal(t, u, redecoded)
+	})
+}
+
+var benchmarkURN = "urn:sm:conversation:0f8fad5b-d9cb-469f-a165-70867728950e"
+
+func BenchmarkString(b *testing.B) {
+	u := mustParse(b, benchmarkURN)
+	b.ReportAllocs()
+	b.ReportMetric(float64(len(u.String())), "wire-bytes")
+	for i := 0; i < b.N; i++ {
+		_ = u.String()
+	}
+}
+
+func BenchmarkAppendBinary(b *testing.B) {
+	u := mustParse(b, benchmarkURN)
+	buf := make([]byte, 0, 64)
+	encoded, _ := u.AppendBinary(nil)
+	b.ReportAllocs()
+	b.ReportMetric(float64(len(encoded)), "wire-bytes")
+	for i := 0; i < b.N; i++ {
+		buf, _ = u.AppendBinary(buf[:0])
+	}
+}
+
+func BenchmarkDecodeBinary(b *testing.B) {
+	encoded, err := mustParse(b, benchmarkURN).AppendBinary(nil)
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := urn.DecodeBinary(encoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package urn
+
+import (
+	"cmp"
+	"encoding/binary"
+	"hash/fnv"
+	"strings"
+)
+
+// Equal reports whether u and other identify the same resource. New and
+// Parse lower-case the namespace and entity types, following RFC 8141's
+// treatment of the NID, so this is the same as u == other; entity IDs are
+// case-sensitive.
+func (u URN) Equal(other URN) bool {
+	return u == other
+}
+
+// Compare returns -1, 0 or +1 depending on whether u sorts before, equal to
+// or after other. The order is total and consistent with Equal, so it can be
+// used with slices.SortFunc and slices.BinarySearchFunc. The zero-value URN
+// sorts first; otherwise URNs are ordered by namespace, then segment by
+// segment from the root, with a parent sorting before its descendants.
+func (u URN) Compare(other URN) int {
+	if c := strings.Compare(u.scheme, other.scheme); c != 0 {
+		return c
+	}
+	if c := strings.Compare(u.namespace, other.namespace); c != 0 {
+		return c
+	}
+
+	var bufA, bufB [4]segment
+	a, b := u.segments(bufA[:0]), other.segments(bufB[:0])
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i].entityType, b[i].entityType); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a[i].entityID, b[i].entityID); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// Hash returns a stable 64-bit FNV-1a hash of the URN that is consistent with
+// Equal: equal URNs always hash to the same value. The hash is independent of
+// process and platform, so it is suitable for sharding and for persisted
+// indexes. The algorithm is part of the API and will not change.
+func (u URN) Hash() uint64 {
+	h := fnv.New64a()
+	var buf []byte
+	buf = appendField(buf, u.scheme)
+	buf = appendField(buf, u.namespace)
+
+	var segBuf [4]segment
+	for _, s := range u.segments(segBuf[:0]) {
+		buf = appendField(buf, s.entityType)
+		buf = appendField(buf, s.entityID)
+	}
+	_, _ = h.Write(buf)
+	return h.Sum64()
+}
+
+type segment struct {
+	entityType string
+	entityID   string
+}
+
+// segments appends the unescaped segments of u, root first, to buf.
+func (u URN) segments(buf []segment) []segment {
+	if u.IsZero() {
+		return buf
+	}
+	rest := u.parent
+	for rest != "" {
+		var seg string
+		seg, rest, _ = strings.Cut(rest, segmentDelimiter)
+		entityType, entityID, _ := strings.Cut(seg, urnDelimiter)
+		// The parent path is stored in canonical form, so it always decodes.
+		entityType, _ = unescape(entityType)
+		entityID, _ = unescape(entityID)
+		buf = append(buf, segment{entityType: entityType, entityID: entityID})
+	}
+	return append(buf, segment{entityType: u.entityType, entityID: u.entityID})
+}
+
+// appendField appends a length-prefixed copy of s.
+func appendField(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
@@ -0,0 +1,143 @@
+package urn_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessors(t *testing.T) {
+	u := mustParse(t, "urn:sm:user:alice/device:phone1")
+	assert.Equal(t, "urn", u.Scheme())
+	assert.Equal(t, "sm", u.Namespace())
+	assert.Equal(t, "device", u.EntityType())
+	assert.Equal(t, "phone1", u.EntityID())
+
+	var zero urn.URN
+	assert.Empty(t, zero.Scheme())
+	assert.Empty(t, zero.Namespace())
+}
+
+func TestEqual(t *testing.T) {
+	testCases := []struct {
+		name  string
+		a, b  urn.URN
+		equal bool
+	}{
+		{name: "Identical", a: mustParse(t, "urn:sm:user:alice"), b: mustParse(t, "urn:sm:user:alice"), equal: true},
+		{name: "Namespace case", a: mustNew(t, "SM", "user", "alice"), b: mustParse(t, "urn:sm:user:alice"), equal: true},
+		{name: "Entity type case", a: mustNew(t, "sm", "User", "alice"), b: mustParse(t, "urn:sm:user:alice"), equal: true},
+		{name: "Entity ID case", a: mustParse(t, "urn:sm:user:Alice"), b: mustParse(t, "urn:sm:user:alice"), equal: false},
+		{name: "Parent type case", a: mustParse(t, "urn:sm:USER:alice/device:p1"), b: mustParse(t, "urn:sm:user:alice/device:p1"), equal: true},
+		{name: "Parent ID differs", a: mustParse(t, "urn:sm:user:bob/device:p1"), b: mustParse(t, "urn:sm:user:alice/device:p1"), equal: false},
+		{name: "Depth differs", a: mustParse(t, "urn:sm:user:alice"), b: mustParse(t, "urn:sm:user:alice/device:p1"), equal: false},
+		{name: "Both zero", a: urn.URN{}, b: urn.URN{}, equal: true},
+		{name: "Zero and non-zero", a: urn.URN{}, b: mustParse(t, "urn:sm:user:alice"), equal: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.equal, tc.a.Equal(tc.b))
+			assert.Equal(t, tc.equal, tc.b.Equal(tc.a))
+			if tc.equal {
+				assert.Equal(t, tc.a.Hash(), tc.b.Hash(), "equal URNs must hash equally")
+				assert.Zero(t, tc.a.Compare(tc.b))
+			} else {
+				assert.NotZero(t, tc.a.Compare(tc.b))
+				assert.Equal(t, -tc.a.Compare(tc.b), tc.b.Compare(tc.a))
+			}
+		})
+	}
+}
+
+func TestCanonicalCase(t *testing.T) {
+	testCases := []struct {
+		name     string
+		u        urn.URN
+		expected string
+	}{
+		{name: "Parse", u: mustParse(t, "urn:SM:User:Alice"), expected: "urn:sm:user:Alice"},
+		{name: "Parse parent", u: mustParse(t, "urn:sm:USER:alice/Device:p1"), expected: "urn:sm:user:alice/device:p1"},
+		{name: "New", u: mustNew(t, "Sm", "GROUP", "G1"), expected: "urn:sm:group:G1"},
+		{name: "RFC 8141", u: mustParseRFC8141(t, "URN:SM:User:alice:work"), expected: "urn:sm:user:alice%3Awork"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.u.String())
+			assert.Equal(t, mustParse(t, tc.expected), tc.u, "canonical URNs are ==")
+		})
+	}
+
+	t.Run("Map keys and hierarchy agree with Equal", func(t *testing.T) {
+		seen := map[urn.URN]bool{mustParse(t, "urn:sm:user:a"): true}
+		assert.True(t, seen[mustParse(t, "urn:sm:User:a")])
+
+		child, err := mustParse(t, "urn:sm:USER:a").Child("Device", "p1")
+		require.NoError(t, err)
+		assert.True(t, child.IsDescendantOf(mustParse(t, "urn:sm:user:a")))
+		assert.Equal(t, "urn:sm:user:a/device:p1", child.String())
+	})
+}
+
+func TestCompareSort(t *testing.T) {
+	inputs := []string{
+		"urn:sm:user:bob",
+		"urn:sm:user:alice/device:phone2",
+		"urn:other:user:alice",
+		"urn:sm:group:g1",
+		"urn:sm:user:alice",
+		"",
+		"urn:sm:user:alice/device:phone1",
+	}
+	urns := make([]urn.URN, len(inputs))
+	for i, s := range inputs {
+		urns[i] = mustParse(t, s)
+	}
+
+	slices.SortFunc(urns, urn.URN.Compare)
+
+	var sorted []string
+	for _, u := range urns {
+		sorted = append(sorted, u.String())
+	}
+	assert.Equal(t, []string{
+		"",
+		"urn:other:user:alice",
+		"urn:sm:group:g1",
+		"urn:sm:user:alice",
+		"urn:sm:user:alice/device:phone1",
+		"urn:sm:user:alice/device:phone2",
+		"urn:sm:user:bob",
+	}, sorted)
+
+	i, found := slices.BinarySearchFunc(urns, mustParse(t, "urn:sm:user:alice"), urn.URN.Compare)
+	assert.True(t, found)
+	assert.Equal(t, 3, i)
+}
+
+func TestHashIsStable(t *testing.T) {
+	// These values are persisted by callers for sharding and must never
+	// change.
+	assert.Equal(t, uint64(0x39b34f9f0178a475), mustParse(t, "urn:sm:user:alice").Hash())
+	assert.Equal(t, uint64(0xc5c81e2155de29b0), mustParse(t, "urn:sm:user:alice/device:phone1").Hash())
+	assert.NotEqual(t,
+		mustParse(t, "urn:sm:user:alice").Hash(),
+		mustParse(t, "urn:sm:user:bob").Hash())
+}
+
+func mustNew(t testing.TB, namespace, entityType, entityID string) urn.URN {
+	t.Helper()
+	u, err := urn.New(namespace, entityType, entityID)
+	require.NoError(t, err)
+	return u
+}
+
+func mustParseRFC8141(t testing.TB, s string) urn.URN {
+	t.Helper()
+	u, err := urn.ParseRFC8141(s)
+	require.NoError(t, err)
+	return u
+}
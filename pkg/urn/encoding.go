@@ -0,0 +1,89 @@
+package urn
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"flag"
+	"fmt"
+)
+
+// Compile-time checks that URN satisfies the standard encoding interfaces.
+var (
+	_ encoding.TextMarshaler     = URN{}
+	_ encoding.TextUnmarshaler   = (*URN)(nil)
+	_ encoding.BinaryMarshaler   = URN{}
+	_ encoding.BinaryUnmarshaler = (*URN)(nil)
+	_ sql.Scanner                = (*URN)(nil)
+	_ driver.Valuer              = URN{}
+	_ flag.Value                 = (*URN)(nil)
+)
+
+// MarshalText implements the encoding.TextMarshaler interface. It allows a
+// URN to be used as a map key in JSON, YAML and TOML documents. A zero-value
+// URN marshals to empty text.
+func (u URN) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface. Unlike
+// UnmarshalJSON it does not accept legacy bare user IDs.
+func (u *URN) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface using the
+// compact encoding written by AppendBinary.
+func (u URN) MarshalBinary() ([]byte, error) {
+	return u.AppendBinary(nil)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. data
+// must hold exactly one URN in the compact binary encoding.
+func (u *URN) UnmarshalBinary(data []byte) error {
+	parsed, n, err := DecodeBinary(data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("%w: %d trailing bytes after binary URN", ErrInvalidFormat, len(data)-n)
+	}
+	*u = parsed
+	return nil
+}
+
+// Scan implements the sql.Scanner interface so a URN can be read directly
+// from a text column. SQL NULL scans into the zero-value URN.
+func (u *URN) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*u = URN{}
+		return nil
+	case string:
+		return u.UnmarshalText([]byte(v))
+	case []byte:
+		return u.UnmarshalText(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T into URN", ErrInvalidFormat, src)
+	}
+}
+
+// Value implements the driver.Valuer interface. A zero-value URN is stored
+// as SQL NULL.
+func (u URN) Value() (driver.Value, error) {
+	if u.IsZero() {
+		return nil, nil
+	}
+	return u.String(), nil
+}
+
+// Set implements the flag.Value interface, allowing a URN to be used with
+// flag.Var.
+func (u *URN) Set(s string) error {
+	return u.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,119 @@
+package urn_test
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextMarshaling(t *testing.T) {
+	u := mustParse(t, "urn:sm:user:alice%3Awork/device:phone1")
+
+	text, err := u.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, u.String(), string(text))
+
+	var decoded urn.URN
+	require.NoError(t, decoded.UnmarshalText(text))
+	assert.Equal(t, u, decoded)
+
+	t.Run("Zero-value", func(t *testing.T) {
+		text, err := urn.URN{}.MarshalText()
+		require.NoError(t, err)
+		assert.Empty(t, text)
+
+		decoded := u
+		require.NoError(t, decoded.UnmarshalText(nil))
+		assert.True(t, decoded.IsZero())
+	})
+
+	t.Run("Rejects legacy IDs", func(t *testing.T) {
+		var decoded urn.URN
+		assert.ErrorIs(t, decoded.UnmarshalText([]byte("legacy-user-456")), urn.ErrInvalidFormat)
+	})
+
+	t.Run("JSON map keys", func(t *testing.T) {
+		in := map[urn.URN]int{
+			mustParse(t, "urn:sm:user:alice"): 1,
+			mustParse(t, "urn:sm:group:g1"):   2,
+		}
+		data, err := json.Marshal(in)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"urn:sm:user:alice":1,"urn:sm:group:g1":2}`, string(data))
+
+		var out map[urn.URN]int
+		require.NoError(t, json.Unmarshal(data, &out))
+		assert.Equal(t, in, out)
+	})
+}
+
+func TestBinaryMarshaling(t *testing.T) {
+	u := mustParse(t, "urn:sm:user:alice/device:phone1")
+
+	data, err := u.MarshalBinary()
+	require.NoError(t, err)
+
+	var decoded urn.URN
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, u, decoded)
+}
+
+func TestSQL(t *testing.T) {
+	u := mustParse(t, "urn:sm:user:alice")
+
+	t.Run("Value", func(t *testing.T) {
+		v, err := u.Value()
+		require.NoError(t, err)
+		assert.Equal(t, "urn:sm:user:alice", v)
+
+		v, err = urn.URN{}.Value()
+		require.NoError(t, err)
+		assert.Nil(t, v, "a zero-value URN should be stored as NULL")
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		testCases := []struct {
+			name      string
+			src       any
+			expected  urn.URN
+			expectErr bool
+		}{
+			{name: "String", src: "urn:sm:user:alice", expected: u},
+			{name: "Bytes", src: []byte("urn:sm:user:alice"), expected: u},
+			{name: "NULL", src: nil, expected: urn.URN{}},
+			{name: "Empty string", src: "", expected: urn.URN{}},
+			{name: "Invalid string", src: "not-a-urn", expectErr: true},
+			{name: "Unsupported type", src: 42, expectErr: true},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				scanned := mustParse(t, "urn:sm:group:previous")
+				err := scanned.Scan(tc.src)
+				if tc.expectErr {
+					assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, scanned)
+			})
+		}
+	})
+}
+
+func TestFlagValue(t *testing.T) {
+	var u urn.URN
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&u, "sender", "sender URN")
+
+	require.NoError(t, fs.Parse([]string{"-sender", "urn:sm:user:alice"}))
+	assert.Equal(t, "urn:sm:user:alice", u.String())
+
+	fs.SetOutput(io.Discard)
+	assert.Error(t, fs.Parse([]string{"-sender", "alice"}))
+}
@@ -0,0 +1,93 @@
+package urn
+
+import (
+	"fmt"
+	"strings"
+)
+
+const upperHex = "0123456789ABCDEF"
+
+// shouldEscape reports whether c must be percent-encoded when it appears in
+// a URN component. Everything that is not an RFC 3986 pchar is escaped, as
+// is ":", which separates the components, and "%", which introduces an
+// escape.
+func shouldEscape(c byte) bool {
+	return c == ':' || !isPChar(c)
+}
+
+// escape percent-encodes the reserved characters of a URN component so the
+// result can be safely joined with urnDelimiter and split apart again.
+func escape(s string) string {
+	if !needsEscape(s) {
+		return s
+	}
+	var b strings.Builder
+	writeEscaped(&b, s)
+	return b.String()
+}
+
+// writeEscaped writes the escaped form of s to b without an intermediate
+// allocation.
+func writeEscaped(b *strings.Builder, s string) {
+	if !needsEscape(s) {
+		b.WriteString(s)
+		return
+	}
+	b.Grow(len(s) + 8)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if shouldEscape(c) {
+			b.WriteByte('%')
+			b.WriteByte(upperHex[c>>4])
+			b.WriteByte(upperHex[c&0x0F])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+}
+
+func needsEscape(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if shouldEscape(s[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// unescape reverses escape. It accepts percent-encoded octets in either case
+// and returns an error for a truncated or non-hex escape sequence.
+func unescape(s string) (string, error) {
+	first := strings.IndexByte(s, '%')
+	if first < 0 {
+		return s, nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s[:first])
+	for i := first; i < len(s); i++ {
+		c := s[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			return "", fmt.Errorf("%w: invalid percent-encoding in '%s'", ErrInvalidFormat, s)
+		}
+		b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,196 @@
+package urn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// segmentDelimiter separates the segments of a hierarchical URN such as
+// "urn:sm:user:alice/device:phone1".
+const segmentDelimiter = "/"
+
+// Child returns a new URN for a sub-resource of u, for example a device
+// belonging to a user. It returns an error if u is the zero value or if the
+// entity type or ID is empty.
+func (u URN) Child(entityType, entityID string) (URN, error) {
+	if u.IsZero() {
+		return URN{}, fmt.Errorf("%w: cannot create a child of a zero-value URN", ErrInvalidFormat)
+	}
+	child, err := New(u.namespace, entityType, entityID)
+	if err != nil {
+		return URN{}, err
+	}
+	child.scheme = u.scheme
+	child.parent = u.path()
+	return child, nil
+}
+
+// Parent returns the URN that u is a sub-resource of, or a zero-value URN if
+// u has no parent.
+func (u URN) Parent() URN {
+	if u.parent == "" {
+		return URN{}
+	}
+	grandparent, last := "", u.parent
+	if i := strings.LastIndex(u.parent, segmentDelimiter); i >= 0 {
+		grandparent, last = u.parent[:i], u.parent[i+1:]
+	}
+	// The parent path is always stored in canonical form, so the segment is
+	// known to be well formed.
+	entityType, entityID, _ := strings.Cut(last, urnDelimiter)
+	entityType, _ = unescape(entityType)
+	entityID, _ = unescape(entityID)
+	return URN{
+		scheme:     u.scheme,
+		namespace:  u.namespace,
+		parent:     grandparent,
+		entityType: internKnown(entityType),
+		entityID:   entityID,
+	}
+}
+
+// Ancestors returns every ancestor of u, starting with the top-level URN and
+// ending with u's direct parent. It returns nil for a top-level URN.
+func (u URN) Ancestors() []URN {
+	if u.parent == "" {
+		return nil
+	}
+	ancestors := make([]URN, u.Depth()-1)
+	for i, p := len(ancestors)-1, u.Parent(); i >= 0; i, p = i-1, p.Parent() {
+		ancestors[i] = p
+	}
+	return ancestors
+}
+
+// IsDescendantOf reports whether u is a sub-resource, at any depth, of
+// ancestor. A URN is not a descendant of itself.
+func (u URN) IsDescendantOf(ancestor URN) bool {
+	if u.parent == "" || ancestor.IsZero() {
+		return false
+	}
+	if u.scheme != ancestor.scheme || u.namespace != ancestor.namespace {
+		return false
+	}
+	prefix := ancestor.path()
+	return u.parent == prefix || strings.HasPrefix(u.parent, prefix+segmentDelimiter)
+}
+
+// Depth returns the number of segments in u: 1 for a top-level URN such as
+// urn:sm:user:alice, 2 for urn:sm:user:alice/device:phone1, and 0 for the
+// zero value.
+func (u URN) Depth() int {
+	if u.IsZero() {
+		return 0
+	}
+	if u.parent == "" {
+		return 1
+	}
+	return strings.Count(u.parent, segmentDelimiter) + 2
+}
+
+// path returns the escaped segment path of u, including its own segment.
+func (u URN) path() string {
+	segment := escape(u.entityType) + urnDelimiter + escape(u.entityID)
+	if u.parent == "" {
+		return segment
+	}
+	return u.parent + segmentDelimiter + segment
+}
+
+// parseNSS parses the "<type>:<id>[/<type>:<id>...]" part of a URN. When
+// allowColons is set, everything after the first colon of a segment is
+// treated as its ID; otherwise a literal colon in an ID is an error.
+//
+// When the ancestor segments are already in canonical form, which is the case
+// for anything produced by String(), the parent path is sliced out of nss
+// rather than rebuilt, so parsing does not allocate.
+func parseNSS(namespace, nss string, allowColons bool) (URN, error) {
+	full := nss
+	parentEnd := 0 // end of the canonical prefix of full used as the parent
+	var rebuilt *strings.Builder
+
+	for {
+		segment, rest, more := strings.Cut(nss, segmentDelimiter)
+
+		entityType, entityID, ok := strings.Cut(segment, urnDelimiter)
+		if !ok || (!allowColons && strings.Contains(entityID, urnDelimiter)) {
+			return URN{}, fmt.Errorf("%w: segment '%s' must be of the form <type>:<id>", ErrInvalidFormat, segment)
+		}
+
+		// A canonical ancestor is only checked for emptiness: its escaped
+		// form is kept as-is, so there is nothing to decode.
+		if more && rebuilt == nil && isCanonicalSegment(segment) {
+			if entityType == "" || entityID == "" {
+				return URN{}, fmt.Errorf("%w: segment '%s' must be of the form <type>:<id>", ErrInvalidFormat, segment)
+			}
+			if parentEnd > 0 {
+				parentEnd += len(segmentDelimiter)
+			}
+			parentEnd += len(segment)
+			nss = rest
+			continue
+		}
+
+		entityType, err := unescape(entityType)
+		if err != nil {
+			return URN{}, err
+		}
+		entityID, err = unescape(entityID)
+		if err != nil {
+			return URN{}, err
+		}
+		u, err := New(namespace, entityType, entityID)
+		if err != nil {
+			return URN{}, err
+		}
+
+		if !more {
+			if rebuilt != nil {
+				u.parent = rebuilt.String()
+			} else if parentEnd > 0 {
+				u.parent = full[:parentEnd]
+			}
+			return u, nil
+		}
+
+		if rebuilt == nil {
+			rebuilt = &strings.Builder{}
+			rebuilt.WriteString(full[:parentEnd])
+		}
+		if rebuilt.Len() > 0 {
+			rebuilt.WriteString(segmentDelimiter)
+		}
+		rebuilt.WriteString(u.path())
+		nss = rest
+	}
+}
+
+// isCanonicalSegment reports whether a raw "<type>:<id>" segment is exactly
+// what path() would produce for it: a single separating colon, a lower-case
+// entity type and no characters that are escaped differently, or
+// unnecessarily.
+func isCanonicalSegment(segment string) bool {
+	colons := 0
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch {
+		case c == ':':
+			colons++
+		case colons == 0 && 'A' <= c && c <= 'Z':
+			return false
+		case c == '%':
+			if i+2 >= len(segment) || !isUpperHex(segment[i+1]) || !isUpperHex(segment[i+2]) ||
+				!shouldEscape(unhex(segment[i+1])<<4|unhex(segment[i+2])) {
+				return false
+			}
+			i += 2
+		case shouldEscape(c):
+			return false
+		}
+	}
+	return colons == 1
+}
+
+func isUpperHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('A' <= c && c <= 'F')
+}
@@ -0,0 +1,169 @@
+package urn_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHierarchicalParse(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		expectedURN   string
+		expectedType  string
+		expectedID    string
+		expectedDepth int
+		expectErr     bool
+	}{
+		{
+			name:          "Top-level URN",
+			input:         "urn:sm:user:alice",
+			expectedURN:   "urn:sm:user:alice",
+			expectedType:  "user",
+			expectedID:    "alice",
+			expectedDepth: 1,
+		},
+		{
+			name:          "Device of a user",
+			input:         "urn:sm:user:alice/device:phone1",
+			expectedURN:   "urn:sm:user:alice/device:phone1",
+			expectedType:  "device",
+			expectedID:    "phone1",
+			expectedDepth: 2,
+		},
+		{
+			name:          "Three levels",
+			input:         "urn:sm:user:alice/device:phone1/key:42",
+			expectedURN:   "urn:sm:user:alice/device:phone1/key:42",
+			expectedType:  "key",
+			expectedID:    "42",
+			expectedDepth: 3,
+		},
+		{
+			name:          "Escaped separators in IDs",
+			input:         "urn:sm:user:a%2fb/device:c%3ad",
+			expectedURN:   "urn:sm:user:a%2Fb/device:c%3Ad",
+			expectedType:  "device",
+			expectedID:    "c:d",
+			expectedDepth: 2,
+		},
+		{name: "Empty segment", input: "urn:sm:user:alice//device:phone1", expectErr: true},
+		{name: "Trailing slash", input: "urn:sm:user:alice/", expectErr: true},
+		{name: "Segment without ID", input: "urn:sm:user:alice/device", expectErr: true},
+		{name: "Segment with empty ID", input: "urn:sm:user:alice/device:", expectErr: true},
+		{name: "Literal colon in ID", input: "urn:sm:user:alice/device:a:b", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := urn.Parse(tc.input)
+			if tc.expectErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedURN, u.String())
+			assert.Equal(t, tc.expectedType, u.EntityType())
+			assert.Equal(t, tc.expectedID, u.EntityID())
+			assert.Equal(t, tc.expectedDepth, u.Depth())
+		})
+	}
+}
+
+func TestHierarchyNavigation(t *testing.T) {
+	user, err := urn.New(urn.SecureMessaging, urn.EntityTypeUser, "alice")
+	require.NoError(t, err)
+	device, err := user.Child(urn.EntityTypeDevice, "phone/1")
+	require.NoError(t, err)
+	key, err := device.Child(urn.EntityTypeKey, "42")
+	require.NoError(t, err)
+
+	t.Run("Child", func(t *testing.T) {
+		assert.Equal(t, "urn:sm:user:alice/device:phone%2F1", device.String())
+		assert.Equal(t, "urn:sm:user:alice/device:phone%2F1/key:42", key.String())
+
+		_, err := user.Child("", "x")
+		assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+		_, err = urn.URN{}.Child(urn.EntityTypeDevice, "x")
+		assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+	})
+
+	t.Run("Parent", func(t *testing.T) {
+		assert.Equal(t, device, key.Parent())
+		assert.Equal(t, user, device.Parent())
+		assert.True(t, user.Parent().IsZero())
+	})
+
+	t.Run("Ancestors", func(t *testing.T) {
+		assert.Equal(t, []urn.URN{user, device}, key.Ancestors())
+		assert.Equal(t, []urn.URN{user}, device.Ancestors())
+		assert.Nil(t, user.Ancestors())
+	})
+
+	t.Run("IsDescendantOf", func(t *testing.T) {
+		assert.True(t, key.IsDescendantOf(device))
+		assert.True(t, key.IsDescendantOf(user))
+		assert.True(t, device.IsDescendantOf(user))
+		assert.False(t, user.IsDescendantOf(user))
+		assert.False(t, user.IsDescendantOf(device))
+		assert.False(t, key.IsDescendantOf(urn.URN{}))
+
+		// "alice" must not match "alice2" by string prefix.
+		alice2, err := urn.Parse("urn:sm:user:alice2/device:phone%2F1")
+		require.NoError(t, err)
+		assert.False(t, alice2.IsDescendantOf(user))
+
+		other, err := urn.New("other", urn.EntityTypeUser, "alice")
+		require.NoError(t, err)
+		assert.False(t, device.IsDescendantOf(other))
+	})
+
+	t.Run("Round-trip", func(t *testing.T) {
+		parsed, err := urn.Parse(key.String())
+		require.NoError(t, err)
+		assert.Equal(t, key, parsed)
+
+		data, err := json.Marshal(key)
+		require.NoError(t, err)
+		assert.Equal(t, `"urn:sm:user:alice/device:phone%2F1/key:42"`, string(data))
+
+		var decoded urn.URN
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, key, decoded)
+	})
+
+	t.Run("Non-canonical input is normalized", func(t *testing.T) {
+		parsed, err := urn.Parse("urn:sm:user:%61lice/device:phone%2f1")
+		require.NoError(t, err)
+		assert.Equal(t, device, parsed)
+		assert.Equal(t, user, parsed.Parent())
+	})
+}
+
+func TestHierarchicalRegistry(t *testing.T) {
+	r := urn.NewRegistry()
+	r.MustRegister(urn.EntityTypeUser, nil)
+	r.MustRegister(urn.EntityTypeDevice, urn.Numeric)
+
+	_, err := r.Parse("urn:sm:user:alice/device:1")
+	assert.NoError(t, err)
+	_, err = r.Parse("urn:sm:user:alice/device:phone")
+	assert.ErrorIs(t, err, urn.ErrInvalidEntityID)
+	_, err = r.Parse("urn:sm:group:g1/device:1")
+	assert.ErrorIs(t, err, urn.ErrUnregisteredType)
+}
+
+func TestHierarchicalRFC8141(t *testing.T) {
+	u, err := urn.ParseRFC8141("urn:SM:user:alice@example.com:work/device:phone1")
+	require.NoError(t, err)
+	assert.Equal(t, "urn:sm:user:alice@example.com%3Awork/device:phone1", u.String())
+	assert.Equal(t, "alice@example.com:work", u.Parent().EntityID())
+
+	_, err = urn.ParseRFC8141("urn:sm:user:alice/dev.ice:phone1")
+	assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+}
@@ -0,0 +1,109 @@
+package urn
+
+import (
+	"strings"
+	"sync"
+)
+
+// internKnown returns the package constant equal to s, if there is one, so
+// that parsed URNs share the constant's storage instead of holding on to a
+// slice of the input string.
+func internKnown(s string) string {
+	switch s {
+	case SecureMessaging:
+		return SecureMessaging
+	case EntityTypeUser:
+		return EntityTypeUser
+	case EntityTypeGroup:
+		return EntityTypeGroup
+	case EntityTypeDevice:
+		return EntityTypeDevice
+	case EntityTypeConversation:
+		return EntityTypeConversation
+	case EntityTypeMessage:
+		return EntityTypeMessage
+	case EntityTypeKey:
+		return EntityTypeKey
+	case EntityTypeBlob:
+		return EntityTypeBlob
+	}
+	return s
+}
+
+// lowerASCII returns s with ASCII letters lower-cased. It only allocates if
+// s contains an upper-case letter.
+func lowerASCII(s string) string {
+	for i := 0; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				b[j] = toLowerASCII(b[j])
+			}
+			return string(b)
+		}
+	}
+	return s
+}
+
+// Interner is an optional table for deduplicating the namespace, entity type
+// and parent path strings of parsed URNs. Decoding a large batch of envelopes
+// with an Interner means every URN shares one copy of each namespace, type
+// and parent, such as the user that owns many devices, instead of keeping a
+// reference into its own input buffer. It is safe for concurrent use.
+type Interner struct {
+	mu      sync.RWMutex
+	strings map[string]string
+	limit   int
+}
+
+// NewInterner creates an Interner that holds at most limit distinct strings.
+// Once full, new strings are returned unchanged rather than interned, which
+// bounds memory use when parsing untrusted input. A limit of zero or less
+// means no limit.
+func NewInterner(limit int) *Interner {
+	return &Interner{strings: make(map[string]string), limit: limit}
+}
+
+// Intern returns a canonical copy of s.
+func (in *Interner) Intern(s string) string {
+	in.mu.RLock()
+	v, ok := in.strings[s]
+	in.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if v, ok := in.strings[s]; ok {
+		return v
+	}
+	if in.limit > 0 && len(in.strings) >= in.limit {
+		return s
+	}
+	v = strings.Clone(s)
+	in.strings[v] = v
+	return v
+}
+
+// Len returns the number of interned strings.
+func (in *Interner) Len() int {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return len(in.strings)
+}
+
+// Parse is like the package-level Parse, but interns the namespace, the
+// entity type and, for a hierarchical URN, the parent path of the result.
+func (in *Interner) Parse(s string) (URN, error) {
+	u, err := Parse(s)
+	if err != nil || u.IsZero() {
+		return u, err
+	}
+	u.namespace = in.Intern(u.namespace)
+	u.entityType = in.Intern(u.entityType)
+	if u.parent != "" {
+		u.parent = in.Intern(u.parent)
+	}
+	return u, nil
+}
@@ -0,0 +1,92 @@
+package urn_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sameStorage(a, b string) bool {
+	return unsafe.StringData(a) == unsafe.StringData(b)
+}
+
+func TestParseInternsWellKnownStrings(t *testing.T) {
+	input := strings.Clone("urn:sm:user:alice/device:phone1")
+	u, err := urn.Parse(input)
+	require.NoError(t, err)
+	assert.True(t, sameStorage(urn.EntityTypeDevice, u.EntityType()))
+	assert.True(t, sameStorage(urn.EntityTypeUser, u.Parent().EntityType()))
+}
+
+func TestInterner(t *testing.T) {
+	in := urn.NewInterner(0)
+
+	a, err := in.Parse(strings.Clone("urn:custom:widget:w-1"))
+	require.NoError(t, err)
+	b, err := in.Parse(strings.Clone("urn:custom:widget:w-2"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "urn:custom:widget:w-1", a.String())
+	assert.True(t, sameStorage(a.EntityType(), b.EntityType()))
+	assert.Equal(t, 2, in.Len(), "namespace and entity type")
+
+	t.Run("Hierarchical", func(t *testing.T) {
+		hin := urn.NewInterner(0)
+		phone, err := hin.Parse(strings.Clone("urn:sm:user:alice/device:phone"))
+		require.NoError(t, err)
+		laptop, err := hin.Parse(strings.Clone("urn:sm:user:alice/device:laptop"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "urn:sm:user:alice/device:laptop", laptop.String())
+		assert.True(t, sameStorage(phone.Parent().EntityID(), laptop.Parent().EntityID()), "the parent path is shared")
+		assert.Equal(t, 3, hin.Len(), "namespace, entity type and parent path")
+
+		input := strings.Clone("urn:sm:user:alice/device:tablet")
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := hin.Parse(input); err != nil {
+				t.Fatal(err)
+			}
+		})
+		assert.Zero(t, allocs, "parsing against a warm interner does not allocate")
+	})
+
+	t.Run("Zero-value and errors", func(t *testing.T) {
+		u, err := in.Parse("")
+		require.NoError(t, err)
+		assert.True(t, u.IsZero())
+
+		_, err = in.Parse("bad")
+		assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		limited := urn.NewInterner(1)
+		first := limited.Intern(strings.Clone("one"))
+		assert.True(t, sameStorage(first, limited.Intern(strings.Clone("one"))))
+
+		two := strings.Clone("two")
+		assert.True(t, sameStorage(two, limited.Intern(two)), "strings beyond the limit are returned as-is")
+		assert.Equal(t, 1, limited.Len())
+	})
+
+	t.Run("Concurrent use", func(t *testing.T) {
+		shared := urn.NewInterner(0)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					shared.Intern(strings.Clone("type"))
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, shared.Len())
+	})
+}
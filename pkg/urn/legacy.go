@@ -0,0 +1,180 @@
+package urn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrLegacyID is returned when a bare, non-URN ID is decoded with
+// LegacyReject, or with a mode that cannot convert it.
+var ErrLegacyID = errors.New("legacy ID is not a URN")
+
+// LegacyMode selects how DecodeOptions handles a string that is not a URN.
+type LegacyMode int
+
+const (
+	// LegacyAsUser converts a bare ID into urn:sm:user:<id>. This is the
+	// historical behaviour and the default.
+	LegacyAsUser LegacyMode = iota
+	// LegacyReject refuses bare IDs with ErrLegacyID.
+	LegacyReject
+	// LegacyAsTypeHint converts a bare ID into urn:sm:<TypeHint>:<id>.
+	LegacyAsTypeHint
+	// LegacyCallback delegates the conversion to DecodeOptions.Convert.
+	LegacyCallback
+)
+
+// DecodeOptions controls how URNs are decoded from JSON and from plain
+// strings, in particular how legacy bare IDs are handled.
+type DecodeOptions struct {
+	// Mode selects the legacy ID handling.
+	Mode LegacyMode
+	// TypeHint is the entity type used by LegacyAsTypeHint.
+	TypeHint string
+	// Convert is called by LegacyCallback to turn a bare ID into a URN.
+	Convert func(legacyID string) (URN, error)
+	// OnLegacy, if set, is called after every successful legacy conversion
+	// so that callers can log or count IDs that still need migrating.
+	OnLegacy func(legacyID string, converted URN)
+}
+
+// The default options and the conversion count are process-wide, because
+// json.Unmarshaler gives URN.UnmarshalJSON no other way to be configured.
+// Both are atomic, so they are safe to read and update concurrently.
+var (
+	defaultDecodeOptions atomic.Pointer[DecodeOptions]
+	legacyConversions    atomic.Uint64
+)
+
+// SetDefaultDecodeOptions replaces the process-wide options used by
+// URN.UnmarshalJSON. It is safe to call concurrently with decoding, but is
+// intended to be called once during service start-up. Because it affects
+// every decode in the process, libraries should not call it; they should use
+// Decoded fields or DecodeOptions.DecodeJSON instead.
+func SetDefaultDecodeOptions(opts DecodeOptions) {
+	defaultDecodeOptions.Store(&opts)
+}
+
+// DefaultDecodeOptions returns the options used by URN.UnmarshalJSON.
+func DefaultDecodeOptions() DecodeOptions {
+	if opts := defaultDecodeOptions.Load(); opts != nil {
+		return *opts
+	}
+	return DecodeOptions{}
+}
+
+// DecodePolicy supplies the DecodeOptions used by a Decoded URN. A policy is
+// usually an empty struct type whose DecodeOptions method returns fixed
+// options.
+type DecodePolicy interface {
+	DecodeOptions() DecodeOptions
+}
+
+// Strict is a DecodePolicy that rejects legacy bare IDs.
+type Strict struct{}
+
+// DecodeOptions implements DecodePolicy.
+func (Strict) DecodeOptions() DecodeOptions {
+	return DecodeOptions{Mode: LegacyReject}
+}
+
+// AsUser is a DecodePolicy that converts legacy bare IDs into user URNs,
+// whatever the package default.
+type AsUser struct{}
+
+// DecodeOptions implements DecodePolicy.
+func (AsUser) DecodeOptions() DecodeOptions {
+	return DecodeOptions{Mode: LegacyAsUser}
+}
+
+// Decoded is a URN that is decoded from JSON with the options of its policy
+// P instead of the package default, so that legacy ID handling can be chosen
+// per field without changing global state:
+//
+//	type deviceHint struct{}
+//
+//	func (deviceHint) DecodeOptions() urn.DecodeOptions {
+//		return urn.DecodeOptions{Mode: urn.LegacyAsTypeHint, TypeHint: urn.EntityTypeDevice}
+//	}
+//
+//	type request struct {
+//		Sender  urn.Decoded[urn.Strict] `json:"sender"`
+//		Devices []urn.Decoded[deviceHint] `json:"devices"`
+//	}
+//
+// It marshals exactly like the embedded URN.
+type Decoded[P DecodePolicy] struct {
+	URN
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface using the options
+// of P.
+func (d *Decoded[P]) UnmarshalJSON(data []byte) error {
+	var policy P
+	parsed, err := policy.DecodeOptions().DecodeJSON(data)
+	if err != nil {
+		return err
+	}
+	d.URN = parsed
+	return nil
+}
+
+// LegacyConversions returns the number of legacy IDs converted to URNs by
+// this process, across all DecodeOptions, since it started. It is a cheap way
+// to track migration progress from a metrics endpoint; use
+// DecodeOptions.OnLegacy to count the conversions of one decoder.
+func LegacyConversions() uint64 {
+	return legacyConversions.Load()
+}
+
+// DecodeJSON decodes a JSON string into a URN. JSON null and the empty
+// string both decode to the zero-value URN.
+func (o DecodeOptions) DecodeJSON(data []byte) (URN, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return URN{}, fmt.Errorf("URN should be a string, but got %s: %w", string(data), err)
+	}
+	return o.DecodeString(s)
+}
+
+// DecodeString parses s as a URN, applying the legacy handling to strings
+// that do not start with "urn:".
+func (o DecodeOptions) DecodeString(s string) (URN, error) {
+	if s == "" || strings.HasPrefix(s, Scheme+urnDelimiter) {
+		return Parse(s)
+	}
+
+	var converted URN
+	var err error
+	switch o.Mode {
+	case LegacyAsUser:
+		converted, err = New(SecureMessaging, EntityTypeUser, s)
+	case LegacyAsTypeHint:
+		if o.TypeHint == "" {
+			return URN{}, fmt.Errorf("%w: no type hint configured for '%s'", ErrLegacyID, s)
+		}
+		converted, err = New(SecureMessaging, o.TypeHint, s)
+	case LegacyCallback:
+		if o.Convert == nil {
+			return URN{}, fmt.Errorf("%w: no converter configured for '%s'", ErrLegacyID, s)
+		}
+		converted, err = o.Convert(s)
+		if err == nil && converted.IsZero() {
+			err = fmt.Errorf("%w: converter returned a zero-value URN for '%s'", ErrLegacyID, s)
+		}
+	default:
+		return URN{}, fmt.Errorf("%w: '%s'", ErrLegacyID, s)
+	}
+	if err != nil {
+		return URN{}, err
+	}
+
+	legacyConversions.Add(1)
+	if o.OnLegacy != nil {
+		o.OnLegacy(s, converted)
+	}
+	return converted, nil
+}
@@ -0,0 +1,215 @@
+package urn_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeOptions(t *testing.T) {
+	errUnknownID := errors.New("unknown id")
+
+	testCases := []struct {
+		name          string
+		opts          urn.DecodeOptions
+		input         string
+		expectedURN   string
+		expectedErrIs error
+		expectLegacy  bool
+	}{
+		{
+			name:         "Default converts to user",
+			opts:         urn.DecodeOptions{},
+			input:        "legacy-456",
+			expectedURN:  "urn:sm:user:legacy-456",
+			expectLegacy: true,
+		},
+		{
+			name:          "Reject",
+			opts:          urn.DecodeOptions{Mode: urn.LegacyReject},
+			input:         "legacy-456",
+			expectedErrIs: urn.ErrLegacyID,
+		},
+		{
+			name:         "Type hint",
+			opts:         urn.DecodeOptions{Mode: urn.LegacyAsTypeHint, TypeHint: urn.EntityTypeGroup},
+			input:        "group-1",
+			expectedURN:  "urn:sm:group:group-1",
+			expectLegacy: true,
+		},
+		{
+			name:          "Type hint missing",
+			opts:          urn.DecodeOptions{Mode: urn.LegacyAsTypeHint},
+			input:         "group-1",
+			expectedErrIs: urn.ErrLegacyID,
+		},
+		{
+			name: "Callback",
+			opts: urn.DecodeOptions{Mode: urn.LegacyCallback, Convert: func(id string) (urn.URN, error) {
+				return urn.New(urn.SecureMessaging, urn.EntityTypeDevice, id)
+			}},
+			input:        "phone1",
+			expectedURN:  "urn:sm:device:phone1",
+			expectLegacy: true,
+		},
+		{
+			name: "Callback error",
+			opts: urn.DecodeOptions{Mode: urn.LegacyCallback, Convert: func(string) (urn.URN, error) {
+				return urn.URN{}, errUnknownID
+			}},
+			input:         "phone1",
+			expectedErrIs: errUnknownID,
+		},
+		{
+			name:          "Callback missing",
+			opts:          urn.DecodeOptions{Mode: urn.LegacyCallback},
+			input:         "phone1",
+			expectedErrIs: urn.ErrLegacyID,
+		},
+		{
+			name: "Callback returns zero-value",
+			opts: urn.DecodeOptions{Mode: urn.LegacyCallback, Convert: func(string) (urn.URN, error) {
+				return urn.URN{}, nil
+			}},
+			input:         "phone1",
+			expectedErrIs: urn.ErrLegacyID,
+		},
+		{
+			name:        "Full URN is never legacy",
+			opts:        urn.DecodeOptions{Mode: urn.LegacyReject},
+			input:       "urn:sm:group:g1",
+			expectedURN: "urn:sm:group:g1",
+		},
+		{
+			name:        "Empty string is never legacy",
+			opts:        urn.DecodeOptions{Mode: urn.LegacyReject},
+			input:       "",
+			expectedURN: "",
+		},
+		{
+			name:          "Invalid URN is not treated as legacy",
+			opts:          urn.DecodeOptions{},
+			input:         "urn:sm:user",
+			expectedErrIs: urn.ErrInvalidFormat,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var reported []string
+			tc.opts.OnLegacy = func(legacyID string, converted urn.URN) {
+				reported = append(reported, legacyID+" -> "+converted.String())
+			}
+			before := urn.LegacyConversions()
+
+			u, err := tc.opts.DecodeString(tc.input)
+			if tc.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tc.expectedErrIs)
+				assert.Empty(t, reported)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedURN, u.String())
+
+			if tc.expectLegacy {
+				assert.Equal(t, []string{tc.input + " -> " + tc.expectedURN}, reported)
+				assert.Equal(t, before+1, urn.LegacyConversions())
+			} else {
+				assert.Empty(t, reported)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	opts := urn.DecodeOptions{Mode: urn.LegacyReject}
+
+	u, err := opts.DecodeJSON([]byte(`"urn:sm:user:alice"`))
+	require.NoError(t, err)
+	assert.Equal(t, "urn:sm:user:alice", u.String())
+
+	u, err = opts.DecodeJSON([]byte(`null`))
+	require.NoError(t, err)
+	assert.True(t, u.IsZero())
+
+	_, err = opts.DecodeJSON([]byte(`42`))
+	assert.Error(t, err)
+
+	_, err = opts.DecodeJSON([]byte(`"alice"`))
+	assert.ErrorIs(t, err, urn.ErrLegacyID)
+}
+
+func TestDefaultDecodeOptions(t *testing.T) {
+	original := urn.DefaultDecodeOptions()
+	t.Cleanup(func() { urn.SetDefaultDecodeOptions(original) })
+
+	type envelope struct {
+		GroupID urn.URN `json:"groupId"`
+	}
+
+	t.Run("Strict default rejects legacy IDs", func(t *testing.T) {
+		urn.SetDefaultDecodeOptions(urn.DecodeOptions{Mode: urn.LegacyReject})
+		var e envelope
+		err := json.Unmarshal([]byte(`{"groupId":"typo-group"}`), &e)
+		assert.ErrorIs(t, err, urn.ErrLegacyID)
+	})
+
+	t.Run("Type hint default", func(t *testing.T) {
+		var migrated []string
+		urn.SetDefaultDecodeOptions(urn.DecodeOptions{
+			Mode:     urn.LegacyAsTypeHint,
+			TypeHint: urn.EntityTypeGroup,
+			OnLegacy: func(legacyID string, _ urn.URN) { migrated = append(migrated, legacyID) },
+		})
+		var e envelope
+		require.NoError(t, json.Unmarshal([]byte(`{"groupId":"group-1"}`), &e))
+		assert.Equal(t, "urn:sm:group:group-1", e.GroupID.String())
+		assert.Equal(t, []string{"group-1"}, migrated)
+	})
+}
+
+type deviceHint struct{}
+
+func (deviceHint) DecodeOptions() urn.DecodeOptions {
+	return urn.DecodeOptions{Mode: urn.LegacyAsTypeHint, TypeHint: urn.EntityTypeDevice}
+}
+
+func TestDecoded(t *testing.T) {
+	type request struct {
+		Sender  urn.Decoded[urn.Strict]   `json:"sender"`
+		Owner   urn.Decoded[urn.AsUser]   `json:"owner"`
+		Devices []urn.Decoded[deviceHint] `json:"devices"`
+		Plain   urn.URN                   `json:"plain"`
+	}
+
+	// Per-field policies apply whatever the package default is, and the
+	// default still applies to plain URN fields.
+	original := urn.DefaultDecodeOptions()
+	t.Cleanup(func() { urn.SetDefaultDecodeOptions(original) })
+	urn.SetDefaultDecodeOptions(urn.DecodeOptions{Mode: urn.LegacyAsTypeHint, TypeHint: urn.EntityTypeGroup})
+
+	var r request
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"sender": "urn:sm:user:alice",
+		"owner": "bob",
+		"devices": ["phone", "urn:sm:device:laptop"],
+		"plain": "team"
+	}`), &r))
+	assert.Equal(t, "urn:sm:user:alice", r.Sender.String())
+	assert.Equal(t, "urn:sm:user:bob", r.Owner.String())
+	require.Len(t, r.Devices, 2)
+	assert.Equal(t, "urn:sm:device:phone", r.Devices[0].String())
+	assert.Equal(t, "urn:sm:device:laptop", r.Devices[1].String())
+	assert.Equal(t, "urn:sm:group:team", r.Plain.String())
+
+	err := json.Unmarshal([]byte(`{"sender":"alice"}`), &r)
+	assert.ErrorIs(t, err, urn.ErrLegacyID)
+
+	data, err := json.Marshal(urn.Decoded[urn.Strict]{URN: r.Owner.URN})
+	require.NoError(t, err)
+	assert.JSONEq(t, `"urn:sm:user:bob"`, string(data))
+}
@@ -0,0 +1,322 @@
+package urn
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Pattern is a compiled URN selector such as "urn:sm:group:*" or
+// "urn:sm:*:admin-*". The namespace, entity type and entity ID of every
+// segment are matched independently with glob syntax: '*' matches any run
+// of characters (including none) and '?' matches exactly one character.
+// Percent-encoded octets in the pattern match the literal decoded character,
+// so "%2A" matches a literal '*'. Like URNs themselves, the namespace and
+// entity types are matched case-insensitively; entity IDs are not.
+//
+// A pattern only matches URNs with the same number of segments, so
+// "urn:sm:user:*" matches users but not their devices; use
+// "urn:sm:user:*/device:*" for those.
+type Pattern struct {
+	expr      string
+	namespace glob
+	segments  []patternSegment
+}
+
+type patternSegment struct {
+	entityType glob
+	entityID   glob
+}
+
+// CompilePattern parses a URN pattern expression.
+func CompilePattern(expr string) (*Pattern, error) {
+	scheme, rest, _ := strings.Cut(expr, urnDelimiter)
+	if scheme != Scheme {
+		return nil, fmt.Errorf("%w: pattern '%s' must start with '%s:'", ErrInvalidFormat, expr, Scheme)
+	}
+	namespace, nss, ok := strings.Cut(rest, urnDelimiter)
+	if !ok {
+		return nil, fmt.Errorf("%w: pattern '%s' must be of the form urn:<namespace>:<type>:<id>", ErrInvalidFormat, expr)
+	}
+
+	p := &Pattern{expr: expr}
+	var err error
+	if p.namespace, err = compileGlob(namespace, true); err != nil {
+		return nil, err
+	}
+	for _, segment := range strings.Split(nss, segmentDelimiter) {
+		entityType, entityID, ok := strings.Cut(segment, urnDelimiter)
+		if !ok || strings.Contains(entityID, urnDelimiter) {
+			return nil, fmt.Errorf("%w: pattern segment '%s' must be of the form <type>:<id>", ErrInvalidFormat, segment)
+		}
+		var s patternSegment
+		if s.entityType, err = compileGlob(entityType, true); err != nil {
+			return nil, err
+		}
+		if s.entityID, err = compileGlob(entityID, false); err != nil {
+			return nil, err
+		}
+		p.segments = append(p.segments, s)
+	}
+	return p, nil
+}
+
+// MustCompilePattern is like CompilePattern but panics if expr is invalid.
+func MustCompilePattern(expr string) *Pattern {
+	p, err := CompilePattern(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the expression the pattern was compiled from.
+func (p *Pattern) String() string {
+	return p.expr
+}
+
+// Match reports whether u matches the pattern. The zero-value URN never
+// matches.
+func (p *Pattern) Match(u URN) bool {
+	if u.IsZero() || u.Depth() != len(p.segments) || !p.namespace.match(u.namespace) {
+		return false
+	}
+	last := p.segments[len(p.segments)-1]
+	if !last.entityType.match(u.entityType) || !last.entityID.match(u.entityID) {
+		return false
+	}
+	for i, a := range u.Ancestors() {
+		if !p.segments[i].entityType.match(a.entityType) || !p.segments[i].entityID.match(a.entityID) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchString parses s and reports whether it matches the pattern. Strings
+// that are not valid URNs never match.
+func (p *Pattern) MatchString(s string) bool {
+	u, err := Parse(s)
+	return err == nil && p.Match(u)
+}
+
+// PatternSet tests a URN against many patterns at once. Patterns whose
+// namespace and final entity type are literals are indexed by those values,
+// so a lookup only evaluates the patterns that could possibly match plus
+// those with wildcards in the indexed positions.
+//
+// A PatternSet is not safe for concurrent modification; build it up front
+// and then share it for read-only matching.
+type PatternSet struct {
+	indexed  map[string]map[string][]*Pattern
+	wildcard []*Pattern
+	size     int
+}
+
+// NewPatternSet compiles exprs into a new PatternSet.
+func NewPatternSet(exprs ...string) (*PatternSet, error) {
+	s := &PatternSet{indexed: make(map[string]map[string][]*Pattern)}
+	for _, expr := range exprs {
+		p, err := CompilePattern(expr)
+		if err != nil {
+			return nil, err
+		}
+		s.Add(p)
+	}
+	return s, nil
+}
+
+// Add inserts a compiled pattern into the set.
+func (s *PatternSet) Add(p *Pattern) {
+	if s.indexed == nil {
+		s.indexed = make(map[string]map[string][]*Pattern)
+	}
+	s.size++
+	last := p.segments[len(p.segments)-1]
+	if !p.namespace.isLiteral() || !last.entityType.isLiteral() {
+		s.wildcard = append(s.wildcard, p)
+		return
+	}
+	byType, ok := s.indexed[p.namespace.literal]
+	if !ok {
+		byType = make(map[string][]*Pattern)
+		s.indexed[p.namespace.literal] = byType
+	}
+	byType[last.entityType.literal] = append(byType[last.entityType.literal], p)
+}
+
+// Len returns the number of patterns in the set.
+func (s *PatternSet) Len() int {
+	return s.size
+}
+
+// Match reports whether u matches at least one pattern in the set.
+func (s *PatternSet) Match(u URN) bool {
+	for _, p := range s.indexed[u.namespace][u.entityType] {
+		if p.Match(u) {
+			return true
+		}
+	}
+	for _, p := range s.wildcard {
+		if p.Match(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matching returns every pattern in the set that matches u.
+func (s *PatternSet) Matching(u URN) []*Pattern {
+	var matches []*Pattern
+	for _, p := range s.indexed[u.namespace][u.entityType] {
+		if p.Match(u) {
+			matches = append(matches, p)
+		}
+	}
+	for _, p := range s.wildcard {
+		if p.Match(u) {
+			matches = append(matches, p)
+		}
+	}
+	return matches
+}
+
+// --- glob matching ---
+
+type globKind int
+
+const (
+	globLiteral  globKind = iota // no wildcards: exact comparison
+	globAnything                 // "*": matches everything
+	globPrefix                   // "abc*"
+	globSuffix                   // "*abc"
+	globGeneral                  // anything else: token-by-token matching
+)
+
+const (
+	tokenStar = -1
+	tokenAny  = -2
+)
+
+// glob is a compiled glob expression. tokens holds either a literal byte
+// value or one of tokenStar/tokenAny.
+type glob struct {
+	kind    globKind
+	literal string
+	tokens  []int
+}
+
+// compileGlob compiles an escaped glob expression, decoding percent-encoded
+// octets into literal bytes. If fold is set, literal ASCII letters are
+// lower-cased to match the canonical form of namespaces and entity types.
+func compileGlob(expr string, fold bool) (glob, error) {
+	if expr == "" {
+		return glob{}, fmt.Errorf("%w: empty pattern component", ErrInvalidFormat)
+	}
+
+	var tokens []int
+	stars, anys := 0, 0
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; c {
+		case '*':
+			if len(tokens) > 0 && tokens[len(tokens)-1] == tokenStar {
+				continue // "**" is equivalent to "*"
+			}
+			tokens = append(tokens, tokenStar)
+			stars++
+		case '?':
+			tokens = append(tokens, tokenAny)
+			anys++
+		case '%':
+			if i+2 >= len(expr) || !isHex(expr[i+1]) || !isHex(expr[i+2]) {
+				return glob{}, fmt.Errorf("%w: invalid percent-encoding in pattern '%s'", ErrInvalidFormat, expr)
+			}
+			tokens = append(tokens, int(unhex(expr[i+1])<<4|unhex(expr[i+2])))
+			i += 2
+		default:
+			tokens = append(tokens, int(c))
+		}
+	}
+
+	if fold {
+		for i, t := range tokens {
+			if t >= 0 {
+				tokens[i] = int(toLowerASCII(byte(t)))
+			}
+		}
+	}
+
+	g := glob{tokens: tokens}
+	switch {
+	case stars == 0 && anys == 0:
+		g.kind, g.literal = globLiteral, literalOf(tokens)
+	case anys == 0 && stars == 1 && len(tokens) == 1:
+		g.kind = globAnything
+	case anys == 0 && stars == 1 && tokens[len(tokens)-1] == tokenStar:
+		g.kind, g.literal = globPrefix, literalOf(tokens[:len(tokens)-1])
+	case anys == 0 && stars == 1 && tokens[0] == tokenStar:
+		g.kind, g.literal = globSuffix, literalOf(tokens[1:])
+	default:
+		g.kind = globGeneral
+	}
+	return g, nil
+}
+
+func literalOf(tokens []int) string {
+	b := make([]byte, len(tokens))
+	for i, t := range tokens {
+		b[i] = byte(t)
+	}
+	return string(b)
+}
+
+func (g glob) isLiteral() bool {
+	return g.kind == globLiteral
+}
+
+func (g glob) match(s string) bool {
+	switch g.kind {
+	case globLiteral:
+		return s == g.literal
+	case globAnything:
+		return true
+	case globPrefix:
+		return strings.HasPrefix(s, g.literal)
+	case globSuffix:
+		return strings.HasSuffix(s, g.literal)
+	}
+
+	// Iterative wildcard matching: on a mismatch, backtrack to the most
+	// recent '*' and let it absorb one more character.
+	t, i := 0, 0
+	starT, starI := -1, 0
+	for i < len(s) {
+		if t < len(g.tokens) {
+			switch tok := g.tokens[t]; {
+			case tok == tokenStar:
+				starT, starI = t, i
+				t++
+				continue
+			case tok == tokenAny:
+				_, size := utf8.DecodeRuneInString(s[i:])
+				i += size
+				t++
+				continue
+			case byte(tok) == s[i]:
+				i++
+				t++
+				continue
+			}
+		}
+		if starT < 0 {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(s[starI:])
+		starI += size
+		t, i = starT+1, starI
+	}
+	for t < len(g.tokens) && g.tokens[t] == tokenStar {
+		t++
+	}
+	return t == len(g.tokens)
+}
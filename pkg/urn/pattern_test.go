@@ -0,0 +1,156 @@
+package urn_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternMatch(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pattern string
+		input   string
+		match   bool
+	}{
+		{name: "Exact", pattern: "urn:sm:user:alice", input: "urn:sm:user:alice", match: true},
+		{name: "Exact mismatch", pattern: "urn:sm:user:alice", input: "urn:sm:user:bob", match: false},
+		{name: "Any group", pattern: "urn:sm:group:*", input: "urn:sm:group:g-1", match: true},
+		{name: "Any group rejects user", pattern: "urn:sm:group:*", input: "urn:sm:user:g-1", match: false},
+		{name: "Any type, ID prefix", pattern: "urn:sm:*:admin-*", input: "urn:sm:user:admin-alice", match: true},
+		{name: "Any type, ID prefix mismatch", pattern: "urn:sm:*:admin-*", input: "urn:sm:user:alice", match: false},
+		{name: "ID suffix", pattern: "urn:sm:user:*@example.com", input: "urn:sm:user:alice@example.com", match: true},
+		{name: "Any namespace", pattern: "urn:*:user:alice", input: "urn:other:user:alice", match: true},
+		{name: "Namespace prefix mismatch", pattern: "urn:s*:user:alice", input: "urn:xs:user:alice", match: false},
+		{name: "Infix star", pattern: "urn:sm:user:a*e", input: "urn:sm:user:alice", match: true},
+		{name: "Multiple stars", pattern: "urn:sm:user:*li*e*", input: "urn:sm:user:alice", match: true},
+		{name: "Star needs backtracking", pattern: "urn:sm:user:*ab", input: "urn:sm:user:aabab", match: true},
+		{name: "Question mark", pattern: "urn:sm:user:b?b", input: "urn:sm:user:bob", match: true},
+		{name: "Question mark is one character", pattern: "urn:sm:user:b?b", input: "urn:sm:user:boob", match: false},
+		{name: "Question mark matches a rune", pattern: "urn:sm:user:zo?", input: "urn:sm:user:zo%C3%AB", match: true},
+		{name: "Escaped star is literal", pattern: "urn:sm:user:a%2A", input: "urn:sm:user:a*", match: true},
+		{name: "Escaped star does not glob", pattern: "urn:sm:user:a%2A", input: "urn:sm:user:abc", match: false},
+		{name: "Escaped colon", pattern: "urn:sm:user:*%3Awork", input: "urn:sm:user:alice%3Awork", match: true},
+		{name: "Depth must match", pattern: "urn:sm:user:*", input: "urn:sm:user:alice/device:phone1", match: false},
+		{name: "Hierarchical", pattern: "urn:sm:user:*/device:phone*", input: "urn:sm:user:alice/device:phone1", match: true},
+		{name: "Hierarchical parent mismatch", pattern: "urn:sm:user:bob/device:*", input: "urn:sm:user:alice/device:phone1", match: false},
+		{name: "Invalid URN", pattern: "urn:sm:*:*", input: "not-a-urn", match: false},
+		{name: "Type is case-insensitive", pattern: "urn:SM:User:alice", input: "urn:sm:USER:alice", match: true},
+		{name: "Escaped type is case-insensitive", pattern: "urn:sm:us%45r:*", input: "urn:sm:user:alice", match: true},
+		{name: "ID is case-sensitive", pattern: "urn:sm:user:Alice", input: "urn:sm:user:alice", match: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := urn.CompilePattern(tc.pattern)
+			require.NoError(t, err)
+			assert.Equal(t, tc.pattern, p.String())
+			assert.Equal(t, tc.match, p.MatchString(tc.input))
+		})
+	}
+
+	t.Run("Zero URN never matches", func(t *testing.T) {
+		assert.False(t, urn.MustCompilePattern("urn:*:*:*").Match(urn.URN{}))
+	})
+}
+
+func TestCompilePatternErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"sm:user:*",
+		"urn:sm",
+		"urn:sm:user",
+		"urn:sm::*",
+		"urn:sm:user:",
+		"urn:sm:user:a:b",
+		"urn:sm:user:*/device",
+		"urn:sm:user:%zz",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := urn.CompilePattern(expr)
+			assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+		})
+	}
+	assert.Panics(t, func() { urn.MustCompilePattern("bad") })
+}
+
+func TestPatternSet(t *testing.T) {
+	set, err := urn.NewPatternSet(
+		"urn:sm:group:*",
+		"urn:sm:user:admin-*",
+		"urn:sm:*:root",
+		"urn:sm:user:*/device:*",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 4, set.Len())
+
+	testCases := []struct {
+		input    string
+		match    bool
+		matching []string
+	}{
+		{input: "urn:sm:group:g1", match: true, matching: []string{"urn:sm:group:*"}},
+		{input: "urn:sm:user:admin-alice", match: true, matching: []string{"urn:sm:user:admin-*"}},
+		{input: "urn:sm:user:root", match: true, matching: []string{"urn:sm:*:root"}},
+		{input: "urn:sm:group:root", match: true, matching: []string{"urn:sm:group:*", "urn:sm:*:root"}},
+		{input: "urn:sm:user:alice/device:phone1", match: true, matching: []string{"urn:sm:user:*/device:*"}},
+		{input: "urn:sm:user:alice", match: false},
+		{input: "urn:other:group:g1", match: false},
+		{input: "urn:sm:GROUP:g1", match: true, matching: []string{"urn:sm:group:*"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			u, err := urn.Parse(tc.input)
+			require.NoError(t, err)
+			assert.Equal(t, tc.match, set.Match(u))
+
+			var matching []string
+			for _, p := range set.Matching(u) {
+				matching = append(matching, p.String())
+			}
+			assert.Equal(t, tc.matching, matching)
+		})
+	}
+
+	t.Run("Invalid pattern", func(t *testing.T) {
+		_, err := urn.NewPatternSet("urn:sm:group:*", "bad")
+		assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+	})
+
+	t.Run("Zero-value set", func(t *testing.T) {
+		var s urn.PatternSet
+		s.Add(urn.MustCompilePattern("urn:sm:group:*"))
+		assert.True(t, s.Match(mustParse(t, "urn:sm:group:g1")))
+	})
+}
+
+func BenchmarkPatternSetMatch(b *testing.B) {
+	exprs := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		exprs = append(exprs, fmt.Sprintf("urn:sm:group:team-%d-*", i))
+	}
+	exprs = append(exprs, "urn:sm:*:admin-*")
+	set, err := urn.NewPatternSet(exprs...)
+	require.NoError(b, err)
+	u, err := urn.Parse("urn:sm:user:admin-alice")
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !set.Match(u) {
+			b.Fatal("expected match")
+		}
+	}
+}
+
+func mustParse(t testing.TB, s string) urn.URN {
+	t.Helper()
+	u, err := urn.Parse(s)
+	require.NoError(t, err)
+	return u
+}
@@ -0,0 +1,257 @@
+package urn
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnregisteredType is returned by a Registry when a URN uses an entity
+	// type that has not been registered.
+	ErrUnregisteredType = errors.New("unregistered entity type")
+	// ErrInvalidEntityID is returned by a Registry when an entity ID is
+	// rejected by the validator registered for its type.
+	ErrInvalidEntityID = errors.New("invalid entity ID")
+	// ErrAlreadyRegistered is returned when an entity type is registered twice.
+	ErrAlreadyRegistered = errors.New("entity type already registered")
+)
+
+// IDValidator checks that an entity ID is well formed for its entity type.
+type IDValidator func(entityID string) error
+
+// EntityError describes a URN that was rejected by a Registry. Use
+// errors.Is with ErrUnregisteredType or ErrInvalidEntityID to tell the
+// failure modes apart.
+type EntityError struct {
+	EntityType string
+	EntityID   string
+	Err        error
+}
+
+func (e *EntityError) Error() string {
+	return fmt.Sprintf("urn entity %s:%s: %v", e.EntityType, e.EntityID, e.Err)
+}
+
+func (e *EntityError) Unwrap() error {
+	return e.Err
+}
+
+// Registry maps entity types to the validators for their IDs. It is safe for
+// concurrent use, so a service can populate one at start-up and share it
+// across handlers.
+type Registry struct {
+	mu         sync.RWMutex
+	validators map[string]IDValidator
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{validators: make(map[string]IDValidator)}
+}
+
+// Register adds an entity type and the validator for its IDs. A nil
+// validator accepts any non-empty ID. Entity types are case-insensitive, as
+// in URNs.
+func (r *Registry) Register(entityType string, validator IDValidator) error {
+	if err := validateEntityType(entityType); err != nil {
+		return err
+	}
+	entityType = lowerASCII(entityType)
+	if validator == nil {
+		validator = AnyID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.validators[entityType]; exists {
+		return fmt.Errorf("%w: %s", ErrAlreadyRegistered, entityType)
+	}
+	r.validators[entityType] = validator
+	return nil
+}
+
+// MustRegister is like Register but panics on error. It is intended for
+// package-level initialization.
+func (r *Registry) MustRegister(entityType string, validator IDValidator) {
+	if err := r.Register(entityType, validator); err != nil {
+		panic(err)
+	}
+}
+
+// IsRegistered reports whether entityType has been registered.
+func (r *Registry) IsRegistered(entityType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.validators[lowerASCII(entityType)]
+	return ok
+}
+
+// Types returns the registered entity types in sorted order.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.validators))
+	for t := range r.validators {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Validate checks that u has a registered entity type and that its ID is
+// accepted by that type's validator. Every segment of a hierarchical URN is
+// checked. A zero-value URN is always valid.
+func (r *Registry) Validate(u URN) error {
+	if u.IsZero() {
+		return nil
+	}
+	for _, a := range u.Ancestors() {
+		if err := r.validateSegment(a); err != nil {
+			return err
+		}
+	}
+	return r.validateSegment(u)
+}
+
+func (r *Registry) validateSegment(u URN) error {
+	r.mu.RLock()
+	validator, ok := r.validators[u.entityType]
+	r.mu.RUnlock()
+
+	if !ok {
+		return &EntityError{EntityType: u.entityType, EntityID: u.entityID, Err: ErrUnregisteredType}
+	}
+	if err := validator(u.entityID); err != nil {
+		return &EntityError{EntityType: u.entityType, EntityID: u.entityID, Err: fmt.Errorf("%w: %w", ErrInvalidEntityID, err)}
+	}
+	return nil
+}
+
+// New constructs a URN like the package-level New and then validates it
+// against the registry.
+func (r *Registry) New(namespace, entityType, entityID string) (URN, error) {
+	u, err := New(namespace, entityType, entityID)
+	if err != nil {
+		return URN{}, err
+	}
+	if err := r.Validate(u); err != nil {
+		return URN{}, err
+	}
+	return u, nil
+}
+
+// Parse is the strict counterpart of the package-level Parse. In addition to
+// the format checks it rejects URNs whose entity type is unregistered or
+// whose ID fails validation.
+func (r *Registry) Parse(s string) (URN, error) {
+	u, err := Parse(s)
+	if err != nil {
+		return URN{}, err
+	}
+	if err := r.Validate(u); err != nil {
+		return URN{}, err
+	}
+	return u, nil
+}
+
+// --- ID validators ---
+
+const (
+	uuidLength = 36
+	ulidLength = 26
+	// crockfordAlphabet is the Base32 alphabet used by ULIDs.
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// AnyID accepts any non-empty entity ID.
+func AnyID(entityID string) error {
+	if entityID == "" {
+		return errors.New("must not be empty")
+	}
+	return nil
+}
+
+// UUID accepts IDs in the canonical 8-4-4-4-12 hexadecimal UUID form.
+func UUID(entityID string) error {
+	if len(entityID) != uuidLength {
+		return fmt.Errorf("UUID must be %d characters, got %d", uuidLength, len(entityID))
+	}
+	for i := 0; i < len(entityID); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if entityID[i] != '-' {
+				return fmt.Errorf("UUID must have '-' at position %d", i)
+			}
+		default:
+			if !isHex(entityID[i]) {
+				return fmt.Errorf("UUID contains non-hex character %q", entityID[i])
+			}
+		}
+	}
+	return nil
+}
+
+// ULID accepts 26-character Crockford Base32 ULIDs, in either case.
+func ULID(entityID string) error {
+	if len(entityID) != ulidLength {
+		return fmt.Errorf("ULID must be %d characters, got %d", ulidLength, len(entityID))
+	}
+	// The first character carries only the top 3 bits of the 48-bit timestamp.
+	if entityID[0] > '7' {
+		return errors.New("ULID timestamp overflows 48 bits")
+	}
+	for i := 0; i < len(entityID); i++ {
+		if strings.IndexByte(crockfordAlphabet, upperASCII(entityID[i])) < 0 {
+			return fmt.Errorf("ULID contains invalid character %q", entityID[i])
+		}
+	}
+	return nil
+}
+
+// Numeric accepts unsigned decimal IDs that fit in 64 bits, without
+// leading zeros.
+func Numeric(entityID string) error {
+	if len(entityID) > 1 && entityID[0] == '0' {
+		return errors.New("numeric ID must not have leading zeros")
+	}
+	if _, err := strconv.ParseUint(entityID, 10, 64); err != nil {
+		return fmt.Errorf("numeric ID: %w", err)
+	}
+	return nil
+}
+
+// MatchRegexp returns a validator that accepts IDs matching expr in full. The
+// expression is anchored automatically.
+func MatchRegexp(expr string) (IDValidator, error) {
+	re, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return nil, err
+	}
+	return func(entityID string) error {
+		if !re.MatchString(entityID) {
+			return fmt.Errorf("does not match %s", expr)
+		}
+		return nil
+	}, nil
+}
+
+// MustMatchRegexp is like MatchRegexp but panics if expr does not compile.
+func MustMatchRegexp(expr string) IDValidator {
+	v, err := MatchRegexp(expr)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func upperASCII(c byte) byte {
+	if 'a' <= c && c <= 'z' {
+		return c - ('a' - 'A')
+	}
+	return c
+}
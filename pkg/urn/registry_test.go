@@ -0,0 +1,112 @@
+package urn_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegistry(t *testing.T) *urn.Registry {
+	t.Helper()
+	r := urn.NewRegistry()
+	require.NoError(t, r.Register(urn.EntityTypeUser, urn.UUID))
+	require.NoError(t, r.Register(urn.EntityTypeMessage, urn.ULID))
+	require.NoError(t, r.Register(urn.EntityTypeKey, urn.Numeric))
+	require.NoError(t, r.Register(urn.EntityTypeDevice, urn.MustMatchRegexp(`[a-z]+[0-9]*`)))
+	require.NoError(t, r.Register(urn.EntityTypeGroup, nil))
+	return r
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := newTestRegistry(t)
+
+	t.Run("Duplicate", func(t *testing.T) {
+		err := r.Register(urn.EntityTypeUser, urn.AnyID)
+		assert.ErrorIs(t, err, urn.ErrAlreadyRegistered)
+	})
+
+	t.Run("Invalid Entity Type", func(t *testing.T) {
+		err := r.Register("bad type", urn.AnyID)
+		assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+	})
+
+	t.Run("Types", func(t *testing.T) {
+		assert.Equal(t, []string{"device", "group", "key", "message", "user"}, r.Types())
+		assert.True(t, r.IsRegistered(urn.EntityTypeUser))
+		assert.False(t, r.IsRegistered(urn.EntityTypeConversation))
+		assert.True(t, r.IsRegistered("User"), "entity types are case-insensitive")
+		assert.ErrorIs(t, r.Register("USER", urn.AnyID), urn.ErrAlreadyRegistered)
+	})
+
+	t.Run("MustRegister panics on duplicate", func(t *testing.T) {
+		assert.Panics(t, func() { r.MustRegister(urn.EntityTypeUser, nil) })
+	})
+}
+
+func TestRegistryParse(t *testing.T) {
+	r := newTestRegistry(t)
+
+	testCases := []struct {
+		name          string
+		input         string
+		expectedErrIs error
+	}{
+		{name: "Valid UUID user", input: "urn:sm:user:0f8fad5b-d9cb-469f-a165-70867728950e"},
+		{name: "Valid ULID message", input: "urn:sm:message:01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+		{name: "Lower-case ULID", input: "urn:sm:message:01arz3ndektsv4rrffq69g5fav"},
+		{name: "Valid numeric key", input: "urn:sm:key:42"},
+		{name: "Valid regexp device", input: "urn:sm:device:phone1"},
+		{name: "Any group ID", input: "urn:sm:group:anything-goes"},
+		{name: "Empty string", input: ""},
+		{name: "Unregistered type", input: "urn:sm:conversation:c1", expectedErrIs: urn.ErrUnregisteredType},
+		{name: "Malformed UUID", input: "urn:sm:user:user-123", expectedErrIs: urn.ErrInvalidEntityID},
+		{name: "UUID without hyphens", input: "urn:sm:user:0f8fad5bd9cb469fa16570867728950e", expectedErrIs: urn.ErrInvalidEntityID},
+		{name: "ULID with invalid character", input: "urn:sm:message:01ARZ3NDEKTSV4RRFFQ69G5FAU", expectedErrIs: urn.ErrInvalidEntityID},
+		{name: "ULID overflow", input: "urn:sm:message:81ARZ3NDEKTSV4RRFFQ69G5FAV", expectedErrIs: urn.ErrInvalidEntityID},
+		{name: "Numeric with leading zero", input: "urn:sm:key:042", expectedErrIs: urn.ErrInvalidEntityID},
+		{name: "Numeric overflow", input: "urn:sm:key:18446744073709551616", expectedErrIs: urn.ErrInvalidEntityID},
+		{name: "Regexp partial match", input: "urn:sm:device:phone1-x", expectedErrIs: urn.ErrInvalidEntityID},
+		{name: "Malformed URN", input: "urn:sm:user", expectedErrIs: urn.ErrInvalidFormat},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := r.Parse(tc.input)
+			if tc.expectedErrIs == nil {
+				require.NoError(t, err)
+				assert.Equal(t, tc.input, u.String())
+				return
+			}
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tc.expectedErrIs)
+		})
+	}
+}
+
+func TestRegistryEntityError(t *testing.T) {
+	r := newTestRegistry(t)
+
+	_, err := r.New(urn.SecureMessaging, urn.EntityTypeUser, "not-a-uuid")
+	require.Error(t, err)
+
+	var entityErr *urn.EntityError
+	require.True(t, errors.As(err, &entityErr))
+	assert.Equal(t, urn.EntityTypeUser, entityErr.EntityType)
+	assert.Equal(t, "not-a-uuid", entityErr.EntityID)
+	assert.ErrorIs(t, err, urn.ErrInvalidEntityID)
+
+	// URNs built without the registry can still be checked later.
+	u, err := urn.New(urn.SecureMessaging, urn.EntityTypeConversation, "c1")
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.Validate(u), urn.ErrUnregisteredType)
+	assert.NoError(t, r.Validate(urn.URN{}))
+}
+
+func TestMatchRegexpInvalid(t *testing.T) {
+	_, err := urn.MatchRegexp("[")
+	assert.Error(t, err)
+	assert.Panics(t, func() { urn.MustMatchRegexp("[") })
+}
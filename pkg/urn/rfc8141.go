@@ -0,0 +1,263 @@
+package urn
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	nidMinLength = 2
+	nidMaxLength = 32
+	rPrefix      = "?+"
+	qPrefix      = "?="
+	fPrefix      = "#"
+)
+
+// Name is a URN broken into the syntactic components defined by RFC 8141.
+// Unlike URN it places no constraints on the shape of the NSS, so it can
+// represent any URN in any namespace.
+type Name struct {
+	// NID is the namespace identifier, e.g. "sm".
+	NID string
+	// NSS is the namespace specific string, kept in its encoded form.
+	NSS string
+	// RComponent holds the resolution parameters that followed "?+", if any.
+	RComponent string
+	// QComponent holds the query parameters that followed "?=", if any.
+	QComponent string
+	// FComponent holds the fragment that followed "#", if any.
+	FComponent string
+}
+
+// ParseName parses s as an RFC 8141 namestring, validating the NID syntax,
+// the characters and percent-encoding of every component, and the order of
+// the optional r-, q- and f-components.
+func ParseName(s string) (Name, error) {
+	var n Name
+
+	if len(s) <= len(Scheme) || !strings.EqualFold(s[:len(Scheme)], Scheme) || s[len(Scheme)] != ':' {
+		return Name{}, fmt.Errorf("%w: missing '%s:' scheme", ErrInvalidFormat, Scheme)
+	}
+	rest := s[len(Scheme)+1:]
+
+	if i := strings.Index(rest, fPrefix); i >= 0 {
+		n.FComponent = rest[i+len(fPrefix):]
+		if err := validateComponent(n.FComponent, "f-component", true, true); err != nil {
+			return Name{}, err
+		}
+		rest = rest[:i]
+	}
+
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		rq := rest[i:]
+		rest = rest[:i]
+		if strings.HasPrefix(rq, rPrefix) {
+			rq = rq[len(rPrefix):]
+			end := strings.Index(rq, qPrefix)
+			if end < 0 {
+				end = len(rq)
+			}
+			n.RComponent = rq[:end]
+			if err := validateComponent(n.RComponent, "r-component", false, true); err != nil {
+				return Name{}, err
+			}
+			rq = rq[end:]
+		}
+		if rq != "" {
+			if !strings.HasPrefix(rq, qPrefix) {
+				return Name{}, fmt.Errorf("%w: expected '%s' or '%s' after the NSS", ErrInvalidFormat, rPrefix, qPrefix)
+			}
+			n.QComponent = rq[len(qPrefix):]
+			if err := validateComponent(n.QComponent, "q-component", false, true); err != nil {
+				return Name{}, err
+			}
+		}
+	}
+
+	nid, nss, ok := strings.Cut(rest, urnDelimiter)
+	if !ok {
+		return Name{}, fmt.Errorf("%w: missing namespace specific string", ErrInvalidFormat)
+	}
+	if err := validateNID(nid); err != nil {
+		return Name{}, err
+	}
+	if err := validateComponent(nss, "NSS", false, false); err != nil {
+		return Name{}, err
+	}
+	n.NID = nid
+	n.NSS = nss
+	return n, nil
+}
+
+// ValidateRFC8141 reports whether s is a syntactically valid RFC 8141 URN.
+func ValidateRFC8141(s string) error {
+	_, err := ParseName(s)
+	return err
+}
+
+// String reassembles the name into its RFC 8141 string form.
+func (n Name) String() string {
+	var b strings.Builder
+	b.WriteString(Scheme)
+	b.WriteString(urnDelimiter)
+	b.WriteString(n.NID)
+	b.WriteString(urnDelimiter)
+	b.WriteString(n.NSS)
+	if n.RComponent != "" {
+		b.WriteString(rPrefix)
+		b.WriteString(n.RComponent)
+	}
+	if n.QComponent != "" {
+		b.WriteString(qPrefix)
+		b.WriteString(n.QComponent)
+	}
+	if n.FComponent != "" {
+		b.WriteString(fPrefix)
+		b.WriteString(n.FComponent)
+	}
+	return b.String()
+}
+
+// Equivalent reports whether two names are URN-equivalent as defined in
+// RFC 8141 section 3: the NID is compared case-insensitively, the hex digits
+// of percent-encoded octets are compared case-insensitively, the NSS is
+// otherwise compared exactly, and the r-, q- and f-components are ignored.
+func (n Name) Equivalent(other Name) bool {
+	if !strings.EqualFold(n.NID, other.NID) || len(n.NSS) != len(other.NSS) {
+		return false
+	}
+	for i := 0; i < len(n.NSS); i++ {
+		a, b := n.NSS[i], other.NSS[i]
+		if a == b {
+			continue
+		}
+		// Only the two hex digits following a '%' may differ in case.
+		inPct := (i >= 1 && n.NSS[i-1] == '%') || (i >= 2 && n.NSS[i-2] == '%')
+		if !inPct || toLowerASCII(a) != toLowerASCII(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRFC8141 is a strict alternative to Parse. It validates s against
+// RFC 8141, requires each segment of the NSS to have the form
+// "<entity type>:<entity ID>" and, unlike Parse, allows the entity ID to
+// contain further literal colons.
+// Percent-encoded octets in the entity ID are decoded and the scheme, NID and
+// entity types are normalized to lower case. Any r-, q- or f-components are
+// validated and then discarded, since they do not take part in URN
+// equivalence.
+func ParseRFC8141(s string) (URN, error) {
+	if s == "" {
+		return URN{}, nil
+	}
+	n, err := ParseName(s)
+	if err != nil {
+		return URN{}, err
+	}
+	u, err := parseNSS(strings.ToLower(n.NID), n.NSS, true)
+	if err != nil {
+		return URN{}, err
+	}
+	for _, a := range append(u.Ancestors(), u) {
+		if err := validateEntityType(a.entityType); err != nil {
+			return URN{}, err
+		}
+	}
+	return u, nil
+}
+
+// validateNID checks the NID production: 2 to 32 characters drawn from
+// letters, digits and hyphens, beginning and ending with a letter or digit.
+func validateNID(nid string) error {
+	if len(nid) < nidMinLength || len(nid) > nidMaxLength {
+		return fmt.Errorf("%w: NID '%s' must be %d to %d characters", ErrInvalidFormat, nid, nidMinLength, nidMaxLength)
+	}
+	if !isAlphaNum(nid[0]) || !isAlphaNum(nid[len(nid)-1]) {
+		return fmt.Errorf("%w: NID '%s' must begin and end with a letter or digit", ErrInvalidFormat, nid)
+	}
+	for i := 1; i < len(nid)-1; i++ {
+		if !isAlphaNum(nid[i]) && nid[i] != '-' {
+			return fmt.Errorf("%w: invalid character %q in NID '%s'", ErrInvalidFormat, nid[i], nid)
+		}
+	}
+	return nil
+}
+
+// validateEntityType checks that an entity type uses the same
+// letter-digit-hyphen alphabet as an NID.
+func validateEntityType(entityType string) error {
+	if entityType == "" {
+		return fmt.Errorf("%w: entity type cannot be empty", ErrInvalidFormat)
+	}
+	for i := 0; i < len(entityType); i++ {
+		if !isAlphaNum(entityType[i]) && entityType[i] != '-' {
+			return fmt.Errorf("%w: invalid character %q in entity type '%s'", ErrInvalidFormat, entityType[i], entityType)
+		}
+	}
+	return nil
+}
+
+// validateComponent checks that s is a sequence of pchars and valid
+// percent-encoded octets. "/" is allowed anywhere except as the first
+// character of the NSS, and "?" only where allowQuery is set.
+func validateComponent(s, name string, allowEmpty, allowQuery bool) error {
+	if s == "" {
+		if allowEmpty {
+			return nil
+		}
+		return fmt.Errorf("%w: %s cannot be empty", ErrInvalidFormat, name)
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return fmt.Errorf("%w: invalid percent-encoding in %s '%s'", ErrInvalidFormat, name, s)
+			}
+			i += 2
+		case c == '/':
+			if i == 0 && name == "NSS" {
+				return fmt.Errorf("%w: NSS cannot begin with '/'", ErrInvalidFormat)
+			}
+		case c == '?':
+			if !allowQuery {
+				return fmt.Errorf("%w: invalid character '?' in %s '%s'", ErrInvalidFormat, name, s)
+			}
+		case !isPChar(c):
+			return fmt.Errorf("%w: invalid character %q in %s '%s'", ErrInvalidFormat, c, name, s)
+		}
+	}
+	return nil
+}
+
+// isPChar reports whether c is an RFC 3986 pchar other than a
+// percent-encoded octet: unreserved, sub-delims, ":" or "@".
+func isPChar(c byte) bool {
+	if isAlphaNum(c) {
+		return true
+	}
+	switch c {
+	case '-', '.', '_', '~', // unreserved
+		'!', '$', '&', '\'', '(', ')', '*', '+', ',', ';', '=', // sub-delims
+		':', '@':
+		return true
+	}
+	return false
+}
+
+func isAlphaNum(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func toLowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
@@ -0,0 +1,163 @@
+package urn_test
+
+import (
+	"testing"
+
+	"github.com/illmade-knight/go-secure-messaging/pkg/urn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseName(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     string
+		expected  urn.Name
+		expectErr bool
+	}{
+		{
+			name:     "Canonical SM URN",
+			input:    "urn:sm:user:user-123",
+			expected: urn.Name{NID: "sm", NSS: "user:user-123"},
+		},
+		{
+			name:     "Upper-case scheme and NID",
+			input:    "URN:SM:user:user-123",
+			expected: urn.Name{NID: "SM", NSS: "user:user-123"},
+		},
+		{
+			name:     "All components",
+			input:    "urn:example:a/b%2Fc?+res=1?=q=2?x#frag/ment",
+			expected: urn.Name{NID: "example", NSS: "a/b%2Fc", RComponent: "res=1", QComponent: "q=2?x", FComponent: "frag/ment"},
+		},
+		{
+			name:     "Q-component only",
+			input:    "urn:example:thing?=lang=en",
+			expected: urn.Name{NID: "example", NSS: "thing", QComponent: "lang=en"},
+		},
+		{name: "Missing scheme", input: "sm:user:user-123", expectErr: true},
+		{name: "Wrong scheme", input: "foo:sm:user:user-123", expectErr: true},
+		{name: "Missing NSS", input: "urn:sm", expectErr: true},
+		{name: "Empty NSS", input: "urn:sm:", expectErr: true},
+		{name: "NID too short", input: "urn:s:user:1", expectErr: true},
+		{name: "NID too long", input: "urn:abcdefghijklmnopqrstuvwxyz0123456:x", expectErr: true},
+		{name: "NID ends in hyphen", input: "urn:sm-:user:1", expectErr: true},
+		{name: "NID invalid character", input: "urn:s_m:user:1", expectErr: true},
+		{name: "NSS starts with slash", input: "urn:sm:/user", expectErr: true},
+		{name: "NSS invalid character", input: "urn:sm:user:a b", expectErr: true},
+		{name: "Truncated percent-encoding", input: "urn:sm:user:a%2", expectErr: true},
+		{name: "Invalid percent-encoding", input: "urn:sm:user:a%zz", expectErr: true},
+		{name: "Bare question mark", input: "urn:sm:user:a?b", expectErr: true},
+		{name: "Empty r-component", input: "urn:sm:user:a?+", expectErr: true},
+		{name: "Empty q-component", input: "urn:sm:user:a?=", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := urn.ParseName(tc.input)
+			if tc.expectErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+				assert.ErrorIs(t, urn.ValidateRFC8141(tc.input), urn.ErrInvalidFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, n)
+			assert.NoError(t, urn.ValidateRFC8141(tc.input))
+		})
+	}
+}
+
+func TestNameString(t *testing.T) {
+	in := "urn:example:a/b?+r?=q#f"
+	n, err := urn.ParseName(in)
+	require.NoError(t, err)
+	assert.Equal(t, in, n.String())
+}
+
+func TestNameEquivalent(t *testing.T) {
+	testCases := []struct {
+		name       string
+		a, b       string
+		equivalent bool
+	}{
+		{name: "Identical", a: "urn:sm:user:alice", b: "urn:sm:user:alice", equivalent: true},
+		{name: "Scheme and NID case", a: "URN:SM:user:alice", b: "urn:sm:user:alice", equivalent: true},
+		{name: "Percent-encoding case", a: "urn:sm:user:a%3Ab", b: "urn:sm:user:a%3ab", equivalent: true},
+		{name: "Components ignored", a: "urn:sm:user:alice?+r?=q#f", b: "urn:sm:user:alice", equivalent: true},
+		{name: "NSS case is significant", a: "urn:sm:user:Alice", b: "urn:sm:user:alice", equivalent: false},
+		{name: "Encoded and literal differ", a: "urn:sm:user:a%3Ab", b: "urn:sm:user:a:b", equivalent: false},
+		{name: "Different NID", a: "urn:sm:user:alice", b: "urn:xx:user:alice", equivalent: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := urn.ParseName(tc.a)
+			require.NoError(t, err)
+			b, err := urn.ParseName(tc.b)
+			require.NoError(t, err)
+			assert.Equal(t, tc.equivalent, a.Equivalent(b))
+			assert.Equal(t, tc.equivalent, b.Equivalent(a))
+		})
+	}
+}
+
+func TestParseRFC8141(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		expectedURN string
+		expectedID  string
+		expectErr   bool
+	}{
+		{
+			name:        "Canonical form",
+			input:       "urn:sm:user:user-123",
+			expectedURN: "urn:sm:user:user-123",
+			expectedID:  "user-123",
+		},
+		{
+			name:        "Colon in entity ID",
+			input:       "urn:sm:user:alice@example.com:work",
+			expectedURN: "urn:sm:user:alice@example.com%3Awork",
+			expectedID:  "alice@example.com:work",
+		},
+		{
+			name:        "Percent-encoded entity ID",
+			input:       "urn:sm:user:a%2fb",
+			expectedURN: "urn:sm:user:a%2Fb",
+			expectedID:  "a/b",
+		},
+		{
+			name:        "Normalizes scheme and NID",
+			input:       "URN:SM:user:user-123",
+			expectedURN: "urn:sm:user:user-123",
+			expectedID:  "user-123",
+		},
+		{
+			name:        "Discards components",
+			input:       "urn:sm:user:user-123?=v=1#top",
+			expectedURN: "urn:sm:user:user-123",
+			expectedID:  "user-123",
+		},
+		{name: "Empty string", input: ""},
+		{name: "No entity type", input: "urn:sm:user-123", expectErr: true},
+		{name: "Empty entity ID", input: "urn:sm:user:", expectErr: true},
+		{name: "Invalid entity type", input: "urn:sm:us.er:user-123", expectErr: true},
+		{name: "Invalid NID", input: "urn:s:user:user-123", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := urn.ParseRFC8141(tc.input)
+			if tc.expectErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, urn.ErrInvalidFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedURN, u.String())
+			assert.Equal(t, tc.expectedID, u.EntityID())
+		})
+	}
+}
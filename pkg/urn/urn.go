@@ -4,6 +4,12 @@
 //
 // ADDED: This file is now updated to gracefully handle empty strings
 // and zero-value URNs, making ToProto/FromProto compatible.
+//
+// ADDED: String() now percent-encodes reserved characters in each component
+// and Parse() decodes them, so every URN accepted by New() round-trips.
+//
+// ADDED: URNs can be hierarchical (urn:sm:user:alice/device:phone1). See
+// hierarchy.go for Child(), Parent(), Ancestors() and IsDescendantOf().
 
 package urn
 
@@ -19,12 +25,22 @@ const (
 	Scheme = "urn"
 	// SecureMessaging is the required namespace for all URNs in the system.
 	SecureMessaging = "sm"
-	urnParts        = 4
 	urnDelimiter    = ":"
 	// EntityTypeUser is a standard entity type for users.
 	EntityTypeUser = "user"
 	// EntityTypeGroup is a standard entity type for groups.
 	EntityTypeGroup = "group"
+	// EntityTypeDevice is a standard entity type for a user's devices.
+	EntityTypeDevice = "device"
+	// EntityTypeConversation is a standard entity type for conversations.
+	EntityTypeConversation = "conversation"
+	// EntityTypeMessage is a standard entity type for individual messages.
+	EntityTypeMessage = "message"
+	// EntityTypeKey is a standard entity type for keys and key bundles.
+	EntityTypeKey = "key"
+	// EntityTypeBlob is a standard entity type for externally stored
+	// encrypted blobs, such as attachments.
+	EntityTypeBlob = "blob"
 )
 
 var (
@@ -37,14 +53,24 @@ var (
 // Its fields are unexported to ensure that all instances are created via the
 // validating New() constructor.
 type URN struct {
-	scheme     string
-	namespace  string
+	scheme    string
+	namespace string
+	// parent is the canonical, escaped path of the ancestor segments
+	// ("user:alice/device:phone1"), or "" for a top-level URN.
+	parent     string
 	entityType string
 	entityID   string
 }
 
 // New is the constructor for a URN. It validates that the provided namespace,
 // entity type, and ID are not empty, ensuring no invalid URNs can be created.
+// The components are stored unescaped; reserved characters such as ':' are
+// only percent-encoded when the URN is serialized by String().
+//
+// The namespace and entity type are case-insensitive and are lower-cased
+// (ASCII only) so that every URN is held in canonical form; the entity ID is
+// kept exactly as given. Two URNs for the same resource are therefore ==,
+// and can be used interchangeably as map keys.
 func New(namespace, entityType, entityID string) (URN, error) {
 	if namespace == "" {
 		return URN{}, fmt.Errorf("%w: namespace cannot be empty", ErrInvalidFormat)
@@ -58,8 +84,8 @@ func New(namespace, entityType, entityID string) (URN, error) {
 	// REFACTOR: Corrected the field assignments.
 	return URN{
 		scheme:     Scheme,
-		namespace:  namespace,
-		entityType: entityType,
+		namespace:  internKnown(lowerASCII(namespace)),
+		entityType: internKnown(lowerASCII(entityType)),
 		entityID:   entityID,
 	}, nil
 }
@@ -74,20 +100,28 @@ func Parse(s string) (URN, error) {
 		return URN{}, nil
 	}
 
-	parts := strings.Split(s, urnDelimiter)
-	if len(parts) != urnParts {
-		return URN{}, fmt.Errorf("%w: expected %d parts, but got %d", ErrInvalidFormat, urnParts, len(parts))
+	scheme, rest, _ := strings.Cut(s, urnDelimiter)
+	if scheme != Scheme {
+		return URN{}, fmt.Errorf("%w: invalid scheme '%s', expected '%s'", ErrInvalidFormat, scheme, Scheme)
 	}
 
-	if parts[0] != Scheme {
-		return URN{}, fmt.Errorf("%w: invalid scheme '%s', expected '%s'", ErrInvalidFormat, parts[0], Scheme)
+	namespace, nss, ok := strings.Cut(rest, urnDelimiter)
+	if !ok {
+		return URN{}, fmt.Errorf("%w: expected <namespace>:<type>:<id>, but got '%s'", ErrInvalidFormat, rest)
+	}
+	namespace, err := unescape(namespace)
+	if err != nil {
+		return URN{}, err
 	}
 
-	// Delegate final validation to the constructor.
-	return New(parts[1], parts[2], parts[3])
+	// Delegate segment parsing and final validation to parseNSS.
+	return parseNSS(namespace, nss, false)
 }
 
 // String reassembles the URN into its canonical string representation.
+// Reserved characters in the namespace, entity type and ID are
+// percent-encoded so that the result can always be read back by Parse.
+// Hierarchical URNs serialize their ancestors first, separated by '/'.
 //
 // FIXED: A zero-value URN now serializes to an empty string ""
 // instead of ":::". This is required for transport.ToProto.
@@ -95,22 +129,48 @@ func (u URN) String() string {
 	if u.IsZero() {
 		return ""
 	}
-	return strings.Join([]string{u.scheme, u.namespace, u.entityType, u.entityID}, urnDelimiter)
+	var b strings.Builder
+	b.Grow(len(u.scheme) + len(u.namespace) + len(u.parent) + len(u.entityType) + len(u.entityID) + 4)
+	b.WriteString(u.scheme)
+	b.WriteString(urnDelimiter)
+	writeEscaped(&b, u.namespace)
+	b.WriteString(urnDelimiter)
+	if u.parent != "" {
+		b.WriteString(u.parent)
+		b.WriteString(segmentDelimiter)
+	}
+	writeEscaped(&b, u.entityType)
+	b.WriteString(urnDelimiter)
+	writeEscaped(&b, u.entityID)
+	return b.String()
+}
+
+// Scheme returns the URN scheme, which is always "urn" for an initialized
+// URN and "" for the zero value.
+func (u URN) Scheme() string {
+	return u.scheme
+}
+
+// Namespace returns the namespace of the URN (e.g., "sm").
+func (u URN) Namespace() string {
+	return u.namespace
 }
 
-// EntityType returns the type of the entity (e.g., "user", "device").
+// EntityType returns the type of the entity (e.g., "user", "device"). For a
+// hierarchical URN this is the type of the last segment.
 func (u URN) EntityType() string {
 	return u.entityType
 }
 
-// EntityID returns the unique identifier for the entity.
+// EntityID returns the unique identifier for the entity. For a hierarchical
+// URN this is the ID of the last segment.
 func (u URN) EntityID() string {
 	return u.entityID
 }
 
 // IsZero returns true if the URN has not been initialized.
 func (u URN) IsZero() bool {
-	return u.scheme == "" && u.namespace == "" && u.entityType == "" && u.entityID == ""
+	return u.scheme == "" && u.namespace == "" && u.parent == "" && u.entityType == "" && u.entityID == ""
 }
 
 // MarshalJSON implements the json.Marshaler interface.
@@ -125,37 +185,16 @@ func (u URN) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
+//
+// Strings that do not start with "urn:" are legacy bare IDs. How they are
+// handled is controlled by the package default DecodeOptions; see
+// SetDefaultDecodeOptions. By default they are converted to user URNs. Use
+// Decoded to choose the handling for a single field.
 func (u *URN) UnmarshalJSON(data []byte) error {
-	var s string
-	if err := json.Unmarshal(data, &s); err != nil {
-		return fmt.Errorf("URN should be a string, but got %s: %w", string(data), err)
-	}
-
-	// FIXED: This logic now works because Parse("") returns a valid,
-	// zero-value URN instead of an error.
-	if strings.HasPrefix(s, Scheme+urnDelimiter) {
-		parsedURN, parseErr := Parse(s)
-		if parseErr != nil {
-			return parseErr
-		}
-		*u = parsedURN
-		return nil
+	parsed, err := DefaultDecodeOptions().DecodeJSON(data)
+	if err != nil {
+		return err
 	}
-
-	// FIXED: Handle the empty string case from JSON
-	if s == "" {
-		*u = URN{}
-		return nil
-	}
-
-	if s != "" {
-		legacyURN, err := New(SecureMessaging, EntityTypeUser, s)
-		if err != nil {
-			return err
-		}
-		*u = legacyURN
-		return nil
-	}
-
-	return ErrInvalidFormat
+	*u = parsed
+	return nil
 }
@@ -117,6 +117,18 @@ func TestParse(t *testing.T) {
 			expectErr:     true,
 			expectedErrIs: urn.ErrInvalidFormat,
 		},
+		{
+			name:        "Percent-encoded Entity ID",
+			input:       "urn:sm:user:alice%3awork",
+			expectedURN: "urn:sm:user:alice%3Awork",
+			expectErr:   false,
+		},
+		{
+			name:          "Invalid percent-encoding",
+			input:         "urn:sm:user:alice%3",
+			expectErr:     true,
+			expectedErrIs: urn.ErrInvalidFormat,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -138,6 +150,66 @@ func TestParse(t *testing.T) {
 	}
 }
 
+// TestReservedCharacters validates that reserved characters in any component
+// are escaped by String() and restored by Parse().
+func TestReservedCharacters(t *testing.T) {
+	testCases := []struct {
+		name        string
+		entityID    string
+		expectedURN string
+	}{
+		{name: "Colon", entityID: "alice@example.com:work", expectedURN: "urn:sm:user:alice@example.com%3Awork"},
+		{name: "Percent", entityID: "100%", expectedURN: "urn:sm:user:100%25"},
+		{name: "Slash", entityID: "a/b", expectedURN: "urn:sm:user:a%2Fb"},
+		{name: "Query and fragment", entityID: "a?b#c", expectedURN: "urn:sm:user:a%3Fb%23c"},
+		{name: "Space and unicode", entityID: "Zoë B", expectedURN: "urn:sm:user:Zo%C3%AB%20B"},
+		{name: "Unreserved untouched", entityID: "a-b.c_d~e!$&'()*+,;=@", expectedURN: "urn:sm:user:a-b.c_d~e!$&'()*+,;=@"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := urn.New(urn.SecureMessaging, urn.EntityTypeUser, tc.entityID)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedURN, u.String())
+
+			parsed, err := urn.Parse(u.String())
+			require.NoError(t, err)
+			assert.Equal(t, u, parsed)
+			assert.Equal(t, tc.entityID, parsed.EntityID())
+
+			strict, err := urn.ParseRFC8141(u.String())
+			require.NoError(t, err)
+			assert.Equal(t, u, strict)
+		})
+	}
+}
+
+// FuzzRoundTrip checks that every URN accepted by New() survives a
+// String() -> Parse() round trip, both directly and through JSON.
+func FuzzRoundTrip(f *testing.F) {
+	f.Add(urn.SecureMessaging, "user", "user-123")
+	f.Add(urn.SecureMessaging, "user", "alice@example.com:work")
+	f.Add("s:m", "a%b", "%%3A::/")
+	f.Add("sm", "group", "\x00\xff")
+
+	f.Fuzz(func(t *testing.T, namespace, entityType, entityID string) {
+		u, err := urn.New(namespace, entityType, entityID)
+		if err != nil {
+			return
+		}
+
+		parsed, err := urn.Parse(u.String())
+		require.NoError(t, err, "failed to parse %q", u.String())
+		assert.Equal(t, u, parsed)
+
+		data, err := json.Marshal(u)
+		require.NoError(t, err)
+		var decoded urn.URN
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, u, decoded)
+	})
+}
+
 func TestJSONMarshaling(t *testing.T) {
 	u, err := urn.New(urn.SecureMessaging, "user", "user-123")
 	require.NoError(t, err)
@@ -206,3 +278,70 @@ func TestJSONUnmarshaling(t *testing.T) {
 		})
 	}
 }
+
+// TestParseAllocations guards the allocation-free fast path of Parse for
+// URNs that are already in canonical form.
+func TestParseAllocations(t *testing.T) {
+	for _, s := range []string{
+		"urn:sm:user:user-123",
+		"urn:sm:conversation:0f8fad5b-d9cb-469f-a165-70867728950e",
+		"urn:sm:user:alice/device:phone1/key:42",
+		"urn:sm:user:alice%3Awork/device:phone1",
+	} {
+		t.Run(s, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(100, func() {
+				if _, err := urn.Parse(s); err != nil {
+					t.Fatal(err)
+				}
+			})
+			assert.Zero(t, allocs)
+		})
+	}
+
+	t.Run("Non-canonical parent is rebuilt", func(t *testing.T) {
+		u, err := urn.Parse("urn:sm:user:%61lice/device:phone1")
+		require.NoError(t, err)
+		assert.Equal(t, "urn:sm:user:alice/device:phone1", u.String())
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	benchmarks := []struct {
+		name  string
+		input string
+	}{
+		{name: "Simple", input: "urn:sm:user:user-123"},
+		{name: "UUID", input: "urn:sm:conversation:0f8fad5b-d9cb-469f-a165-70867728950e"},
+		{name: "Hierarchical", input: "urn:sm:user:alice/device:phone1/key:42"},
+		{name: "Escaped", input: "urn:sm:user:alice%3Awork"},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := urn.Parse(bm.input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkInternerParse(b *testing.B) {
+	in := urn.NewInterner(0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := in.Parse("urn:custom:widget:w-1"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkURNString(b *testing.B) {
+	u, err := urn.Parse("urn:sm:user:alice/device:phone1")
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = u.String()
+	}
+}